@@ -30,19 +30,26 @@ func main() {
 		Short: "GORM Migration Tool",
 	}
 
+	commands.AddConfigFlag(rootCmd)
+
 	rootCmd.AddCommand(
 		commands.RegisterCmd(),
 		commands.InitCmd(),
 		commands.GenerateCmd(),
+		commands.DriftCmd(),
 		commands.UpCmd(),
 		commands.DownCmd(),
 		commands.StatusCmd(),
 		commands.HistoryCmd(),
+		commands.SquashCmd(),
 		commands.ValidateCmd(),
+		commands.BaselineCmd(),
+		commands.RedoCmd(),
+		commands.DiffCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(commands.ExitCode(err))
 	}
 }
@@ -27,15 +27,22 @@ func main() {
 		Short: "Database Migration Tool",
 	}
 
+	commands.AddConfigFlag(rootCmd)
+
 	rootCmd.AddCommand(
 		commands.RegisterCmd(),
 		commands.InitCmd(),
 		commands.GenerateCmd(),
+		commands.DriftCmd(),
+		commands.DiffCmd(),
 		commands.UpCmd(),
 		commands.DownCmd(),
 		commands.StatusCmd(),
 		commands.HistoryCmd(),
+		commands.SquashCmd(),
 		commands.ValidateCmd(),
+		commands.BaselineCmd(),
+		commands.RedoCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
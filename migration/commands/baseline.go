@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func BaselineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Mark all migrations up to a version as applied without running them",
+		Long:  `Records every migration up to and including --to as applied, for a database that already reflects them, e.g. when adopting gorm-migrate on an existing schema. The migrations themselves are not run.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, _ := cmd.Flags().GetString("to")
+			if to == "" {
+				return fmt.Errorf("--to is required")
+			}
+
+			loader, err := getMigrationLoader()
+			if err != nil {
+				return fmt.Errorf("failed to create migration loader: %v", err)
+			}
+
+			migrations, err := loader.LoadMigrations()
+			if err != nil {
+				return fmt.Errorf("failed to load migrations: %v", err)
+			}
+
+			db, err := getDB()
+			if err != nil {
+				return err
+			}
+
+			return baseline(db, migrations, to)
+		},
+	}
+
+	cmd.Flags().String("to", "", "Version of the last migration to mark as applied")
+
+	return cmd
+}
+
+// baseline records the pending migrations with a version up to and
+// including to as applied, in a single insert. The version must be one of
+// the loaded migrations.
+func baseline(db *gorm.DB, migrations []*migration.Migration, to string) error {
+	if findMigration(migrations, to) == nil {
+		return fmt.Errorf("migration %s not found", to)
+	}
+
+	pending, err := pendingMigrations(db, migrations)
+	if err != nil {
+		return err
+	}
+
+	var records []migration.MigrationRecord
+	now := time.Now()
+	for _, mr := range pending {
+		if mr.Version > to {
+			continue
+		}
+		records = append(records, migration.MigrationRecord{Version: mr.Version, Name: mr.Name, AppliedAt: now})
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("Migrations up to %s are already applied.\n", to)
+		return nil
+	}
+
+	if err := db.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to record migrations: %v", err)
+	}
+
+	for _, record := range records {
+		fmt.Printf("Marked as applied: %s (%s)\n", record.Name, record.Version)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func TestBaselineUpToVersion(t *testing.T) {
+	db := newMigrationsTestDB(t)
+
+	var ran []string
+	run := func(name string) func(*gorm.DB) error {
+		return func(*gorm.DB) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "add_widgets", Up: run("add_widgets")},
+		{Version: "20240102000000", Name: "add_gadgets", Up: run("add_gadgets")},
+		{Version: "20240103000000", Name: "add_gizmos", Up: run("add_gizmos")},
+	}
+
+	require.Error(t, baseline(db, migrations, "20240199000000"))
+	require.NoError(t, baseline(db, migrations, "20240102000000"))
+	require.Empty(t, ran, "baseline should not run migrations")
+
+	pending, err := pendingMigrations(db, migrations)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "add_gizmos", pending[0].Name)
+
+	// Stamping again leaves the recorded migrations as they are
+	require.NoError(t, baseline(db, migrations, "20240102000000"))
+	var count int64
+	require.NoError(t, db.Model(&migration.MigrationRecord{}).Count(&count).Error)
+	require.Equal(t, int64(2), count)
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/beesaferoot/gorm-migrate/migration/config"
+)
+
+// activeConfig holds the settings loaded from the config file
+var activeConfig = &config.Config{}
+
+// LoadConfig loads settings from the config file at path, or from
+// gorm-migrate.yaml in the working directory when path is empty
+func LoadConfig(path string) error {
+	var (
+		cfg *config.Config
+		err error
+	)
+	if path == "" {
+		cfg, err = config.LoadDefault()
+	} else {
+		cfg, err = config.Load(path)
+	}
+	if err != nil {
+		return err
+	}
+	activeConfig = cfg
+	return nil
+}
+
+// AddConfigFlag registers the global --config flag on the root command and
+// loads the config file before any subcommand runs
+func AddConfigFlag(root *cobra.Command) {
+	root.PersistentFlags().String("config", "", fmt.Sprintf("Path to the config file (default ./%s)", config.DefaultFile))
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		return LoadConfig(path)
+	}
+}
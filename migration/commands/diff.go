@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
+)
+
+func DiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Summarize the differences between the database and the models",
+		Long:  `Compares the registered models with the database and prints one plain-English line per change, such as "add column users.email varchar", without generating SQL or writing a migration.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pruneUnmanaged, _ := cmd.Flags().GetBool("prune-unmanaged")
+			detectRenames, _ := cmd.Flags().GetBool("detect-renames")
+
+			db, err := getIntrospectionDB(nil)
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			changes, err := schemaChanges(db, pruneUnmanaged, detectRenames)
+			if err != nil {
+				return err
+			}
+
+			printOperations(cmd.OutOrStdout(), changes)
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("prune-unmanaged", false, "Include database tables that have no registered model as tables to drop")
+	cmd.Flags().Bool("detect-renames", false, "Report a table's only dropped and only added column as a rename when their types match")
+
+	return cmd
+}
+
+// printOperations writes the summary of changes, one operation per line
+func printOperations(w io.Writer, changes *diff.SchemaDiff) {
+	if changes == nil || !hasChanges(changes) {
+		fmt.Fprintln(w, "No schema changes detected")
+		return
+	}
+	for _, op := range changes.Operations() {
+		fmt.Fprintln(w, op)
+	}
+}
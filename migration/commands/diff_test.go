@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
+
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
+)
+
+func TestPrintOperations(t *testing.T) {
+	users := &schema.Schema{Table: "users"}
+	orders := &schema.Schema{Table: "orders"}
+	userID := &schema.Field{DBName: "user_id", DataType: schema.Uint, Schema: orders}
+	fk := &schema.Relationship{
+		Schema:     orders,
+		References: []*schema.Reference{{ForeignKey: userID, PrimaryKey: &schema.Field{DBName: "id", Schema: users}}},
+	}
+	changes := &diff.SchemaDiff{
+		TablesToCreate: []diff.TableDiff{{Schema: orders, FieldsToAdd: []*schema.Field{userID}, ForeignKeysToAdd: []*schema.Relationship{fk}}},
+		TablesToModify: []diff.TableDiff{{
+			Schema:         users,
+			FieldsToAdd:    []*schema.Field{{DBName: "email", DataType: schema.String}},
+			FieldsToModify: []*schema.Field{{DBName: "age", DataType: schema.Int}},
+			PreviousFields: map[string]*schema.Field{"age": {DBName: "age", DataType: "varchar"}},
+			FieldsToDrop:   []*schema.Field{{DBName: "nickname", DataType: "varchar"}},
+			IndexesToAdd:   []*schema.Index{{Name: "idx_users_email"}},
+		}},
+		TablesToDrop: []string{"sessions"},
+	}
+
+	var out bytes.Buffer
+	printOperations(&out, changes)
+	require.Equal(t, []string{
+		"create table orders",
+		"add foreign key orders.user_id → users",
+		"add column users.email varchar",
+		"modify column users.age from varchar to bigint",
+		"drop column users.nickname",
+		"add index idx_users_email on users",
+		"drop table sessions",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+
+	out.Reset()
+	printOperations(&out, &diff.SchemaDiff{})
+	require.Equal(t, "No schema changes detected\n", out.String())
+}
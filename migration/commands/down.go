@@ -1,9 +1,19 @@
 package commands
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 
 	"github.com/beesaferoot/gorm-migrate/migration"
 )
@@ -14,15 +24,53 @@ func DownCmd() *cobra.Command {
 		Short: "Revert the last migration",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			debug, _ := cmd.Flags().GetBool("debug")
+			only, _ := cmd.Flags().GetString("only")
+			yes, _ := cmd.Flags().GetBool("yes")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			to, _ := cmd.Flags().GetString("to")
+
+			if to != "" && only != "" {
+				return fmt.Errorf("--to cannot be combined with --only")
+			}
 
 			db, err := getDB()
 			if err != nil {
 				return err
 			}
 
+			if to != "" {
+				loader, err := getMigrationLoader()
+				if err != nil {
+					return fmt.Errorf("failed to create migration loader: %v", err)
+				}
+
+				loader.SetDebug(debug)
+
+				migrations, err := loader.LoadMigrations()
+				if err != nil {
+					return fmt.Errorf("failed to load migrations: %v", err)
+				}
+
+				return downTo(db, migrations, to, func(mr *migration.Migration) error {
+					if dryRun {
+						printDownPlan(cmd.OutOrStdout(), db, mr)
+						return errSkipRevert
+					}
+					if yes {
+						return nil
+					}
+					return confirmDown(db, mr, cmd.InOrStdin(), isTerminal(os.Stdin))
+				})
+			}
+
 			var record migration.MigrationRecord
-			if err := db.Order("applied_at DESC").First(&record).Error; err != nil {
-				return fmt.Errorf("no migrations to revert")
+			if only != "" {
+				if err := db.Where("version = ?", only).First(&record).Error; err != nil {
+					return fmt.Errorf("migration %s is not applied", only)
+				}
+				fmt.Printf("Warning: --only reverts migration %s regardless of apply order\n", only)
+			} else if record, err = lastApplied(db); err != nil {
+				return err
 			}
 
 			loader, err := getMigrationLoader()
@@ -37,43 +85,211 @@ func DownCmd() *cobra.Command {
 				return fmt.Errorf("failed to load migrations: %v", err)
 			}
 
-			var targetMigration *migration.Migration
-			for _, m := range migrations {
-				if m.Version == record.Version {
-					targetMigration = m
-					break
-				}
-			}
-
-			if targetMigration == nil {
-				return fmt.Errorf("migration file for version %s not found", record.Version)
-			}
-
-			tx := db.Begin()
-			if tx.Error != nil {
-				return fmt.Errorf("failed to start transaction: %v", tx.Error)
-			}
-
-			if err := targetMigration.Down(tx); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to revert migration %s: %v", targetMigration.Name, err)
+			targetMigration, err := appliedMigration(migrations, record)
+			if err != nil {
+				return err
 			}
 
-			if err := tx.Delete(&record).Error; err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to remove migration record: %v", err)
+			if dryRun {
+				printDownPlan(cmd.OutOrStdout(), db, targetMigration)
+				return nil
 			}
 
-			if err := tx.Commit().Error; err != nil {
-				return fmt.Errorf("failed to commit transaction: %v", err)
+			if !yes {
+				if err := confirmDown(db, targetMigration, cmd.InOrStdin(), isTerminal(os.Stdin)); err != nil {
+					return err
+				}
 			}
 
-			fmt.Printf("Successfully reverted migration: %s\n", targetMigration.Name)
-			return nil
+			return revertMigration(db, record, targetMigration)
 		},
 	}
 
+	cmd.Flags().Bool("dry-run", false, "Show the migration that would be reverted and its SQL without running it")
 	cmd.Flags().Bool("debug", false, "Enable debug output")
+	cmd.Flags().String("only", "", "Revert only the migration with this version (advanced)")
+	cmd.Flags().String("to", "", "Revert applied migrations in reverse order down to, but not including, this version")
+	cmd.Flags().Bool("yes", false, "Revert without asking for confirmation, required for destructive downs outside a terminal")
 
 	return cmd
 }
+
+// revertMigration runs a migration's Down and removes its record, in one
+// transaction
+func revertMigration(db *gorm.DB, record migration.MigrationRecord, mr *migration.Migration) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return fmt.Errorf("failed to start transaction: %v", tx.Error)
+	}
+
+	if err := mr.Down(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revert migration %s: %v", mr.Name, err)
+	}
+
+	if err := tx.Delete(&record).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to remove migration record: %v", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	fmt.Printf("Successfully reverted migration: %s\n", mr.Name)
+	return nil
+}
+
+// errSkipRevert is returned by a downTo check to go past a migration
+// without reverting it, as on a dry run
+var errSkipRevert = errors.New("skip revert")
+
+// downTo reverts the applied migrations with a version after to, latest
+// first, stopping at the first failure. check runs before each revert and
+// can refuse it. The version must be one of the loaded migrations.
+func downTo(db *gorm.DB, migrations []*migration.Migration, to string, check func(*migration.Migration) error) error {
+	if findMigration(migrations, to) == nil {
+		return fmt.Errorf("migration %s not found", to)
+	}
+
+	var records []migration.MigrationRecord
+	if err := db.Where("version > ?", to).Order("version DESC").Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+	if len(records) == 0 {
+		fmt.Printf("No migrations applied after %s.\n", to)
+		return nil
+	}
+
+	for _, record := range records {
+		mr, err := appliedMigration(migrations, record)
+		if err != nil {
+			return err
+		}
+		if err := check(mr); errors.Is(err, errSkipRevert) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := revertMigration(db, record, mr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastApplied returns the record of the most recently applied migration
+func lastApplied(db *gorm.DB) (migration.MigrationRecord, error) {
+	var record migration.MigrationRecord
+	if err := db.Order(migration.LatestFirst).First(&record).Error; err != nil {
+		return record, fmt.Errorf("no migrations to revert")
+	}
+	return record, nil
+}
+
+// appliedMigration returns the loaded migration of an applied record. A
+// record without a migration file leaves the database in a dirty state.
+func appliedMigration(migrations []*migration.Migration, record migration.MigrationRecord) (*migration.Migration, error) {
+	mr := findMigration(migrations, record.Version)
+	if mr == nil {
+		return nil, fmt.Errorf("%w: migration file for applied version %s not found", migration.ErrDirtyState, record.Version)
+	}
+	return mr, nil
+}
+
+// destructiveSQL matches statements that lose data when run
+var destructiveSQL = regexp.MustCompile(`(?i)\b(DROP\s+TABLE|DROP\s+COLUMN|TRUNCATE|DELETE\s+FROM)\b`)
+
+// confirmDown shows the SQL of a migration's Down and asks before running it
+// when it drops tables or columns or deletes rows. Outside a terminal such a
+// down is refused; --yes skips the check.
+func confirmDown(db *gorm.DB, mr *migration.Migration, in io.Reader, interactive bool) error {
+	statements, err := downStatements(db, mr)
+	if err == nil && !isDestructive(statements) {
+		return nil
+	}
+
+	fmt.Printf("Migration %s (%s) runs:\n", mr.Name, mr.Version)
+	for _, statement := range statements {
+		fmt.Printf("  %s\n", statement)
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not preview the SQL of this migration: %v\n", err)
+	}
+
+	if !interactive {
+		return fmt.Errorf("refusing to revert destructive migration %s without --yes", mr.Name)
+	}
+
+	fmt.Print("This will remove data. Continue? [y/N]: ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("revert of migration %s cancelled", mr.Name)
+}
+
+// printDownPlan writes the migration a down would revert and the SQL its Down
+// runs, without running it
+func printDownPlan(w io.Writer, db *gorm.DB, mr *migration.Migration) {
+	statements, err := downStatements(db, mr)
+	fmt.Fprintf(w, "Would revert migration: %s (%s)\n", mr.Name, mr.Version)
+	for _, statement := range statements {
+		fmt.Fprintf(w, "  %s\n", statement)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Warning: could not preview the SQL of this migration: %v\n", err)
+	}
+}
+
+// downStatements returns the SQL a migration's Down would run, without
+// running it
+func downStatements(db *gorm.DB, mr *migration.Migration) (statements []string, err error) {
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	defer func() {
+		if r := recover(); r != nil {
+			statements, err = recorder.statements, fmt.Errorf("%v", r)
+		}
+	}()
+
+	err = mr.Down(db.Session(&gorm.Session{DryRun: true, Logger: recorder}))
+	return recorder.statements, err
+}
+
+// isDestructive reports whether any statement drops tables or columns or
+// deletes rows
+func isDestructive(statements []string) bool {
+	for _, statement := range statements {
+		if destructiveSQL.MatchString(statement) {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlRecorder is a logger that keeps the SQL of every statement it traces
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// The null device is a character device as well
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func TestConfirmDown(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	require.NoError(t, db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)").Error)
+
+	dropWidgets := &migration.Migration{
+		Version: "20240101000000",
+		Name:    "create_widgets",
+		Down: func(db *gorm.DB) error {
+			return db.Exec("DROP TABLE widgets").Error
+		},
+	}
+
+	statements, err := downStatements(db, dropWidgets)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DROP TABLE widgets"}, statements)
+	require.True(t, db.Migrator().HasTable("widgets"), "previewing a down should not run it")
+
+	require.Error(t, confirmDown(db, dropWidgets, strings.NewReader(""), false))
+	require.Error(t, confirmDown(db, dropWidgets, strings.NewReader("n\n"), true))
+	require.NoError(t, confirmDown(db, dropWidgets, strings.NewReader("y\n"), true))
+
+	// Non-destructive downs run without asking
+	dropIndex := &migration.Migration{
+		Version: "20240102000000",
+		Name:    "add_widgets_index",
+		Down: func(db *gorm.DB) error {
+			return db.Exec("DROP INDEX IF EXISTS idx_widgets_id").Error
+		},
+	}
+	require.NoError(t, confirmDown(db, dropIndex, strings.NewReader(""), false))
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func DriftCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:          "drift",
+		Short:        "Check whether the database schema matches the models",
+		Long:         `Compares the registered models with the database without writing a migration. Exits with code 0 when the schema is up to date, 2 when a migration is needed and 1 on any other error.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := getIntrospectionDB(nil)
+			if err != nil {
+				return err
+			}
+
+			changes, err := schemaChanges(db, false, false)
+			if err != nil {
+				return err
+			}
+
+			if !hasChanges(changes) {
+				fmt.Println("No schema changes detected")
+				return nil
+			}
+
+			fmt.Printf("Schema changes detected: %d table(s) to create, %d to modify, %d to drop\n",
+				len(changes.TablesToCreate), len(changes.TablesToModify), len(changes.TablesToDrop))
+			return ErrSchemaDrift
+		},
+	}
+}
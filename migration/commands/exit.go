@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"errors"
+)
+
+// Process exit codes returned by the CLI
+const (
+	// ExitOK means the command succeeded (or there was nothing to do)
+	ExitOK = 0
+	// ExitError means the command failed
+	ExitError = 1
+	// ExitChanges means the models and the database differ and a migration is needed
+	ExitChanges = 2
+)
+
+// ErrSchemaDrift is returned when the database schema does not match the models
+var ErrSchemaDrift = errors.New("schema drift detected: models and database differ")
+
+// ExitCode maps an error returned by a command to the process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, ErrSchemaDrift) {
+		return ExitChanges
+	}
+	return ExitError
+}
@@ -1,79 +1,453 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 
+	"github.com/beesaferoot/gorm-migrate/migration"
 	"github.com/beesaferoot/gorm-migrate/migration/diff"
+	"github.com/beesaferoot/gorm-migrate/migration/file"
 	"github.com/beesaferoot/gorm-migrate/migration/generator"
 	modelparser "github.com/beesaferoot/gorm-migrate/migration/parser"
 )
 
+// errSimulateRollback discards a simulated migration
+var errSimulateRollback = errors.New("simulate rollback")
+
+// typeMappers are registered on the generator used by generate
+var typeMappers []generator.TypeMapper
+
+// AddTypeMapper makes generate map custom field types, such as
+// decimal.Decimal, with mapper before the built-in mapping
+func AddTypeMapper(mapper generator.TypeMapper) {
+	typeMappers = append(typeMappers, mapper)
+}
+
 func GenerateCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "generate [name]",
 		Short: "Generate a migration from model changes",
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			name := args[0]
+			printOnly, _ := cmd.Flags().GetBool("print")
+			if len(args) == 0 && !printOnly {
+				return fmt.Errorf("generate needs a migration name")
+			}
+
+			if dataOnly, _ := cmd.Flags().GetBool("data-only"); dataOnly {
+				if printOnly {
+					return fmt.Errorf("--data-only cannot be combined with --print")
+				}
+				gen := generator.NewGenerator(getMigrationsDir())
+				if err := gen.WriteDataMigration(newVersion(), args[0]); err != nil {
+					return fmt.Errorf("failed to generate migration: %v", err)
+				}
+				fmt.Printf("Generated data migration: %s\n", args[0])
+				return nil
+			}
+
+			dialect, _ := cmd.Flags().GetString("dialect")
+			if err := validateDialect(dialect); err != nil {
+				return err
+			}
+
+			failOnEmpty, _ := cmd.Flags().GetBool("fail-on-empty")
+			merge, _ := cmd.Flags().GetBool("merge")
+			pruneUnmanaged, _ := cmd.Flags().GetBool("prune-unmanaged")
+			detectRenames, _ := cmd.Flags().GetBool("detect-renames")
+			sqlPreview, _ := cmd.Flags().GetBool("sql")
+			outputDir, _ := cmd.Flags().GetString("output-dir")
+			ifNotExists, _ := cmd.Flags().GetBool("if-not-exists")
+			cascade, _ := cmd.Flags().GetBool("cascade")
+			fkNotValid, _ := cmd.Flags().GetBool("fk-not-valid")
+			simulate, _ := cmd.Flags().GetBool("simulate")
+			scratchURL, _ := cmd.Flags().GetString("scratch-url")
+			if scratchURL == "" {
+				scratchURL = os.Getenv("SIMULATE_DATABASE_URL")
+			}
+			if simulate && scratchURL == "" {
+				return fmt.Errorf("--simulate needs an empty scratch database: pass --scratch-url or set SIMULATE_DATABASE_URL")
+			}
 
 			db, err := getDB()
 			if err != nil {
 				return err
 			}
 
-			parser, err := modelparser.NewModelParser(db)
+			introspectDB, err := getIntrospectionDB(db)
 			if err != nil {
-				return fmt.Errorf("failed to create model parser: %v", err)
+				return err
 			}
 
-			modelSchemas, err := parser.Parse()
+			changes, err := schemaChanges(introspectDB, pruneUnmanaged, detectRenames)
 			if err != nil {
-				return fmt.Errorf("failed to parse models: %v", err)
+				return err
 			}
+			warnRemovedEnumValues(changes)
 
-			if len(modelSchemas) == 0 {
-				return fmt.Errorf("no GORM models found in registry")
+			if changes == nil || !hasChanges(changes) {
+				return noChanges(failOnEmpty)
 			}
 
-			comparer := diff.NewSchemaComparer(db)
-
-			currentSchema, err := comparer.GetCurrentSchema()
-			if err != nil {
-				return fmt.Errorf("failed to get current schema: %v", err)
+			gen := generator.NewGenerator(getMigrationsDir())
+			gen.SetSchemaDiff(changes)
+			configureGenerator(gen)
+			if dialect == "" && activeConfig.Dialect == "" {
+				dialect = generator.DialectOf(db)
+			}
+			if dialect != "" {
+				gen.SetDialect(dialect)
+			}
+			gen.SetIfNotExists(ifNotExists)
+			gen.SetCascade(cascade)
+			gen.SetNotValidFKs(fkNotValid)
+			for _, mapper := range typeMappers {
+				gen.RegisterTypeMapper(mapper)
 			}
 
-			changes, err := comparer.CompareSchemas(currentSchema, modelSchemas)
-			if err != nil {
-				return fmt.Errorf("failed to compare schemas: %v", err)
+			if simulate {
+				loader, err := getMigrationLoader()
+				if err != nil {
+					return fmt.Errorf("failed to create migration loader: %v", err)
+				}
+				migrations, err := loader.LoadMigrations()
+				if err != nil {
+					return fmt.Errorf("failed to load migrations: %v", err)
+				}
+				scratch, err := openDatabase(db.Name(), scratchURL)
+				if err != nil {
+					return fmt.Errorf("failed to open scratch database: %v", err)
+				}
+				err = simulateMigration(db, scratch, gen, migrations)
+				closeDB(scratch)
+				if err != nil {
+					return err
+				}
+				fmt.Println("Simulated migration applied cleanly")
 			}
+			if printOnly {
+				return printSQL(cmd.OutOrStdout(), gen)
+			}
+			name := args[0]
 
-			if changes == nil {
-				fmt.Println("No schema changes detected")
-				return nil
+			if outputDir != "" {
+				if outputDir, err = validateMigrationsPath(outputDir); err != nil {
+					return fmt.Errorf("invalid output dir: %v", err)
+				}
+			} else {
+				outputDir = gen.MigrationsDir
 			}
 
-			if !hasChanges(changes) {
-				fmt.Println("No schema changes detected")
-				return nil
+			written, err := writeMigration(db, gen, name, merge, failOnEmpty)
+			if err != nil || written == nil || !sqlPreview {
+				return err
 			}
+			return writeSQLPreview(gen, written, outputDir)
+		},
+	}
 
-			gen := generator.NewGenerator(getMigrationsDir())
-			gen.SetSchemaDiff(changes)
+	cmd.Flags().Bool("fail-on-empty", false, "Return an error when there are no schema changes to generate")
+	cmd.Flags().Bool("prune-unmanaged", false, "Drop database tables that have no registered model")
+	cmd.Flags().Bool("detect-renames", false, "Report a table's only dropped and only added column as a rename when their types match")
+	cmd.Flags().Bool("merge", false, "Regenerate the latest migration instead of creating a new one, if it has not been applied")
+	cmd.Flags().Bool("sql", false, "Also write the migration's Up and Down SQL to .sql files for review")
+	cmd.Flags().Bool("if-not-exists", false, "Emit ADD COLUMN IF NOT EXISTS and DROP COLUMN IF EXISTS so the migration can be re-run")
+	cmd.Flags().Bool("cascade", false, "Emit DROP TABLE ... CASCADE so dropped tables take dependent foreign keys and views with them")
+	cmd.Flags().Bool("fk-not-valid", false, "Add foreign keys to existing tables NOT VALID and validate them in a later statement, outside a transaction")
+	cmd.Flags().Bool("simulate", false, "Apply the generated Up SQL to a scratch copy of the schema in a transaction that is rolled back, and stop on the first failing statement")
+	cmd.Flags().String("scratch-url", "", "Empty database --simulate rebuilds the current schema in from the applied migrations (default: SIMULATE_DATABASE_URL)")
+	cmd.Flags().Bool("data-only", false, "Scaffold a migration for hand-written data changes, without comparing schemas")
+	cmd.Flags().Bool("print", false, "Print the Up and Down SQL to stdout instead of writing a migration")
+	cmd.Flags().String("dialect", "", "SQL dialect to generate: postgres, mysql, sqlite or cockroach (default: the config file's dialect, then the connected database's)")
+	cmd.Flags().String("output-dir", "", "Directory for review artifacts such as --sql files (default: the migrations directory)")
+
+	return cmd
+}
+
+// validateDialect checks a --dialect value; empty means none was given
+func validateDialect(dialect string) error {
+	switch dialect {
+	case "", generator.DialectPostgres, generator.DialectMySQL, generator.DialectSQLite, generator.DialectCockroach:
+		return nil
+	}
+	return fmt.Errorf("unknown dialect %q: use postgres, mysql, sqlite or cockroach", dialect)
+}
+
+// configureGenerator applies the config file's generator settings
+func configureGenerator(gen *generator.Generator) {
+	if activeConfig.Dialect != "" {
+		gen.SetDialect(activeConfig.Dialect)
+	}
+	gen.SetCharset(activeConfig.MySQLCharset, activeConfig.MySQLCollation)
+	gen.SetTypeOverrides(activeConfig.TypeOverrides)
+	gen.SetDefaultOnDelete(activeConfig.DefaultOnDelete)
+	if activeConfig.QuoteIdentifiers != nil {
+		gen.SetQuoteIdentifiers(*activeConfig.QuoteIdentifiers)
+	}
+	for _, sql := range activeConfig.Preamble {
+		gen.AddPreamble(sql)
+	}
+	for _, sql := range activeConfig.Postamble {
+		gen.AddPostamble(sql)
+	}
+}
+
+// newVersion returns the version of a migration created now; tests replace it
+// to create migrations at distinct versions without waiting for the clock
+var newVersion = generator.NewVersion
+
+// writeMigration writes the generated migration: a new file, the rewritten
+// latest file with --merge, or nothing when the latest unapplied migration
+// already holds the same changes. It returns the migration written, if any.
+func writeMigration(db *gorm.DB, gen *generator.Generator, name string, merge, failOnEmpty bool) (*file.MigrationFile, error) {
+	latest, applied, err := latestMigration(db, gen.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := false
+	target := &file.MigrationFile{Version: newVersion(), Name: name}
+	if merge && latest != nil {
+		if applied {
+			return nil, fmt.Errorf("cannot merge into migration %s (%s): it has already been applied", latest.Name, latest.Version)
+		}
+		target, merged = latest, true
+	}
+
+	if !merged && latest != nil && !applied {
+		if same, err := gen.MatchesMigration(latest.Version, latest.Name); err == nil && same {
+			fmt.Printf("Migration %s (%s) is not applied yet and already contains these changes; nothing generated\n", latest.Name, latest.Version)
+			return nil, nil
+		}
+	}
+
+	if err := gen.WriteMigration(target.Version, target.Name); err != nil {
+		if errors.Is(err, generator.ErrNoChanges) {
+			return nil, noChanges(failOnEmpty)
+		}
+		return nil, fmt.Errorf("failed to generate migration: %v", err)
+	}
+
+	if merged {
+		fmt.Printf("Merged changes into migration: %s (%s)\n", target.Name, target.Version)
+	} else {
+		fmt.Printf("Generated migration: %s\n", name)
+	}
+	return target, nil
+}
+
+// writeSQLPreview writes the Up and Down SQL of a generated migration to
+// <version>_<name>.up.sql and .down.sql files in dir
+func writeSQLPreview(gen *generator.Generator, written *file.MigrationFile, dir string) error {
+	upSQL, downSQL, err := gen.GenerateSQL()
+	if err != nil {
+		return fmt.Errorf("failed to generate SQL preview: %v", err)
+	}
 
-			if err := gen.CreateMigration(name); err != nil {
-				return fmt.Errorf("failed to generate migration: %v", err)
+	base := filepath.Join(dir, fmt.Sprintf("%s_%s", written.Version, written.Name))
+	if err := os.WriteFile(base+".up.sql", []byte(upSQL+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write SQL preview: %v", err)
+	}
+	if err := os.WriteFile(base+".down.sql", []byte(downSQL+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write SQL preview: %v", err)
+	}
+
+	fmt.Printf("Wrote SQL preview to %s.{up,down}.sql\n", base)
+	return nil
+}
+
+// simulateMigration rebuilds the current schema in an empty scratch
+// database by applying the migrations already applied to db, then runs the
+// generated Up statements there and reports the first one that fails. All of
+// it happens in a transaction that is rolled back; db itself is only read, so
+// no lock is taken on its tables. MySQL commits DDL implicitly, and a
+// NonTransactional migration cannot run in a transaction, so neither a
+// migration for MySQL nor one for a MySQL database can be simulated.
+func simulateMigration(db, scratch *gorm.DB, gen *generator.Generator, migrations []*migration.Migration) error {
+	if gen.Dialect == generator.DialectMySQL || generator.DialectOf(db) == generator.DialectMySQL {
+		return fmt.Errorf("--simulate needs transactional DDL, which MySQL does not support")
+	}
+	if gen.NonTransactional() {
+		return fmt.Errorf("--simulate cannot run a migration that must run outside a transaction")
+	}
+
+	statements, err := gen.UpStatements()
+	if err != nil {
+		return fmt.Errorf("failed to generate SQL: %v", err)
+	}
+
+	pending, err := pendingMigrations(db, migrations)
+	if err != nil {
+		return err
+	}
+	isPending := make(map[string]bool)
+	for _, mr := range pending {
+		isPending[mr.Version] = true
+	}
+
+	err = scratch.Transaction(func(tx *gorm.DB) error {
+		tables, err := tx.Migrator().GetTables()
+		if err != nil {
+			return fmt.Errorf("failed to read scratch database: %v", err)
+		}
+		if len(tables) > 0 {
+			return fmt.Errorf("scratch database is not empty")
+		}
+		for _, mr := range migrations {
+			if isPending[mr.Version] {
+				continue
+			}
+			if err := mr.Up(tx); err != nil {
+				return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
 			}
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("simulated statement %s failed: %w", strings.Join(strings.Fields(statement), " "), err)
+			}
+		}
+		return errSimulateRollback
+	})
+	if !errors.Is(err, errSimulateRollback) {
+		return err
+	}
+	return nil
+}
 
-			fmt.Printf("Generated migration: %s\n", name)
-			return nil
-		},
+// printSQL writes the Up and Down SQL of the schema diff to w, without
+// creating any file
+func printSQL(w io.Writer, gen *generator.Generator) error {
+	upSQL, downSQL, err := gen.GenerateSQL()
+	if err != nil {
+		return fmt.Errorf("failed to generate SQL: %v", err)
+	}
+
+	fmt.Fprintf(w, "-- Up\n%s\n\n-- Down\n%s\n", upSQL, downSQL)
+	return nil
+}
+
+// latestMigration returns the latest migration file in dir, if any, and
+// whether it has been applied
+func latestMigration(db *gorm.DB, dir string) (*file.MigrationFile, bool, error) {
+	files, err := file.ListMigrationFiles(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(files) == 0 {
+		return nil, false, nil
+	}
+
+	latest := files[len(files)-1]
+	var count int64
+	if db.Migrator().HasTable(&migration.MigrationRecord{}) {
+		if err := db.Model(&migration.MigrationRecord{}).Where("version = ?", latest.Version).Count(&count).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to check migration %s: %v", latest.Version, err)
+		}
+	}
+
+	return latest, count > 0, nil
+}
+
+// noChanges reports an empty diff, which is only an error with --fail-on-empty
+func noChanges(failOnEmpty bool) error {
+	if failOnEmpty {
+		return generator.ErrNoChanges
+	}
+	fmt.Println("No schema changes detected")
+	return nil
+}
+
+// schemaChanges compares the registered models against the database. Tables
+// without a model are only dropped when pruneUnmanaged is set, and columns
+// are only renamed when detectRenames is set.
+func schemaChanges(db *gorm.DB, pruneUnmanaged, detectRenames bool) (*diff.SchemaDiff, error) {
+	parser, err := modelparser.NewModelParser(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model parser: %w", err)
+	}
+
+	modelSchemas, err := parser.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse models: %v", err)
+	}
+
+	if len(modelSchemas) == 0 {
+		return nil, fmt.Errorf("no GORM models found in registry")
+	}
+
+	comparer := diff.NewSchemaComparer(db)
+	comparer.SetTableRenames(activeConfig.RenameTables)
+	if activeConfig.RenameThreshold != nil {
+		comparer.SetRenameThreshold(*activeConfig.RenameThreshold)
+	}
+	comparer.SetDetectColumnRenames(detectRenames)
+	comparer.SetIndexRenames(activeConfig.RenameIndexes)
+	comparer.SetPruneUnmanaged(pruneUnmanaged)
+	var views []diff.MaterializedView
+	for _, view := range migration.GetRegisteredMaterializedViews() {
+		views = append(views, diff.MaterializedView{Name: view.Name, SQL: view.SQL})
+	}
+	comparer.SetMaterializedViews(views)
+	var enums []diff.Enum
+	for _, enum := range migration.GetRegisteredEnums() {
+		enums = append(enums, diff.Enum{Name: enum.Name, Values: enum.Values})
+	}
+	comparer.SetEnums(enums)
+	var referenceData []diff.ReferenceData
+	for _, data := range migration.GetRegisteredReferenceData() {
+		referenceData = append(referenceData, diff.ReferenceData{Table: data.Table, Rows: data.Rows})
+	}
+	comparer.SetReferenceData(referenceData)
+
+	currentSchema, err := comparer.GetCurrentSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current schema: %v", err)
+	}
+
+	// Tables in the config's ignore list are neither created, changed nor dropped
+	for table := range currentSchema {
+		if activeConfig.IsIgnored(table) {
+			delete(currentSchema, table)
+		}
+	}
+	for table := range modelSchemas {
+		if activeConfig.IsIgnored(table) {
+			delete(modelSchemas, table)
+		}
+	}
+
+	changes, err := comparer.CompareSchemas(currentSchema, modelSchemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare schemas: %v", err)
+	}
+
+	return changes, nil
+}
+
+// warnRemovedEnumValues reports the enum values the models no longer list,
+// which Postgres cannot remove, so they have to be dropped by hand
+func warnRemovedEnumValues(changes *diff.SchemaDiff) {
+	if changes == nil {
+		return
+	}
+	for _, enum := range changes.EnumsToAlter {
+		for _, value := range enum.Removed {
+			fmt.Printf("Warning: Postgres cannot remove value '%s' from enum type %s; recreate the type manually\n", value, enum.Name)
+		}
 	}
 }
 
 func hasChanges(changes *diff.SchemaDiff) bool {
-	if len(changes.TablesToCreate) > 0 || len(changes.TablesToDrop) > 0 || len(changes.TablesToRename) > 0 {
+	if len(changes.TablesToCreate) > 0 || len(changes.TablesToDrop) > 0 || len(changes.TablesToRename) > 0 || len(changes.ViewsToCreate) > 0 ||
+		len(changes.EnumsToCreate) > 0 || changes.AddsEnumValues() || len(changes.RowsToInsert) > 0 ||
+		len(changes.RowsToUpdate) > 0 {
 		return true
 	}
 
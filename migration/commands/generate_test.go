@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
+)
+
+func TestNoChangesFailOnEmpty(t *testing.T) {
+	require.NoError(t, noChanges(false))
+	require.Equal(t, ExitOK, ExitCode(noChanges(false)))
+
+	err := noChanges(true)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, generator.ErrNoChanges))
+	require.True(t, errors.Is(err, migration.ErrNoChanges))
+	require.NotEqual(t, ExitOK, ExitCode(err))
+}
+
+// widgetsDiff is a schema diff creating a widgets table
+func widgetsDiff(columns ...string) *diff.SchemaDiff {
+	fields := []*schema.Field{{DBName: "id", DataType: "uint", PrimaryKey: true}}
+	for _, column := range columns {
+		fields = append(fields, &schema.Field{DBName: column, DataType: "string"})
+	}
+	return &diff.SchemaDiff{
+		TablesToCreate: []diff.TableDiff{{Schema: &schema.Schema{Table: "widgets"}, FieldsToAdd: fields}},
+	}
+}
+
+func newMigrationsTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+	return db
+}
+
+func TestMergeRewritesLatestUnappliedMigration(t *testing.T) {
+	db := newMigrationsTestDB(t)
+
+	dir := t.TempDir()
+	latest := filepath.Join(dir, "20240102000000_add_widgets.go")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101000000_init.go"), []byte("package migrations\n"), 0644))
+	require.NoError(t, os.WriteFile(latest, []byte("package migrations\n"), 0644))
+	require.NoError(t, db.Create(&migration.MigrationRecord{Version: "20240101000000", Name: "init", AppliedAt: time.Now()}).Error)
+
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(widgetsDiff())
+	_, err := writeMigration(db, gen, "more_widgets", true, false)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "merging must not create a new migration file")
+
+	content, err := os.ReadFile(latest)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `Version:   "20240102000000"`)
+	require.Contains(t, string(content), `CREATE TABLE "widgets"`)
+
+	// Once applied, the latest migration can no longer be merged into
+	require.NoError(t, db.Create(&migration.MigrationRecord{Version: "20240102000000", Name: "add_widgets", AppliedAt: time.Now()}).Error)
+	_, err = writeMigration(db, gen, "more_widgets", true, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already been applied")
+}
+
+func TestGenerateTwiceWithoutApplyingWritesOneFile(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	dir := t.TempDir()
+
+	// Each migration gets its own version, so a duplicate would land in its
+	// own file
+	versions := []string{"20240101000000", "20240101000001", "20240101000002"}
+	t.Cleanup(func() { newVersion = generator.NewVersion })
+	newVersion = func() string {
+		version := versions[0]
+		versions = versions[1:]
+		return version
+	}
+
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(widgetsDiff("name"))
+	_, err := writeMigration(db, gen, "add_widgets", false, false)
+	require.NoError(t, err)
+
+	// Same diff again: the unapplied migration already covers it
+	written, err := writeMigration(db, gen, "add_widgets_again", false, false)
+	require.NoError(t, err)
+	require.Nil(t, written)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "an identical migration should not be written twice")
+
+	// A different diff still produces a new migration
+	gen.SetSchemaDiff(widgetsDiff("name", "color"))
+	_, err = writeMigration(db, gen, "add_widget_color", false, false)
+	require.NoError(t, err)
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
+
+func TestSQLPreviewLandsInOutputDir(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	migrationsDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	gen := generator.NewGenerator(migrationsDir)
+	gen.SetSchemaDiff(widgetsDiff("name"))
+	written, err := writeMigration(db, gen, "add_widgets", false, false)
+	require.NoError(t, err)
+	require.NotNil(t, written)
+	require.NoError(t, writeSQLPreview(gen, written, outputDir))
+
+	base := written.Version + "_add_widgets"
+	up, err := os.ReadFile(filepath.Join(outputDir, base+".up.sql"))
+	require.NoError(t, err)
+	require.Contains(t, string(up), `CREATE TABLE "widgets"`)
+	down, err := os.ReadFile(filepath.Join(outputDir, base+".down.sql"))
+	require.NoError(t, err)
+	require.Contains(t, string(down), `DROP TABLE IF EXISTS "widgets"`)
+
+	// Only the migration itself goes to the migrations directory
+	entries, err := os.ReadDir(migrationsDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, base+".go", entries[0].Name())
+}
+
+func TestSimulateMigration(t *testing.T) {
+	db := newMigrationsTestDB(t)
+	scratch, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	gen := generator.NewGenerator(t.TempDir())
+
+	// The applied migration is rebuilt in the scratch database; the pending
+	// one is not
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "create_gadgets", Up: func(db *gorm.DB) error {
+			return db.Exec(`CREATE TABLE "gadgets" (id integer)`).Error
+		}},
+		{Version: "20240102000000", Name: "create_gizmos", Up: func(db *gorm.DB) error {
+			return db.Exec(`CREATE TABLE "gizmos" (id integer)`).Error
+		}},
+	}
+	require.NoError(t, db.Create(&migration.MigrationRecord{Version: "20240101000000", Name: "create_gadgets", AppliedAt: time.Now()}).Error)
+
+	gen.SetSchemaDiff(widgetsDiff("name"))
+	require.NoError(t, simulateMigration(db, scratch, gen, migrations))
+	require.False(t, scratch.Migrator().HasTable("widgets"), "the simulation must be rolled back")
+	require.False(t, scratch.Migrator().HasTable("gadgets"), "the simulation must be rolled back")
+	require.False(t, db.Migrator().HasTable("widgets"), "the live database must not be touched")
+
+	addColumn := func(table string) *diff.SchemaDiff {
+		return &diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+			Schema:      &schema.Schema{Table: table},
+			FieldsToAdd: []*schema.Field{{DBName: "color", DataType: "string"}},
+		}}}
+	}
+	gen.SetSchemaDiff(addColumn("gadgets"))
+	require.NoError(t, simulateMigration(db, scratch, gen, migrations))
+
+	// A column added to a table the database does not have
+	gen.SetSchemaDiff(addColumn("gizmos"))
+	err = simulateMigration(db, scratch, gen, migrations)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `ALTER TABLE "gizmos" ADD COLUMN "color"`)
+
+	// A migration that must run outside a transaction is not simulated
+	gen.SetSchemaDiff(&diff.SchemaDiff{EnumsToAlter: []diff.EnumChange{{Name: "mood", Added: []string{"happy"}}}})
+	require.ErrorContains(t, simulateMigration(db, scratch, gen, migrations), "outside a transaction")
+
+	// MySQL commits DDL implicitly, so a migration for it is not simulated
+	gen.SetSchemaDiff(widgetsDiff("name"))
+	gen.SetDialect(generator.DialectMySQL)
+	require.ErrorContains(t, simulateMigration(db, scratch, gen, migrations), "MySQL")
+}
+
+func TestValidateDialect(t *testing.T) {
+	for _, dialect := range []string{"", "postgres", "mysql", "sqlite", "cockroach"} {
+		require.NoError(t, validateDialect(dialect))
+	}
+	require.ErrorContains(t, validateDialect("oracle"), `unknown dialect "oracle"`)
+}
+
+func TestPrintSQLWritesNoFile(t *testing.T) {
+	dir := t.TempDir()
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(widgetsDiff("name"))
+
+	var out bytes.Buffer
+	require.NoError(t, printSQL(&out, gen))
+	require.Contains(t, out.String(), "-- Up\n")
+	require.Contains(t, out.String(), `CREATE TABLE "widgets"`)
+	require.Contains(t, out.String(), "-- Down\n")
+	require.Contains(t, out.String(), `DROP TABLE IF EXISTS "widgets"`)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
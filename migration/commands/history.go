@@ -20,7 +20,7 @@ func HistoryCmd() *cobra.Command {
 			}
 
 			var records []migration.MigrationRecord
-			if err := db.Order("applied_at DESC").Find(&records).Error; err != nil {
+			if err := db.Order(migration.LatestFirst).Find(&records).Error; err != nil {
 				return fmt.Errorf("failed to get migration history: %v", err)
 			}
 
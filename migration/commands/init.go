@@ -28,7 +28,7 @@ func InitCmd() *cobra.Command {
 				return fmt.Errorf("failed to create migrations directory: %v", err)
 			}
 
-			if err := db.AutoMigrate(&migration.MigrationRecord{}); err != nil {
+			if err := migration.EnsureVersionTable(db); err != nil {
 				return fmt.Errorf("failed to create schema_migrations table: %v", err)
 			}
 
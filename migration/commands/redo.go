@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func RedoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Revert and re-apply the last migration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debug, _ := cmd.Flags().GetBool("debug")
+
+			db, err := getDB()
+			if err != nil {
+				return err
+			}
+
+			record, err := lastApplied(db)
+			if err != nil {
+				return err
+			}
+
+			loader, err := getMigrationLoader()
+			if err != nil {
+				return fmt.Errorf("failed to create migration loader: %v", err)
+			}
+
+			loader.SetDebug(debug)
+
+			migrations, err := loader.LoadMigrations()
+			if err != nil {
+				return fmt.Errorf("failed to load migrations: %v", err)
+			}
+
+			mr, err := appliedMigration(migrations, record)
+			if err != nil {
+				return err
+			}
+
+			return redoMigration(db, record, mr)
+		},
+	}
+
+	cmd.Flags().Bool("debug", false, "Enable debug output")
+
+	return cmd
+}
+
+// redoMigration runs a migration's Down, then its Up again, and records it
+// anew, all in one transaction unless the migration is NonTransactional
+func redoMigration(db *gorm.DB, record migration.MigrationRecord, mr *migration.Migration) error {
+	fmt.Printf("Redoing migration: %s (%s)\n", mr.Name, mr.Version)
+
+	redo := func(tx *gorm.DB) error {
+		if err := mr.Down(tx); err != nil {
+			return fmt.Errorf("failed to revert migration %s: %v", mr.Name, err)
+		}
+		if err := tx.Delete(&record).Error; err != nil {
+			return fmt.Errorf("failed to remove migration record: %v", err)
+		}
+		if err := mr.Up(tx); err != nil {
+			return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+		}
+		record.AppliedAt = time.Now()
+		if err := tx.Create(&record).Error; err != nil {
+			return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: fmt.Errorf("failed to record migration: %w", err)}
+		}
+		return nil
+	}
+
+	var err error
+	if mr.NonTransactional {
+		err = redo(db)
+	} else {
+		err = db.Transaction(redo)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully redid migration: %s\n", mr.Name)
+	return nil
+}
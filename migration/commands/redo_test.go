@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func TestRedoMigration(t *testing.T) {
+	db := newMigrationsTestDB(t)
+
+	var ups, downs int
+	mr := &migration.Migration{
+		Version: "20240101000000",
+		Name:    "add_widgets",
+		Up: func(db *gorm.DB) error {
+			ups++
+			return db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)").Error
+		},
+		Down: func(db *gorm.DB) error {
+			downs++
+			return db.Exec("DROP TABLE widgets").Error
+		},
+	}
+	require.NoError(t, applyMigration(db, mr, applyOptions{transactional: true}))
+
+	record, err := lastApplied(db)
+	require.NoError(t, err)
+	require.NoError(t, redoMigration(db, record, mr))
+	require.Equal(t, 2, ups)
+	require.Equal(t, 1, downs)
+	require.True(t, db.Migrator().HasTable("widgets"))
+
+	var records []migration.MigrationRecord
+	require.NoError(t, db.Find(&records).Error)
+	require.Len(t, records, 1)
+	require.Equal(t, mr.Version, records[0].Version)
+
+	// A failing Up leaves the migration applied as it was
+	mr.Up = func(db *gorm.DB) error {
+		return db.Exec("CREATE TABLE missing.widgets (id INTEGER PRIMARY KEY)").Error
+	}
+	require.Error(t, redoMigration(db, records[0], mr))
+	require.True(t, db.Migrator().HasTable("widgets"))
+	_, err = lastApplied(db)
+	require.NoError(t, err)
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
+)
+
+// errSquashRollback rolls back the scratch transaction once the schema has
+// been read
+var errSquashRollback = errors.New("squash rollback")
+
+func SquashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "squash [name]",
+		Short: "Combine a range of migrations into a single baseline migration",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			to, _ := cmd.Flags().GetString("to")
+			scratchURL, _ := cmd.Flags().GetString("scratch-url")
+			outputDir, _ := cmd.Flags().GetString("output-dir")
+
+			if scratchURL == "" {
+				scratchURL = os.Getenv("SQUASH_DATABASE_URL")
+			}
+			if scratchURL == "" {
+				return fmt.Errorf("squash needs an empty scratch database: pass --scratch-url or set SQUASH_DATABASE_URL")
+			}
+
+			loader, err := getMigrationLoader()
+			if err != nil {
+				return fmt.Errorf("failed to create migration loader: %v", err)
+			}
+			migrations, err := loader.LoadMigrations()
+			if err != nil {
+				return fmt.Errorf("failed to load migrations: %v", err)
+			}
+
+			scratch, err := gorm.Open(postgres.Open(scratchURL), &gorm.Config{})
+			if err != nil {
+				return fmt.Errorf("failed to open scratch database: %v", err)
+			}
+
+			if outputDir == "" {
+				outputDir = filepath.Join(getMigrationsDir(), "squashed")
+			} else if outputDir, err = validateMigrationsPath(outputDir); err != nil {
+				return fmt.Errorf("invalid output dir: %v", err)
+			}
+
+			gen := generator.NewGenerator(outputDir)
+			configureGenerator(gen)
+
+			return squash(scratch, gen, migrations, from, to, args[0])
+		},
+	}
+
+	cmd.Flags().String("from", "", "Version of the first migration to squash")
+	cmd.Flags().String("to", "", "Version of the last migration to squash")
+	cmd.Flags().String("scratch-url", "", "Empty database the migrations are applied to (default: SQUASH_DATABASE_URL)")
+	cmd.Flags().String("output-dir", "", "Directory for the squashed migration (default: squashed/ under the migrations directory)")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// squash writes a migration with version to that has the combined effect of
+// the migrations from through to. The originals are left in place.
+func squash(scratch *gorm.DB, gen *generator.Generator, migrations []*migration.Migration, from, to, name string) error {
+	squashed, err := squashRange(migrations, from, to)
+	if err != nil {
+		return err
+	}
+
+	changes, err := squashChanges(scratch, migrations, squashed)
+	if err != nil {
+		return err
+	}
+
+	versions := make([]string, len(squashed))
+	for i, mr := range squashed {
+		versions[i] = fmt.Sprintf("%s_%s", mr.Version, mr.Name)
+	}
+	gen.SetSchemaDiff(changes)
+	gen.SetComment(fmt.Sprintf("Squashes migrations %s through %s: %s.\n"+
+		"Once every database has applied them, replace those files with this one.", from, to, strings.Join(versions, ", ")))
+
+	if err := gen.WriteMigration(to, name); err != nil {
+		if errors.Is(err, generator.ErrNoChanges) {
+			return fmt.Errorf("migrations %s through %s make no schema changes to squash", from, to)
+		}
+		return fmt.Errorf("failed to generate migration: %v", err)
+	}
+
+	fmt.Printf("Squashed %d migrations into %s\n", len(squashed), filepath.Join(gen.MigrationsDir, fmt.Sprintf("%s_%s.go", to, name)))
+	return nil
+}
+
+// squashRange returns the migrations with versions from through to
+func squashRange(migrations []*migration.Migration, from, to string) ([]*migration.Migration, error) {
+	if findMigration(migrations, from) == nil {
+		return nil, fmt.Errorf("migration %s not found", from)
+	}
+	if findMigration(migrations, to) == nil {
+		return nil, fmt.Errorf("migration %s not found", to)
+	}
+	if from > to {
+		return nil, fmt.Errorf("--from %s is after --to %s", from, to)
+	}
+
+	var squashed []*migration.Migration
+	for _, mr := range migrations {
+		if mr.Version >= from && mr.Version <= to {
+			squashed = append(squashed, mr)
+		}
+	}
+	return squashed, nil
+}
+
+// squashChanges applies the migrations up to the end of the squashed range
+// to the scratch database inside a transaction that is rolled back, and
+// returns the schema changes the squashed range made
+func squashChanges(scratch *gorm.DB, migrations, squashed []*migration.Migration) (*diff.SchemaDiff, error) {
+	var changes *diff.SchemaDiff
+	err := scratch.Transaction(func(tx *gorm.DB) error {
+		comparer := diff.NewSchemaComparer(tx)
+		comparer.SetPruneUnmanaged(true)
+		tables, err := comparer.GetCurrentSchema()
+		if err != nil {
+			return fmt.Errorf("failed to read scratch database: %v", err)
+		}
+		if len(tables) > 0 {
+			return fmt.Errorf("scratch database is not empty")
+		}
+
+		first, last := squashed[0].Version, squashed[len(squashed)-1].Version
+		before := map[string]*schema.Schema{}
+		for _, mr := range migrations {
+			if mr.Version > last {
+				break
+			}
+			if mr.Version == first {
+				if before, err = comparer.GetCurrentSchema(); err != nil {
+					return fmt.Errorf("failed to read scratch database: %v", err)
+				}
+			}
+			if err := mr.Up(tx); err != nil {
+				return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+			}
+		}
+
+		after, err := comparer.GetCurrentSchema()
+		if err != nil {
+			return fmt.Errorf("failed to read scratch database: %v", err)
+		}
+		if changes, err = comparer.CompareSchemas(before, after); err != nil {
+			return fmt.Errorf("failed to compare schemas: %v", err)
+		}
+		return errSquashRollback
+	})
+	if !errors.Is(err, errSquashRollback) {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
+)
+
+func TestSquashCreateTableMigrations(t *testing.T) {
+	scratch, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scratch.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	exec := func(sql string) func(*gorm.DB) error {
+		return func(db *gorm.DB) error { return db.Exec(sql).Error }
+	}
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "create_users", Up: exec(`CREATE TABLE users (id integer PRIMARY KEY, email varchar(255) NOT NULL)`)},
+		{Version: "20240102000000", Name: "create_posts", Up: exec(`CREATE TABLE posts (id integer PRIMARY KEY, title text)`)},
+	}
+
+	dir := t.TempDir()
+	gen := generator.NewGenerator(dir)
+	require.NoError(t, squash(scratch, gen, migrations, "20240101000000", "20240102000000", "baseline"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "20240102000000_baseline.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "// Squashes migrations 20240101000000 through 20240102000000: 20240101000000_create_users, 20240102000000_create_posts.")
+	require.Contains(t, string(content), `CREATE TABLE "users"`)
+	require.Contains(t, string(content), `CREATE TABLE "posts"`)
+	require.Contains(t, string(content), `DROP TABLE IF EXISTS "users"`)
+
+	// The scratch database is left empty
+	require.False(t, scratch.Migrator().HasTable("users"))
+	require.False(t, scratch.Migrator().HasTable("posts"))
+}
@@ -2,8 +2,11 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 
 	"github.com/beesaferoot/gorm-migrate/migration"
 )
@@ -14,11 +17,7 @@ func StatusCmd() *cobra.Command {
 		Short: "Show status of all migrations",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			debug, _ := cmd.Flags().GetBool("debug")
-
-			db, err := getDB()
-			if err != nil {
-				return err
-			}
+			allShards, _ := cmd.Flags().GetBool("all-shards")
 
 			loader, err := getMigrationLoader()
 			if err != nil {
@@ -32,30 +31,114 @@ func StatusCmd() *cobra.Command {
 				return fmt.Errorf("failed to load migrations: %v", err)
 			}
 
-			var records []migration.MigrationRecord
-			if err := db.Find(&records).Error; err != nil {
-				return fmt.Errorf("failed to get applied migrations: %v", err)
-			}
-
-			appliedMap := make(map[string]bool)
-			for _, record := range records {
-				appliedMap[record.Version] = true
+			if allShards {
+				dsns := shardDSNs()
+				if len(dsns) == 0 {
+					return fmt.Errorf("--all-shards needs DATABASE_URLS, a comma-separated list of connection strings")
+				}
+				_, err := shardsStatus(dsns, openShard, migrations)
+				return err
 			}
 
-			fmt.Printf("%-16s  %-30s  %-8s\n", "Version", "Name", "Status")
-			for _, migration := range migrations {
-				status := "Pending"
-				if appliedMap[migration.Version] {
-					status = "Applied"
-				}
-				fmt.Printf("%-16s  %-30s  %-8s\n", migration.Version, migration.Name, status)
+			db, err := getDB()
+			if err != nil {
+				return err
 			}
 
-			return nil
+			_, err = printStatus(db, migrations)
+			return err
 		},
 	}
 
 	cmd.Flags().Bool("debug", false, "Enable debug output")
+	cmd.Flags().Bool("all-shards", false, "Show the status of every database in DATABASE_URLS")
 
 	return cmd
 }
+
+// printStatus prints whether each migration is applied to db and returns the
+// number of pending migrations
+func printStatus(db *gorm.DB, migrations []*migration.Migration) (int, error) {
+	var records []migration.MigrationRecord
+	if err := db.Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+
+	appliedMap := make(map[string]bool)
+	for _, record := range records {
+		appliedMap[record.Version] = true
+	}
+
+	pending := 0
+	fmt.Printf("%-16s  %-30s  %-8s\n", "Version", "Name", "Status")
+	for _, migration := range migrations {
+		status := "Pending"
+		if appliedMap[migration.Version] {
+			status = "Applied"
+		} else {
+			pending++
+		}
+		fmt.Printf("%-16s  %-30s  %-8s\n", migration.Version, migration.Name, status)
+	}
+
+	return pending, nil
+}
+
+// shardDSNs returns the connection strings in DATABASE_URLS
+func shardDSNs() []string {
+	var dsns []string
+	for _, dsn := range strings.Split(os.Getenv("DATABASE_URLS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
+
+// openShard opens a shard with the driver getDB uses for DATABASE_URL
+func openShard(dsn string) (*gorm.DB, error) {
+	return openDatabase(activeConfig.Dialect, dsn)
+}
+
+// shardsStatus prints the status of every shard and returns the shards with
+// pending migrations. Shards are numbered in DATABASE_URLS order so that
+// credentials in the connection strings are not printed.
+func shardsStatus(dsns []string, open func(string) (*gorm.DB, error), migrations []*migration.Migration) ([]string, error) {
+	var behind, failed []string
+	for i, dsn := range dsns {
+		shard := fmt.Sprintf("shard %d", i+1)
+		fmt.Printf("== %s ==\n", shard)
+
+		db, err := open(dsn)
+		if err != nil {
+			fmt.Printf("Error: failed to connect: %v\n\n", err)
+			failed = append(failed, shard)
+			continue
+		}
+
+		pending, err := printStatus(db, migrations)
+		closeDB(db)
+		if err != nil {
+			fmt.Printf("Error: %v\n\n", err)
+			failed = append(failed, shard)
+			continue
+		}
+		if pending > 0 {
+			fmt.Printf("BEHIND: %d pending migration(s)\n", pending)
+			behind = append(behind, shard)
+		}
+		fmt.Println()
+	}
+
+	switch {
+	case len(behind) > 0:
+		fmt.Printf("Shards behind: %s\n", strings.Join(behind, ", "))
+	case len(failed) == 0:
+		fmt.Printf("All %d shard(s) are up to date.\n", len(dsns))
+	}
+
+	if len(failed) > 0 {
+		return behind, fmt.Errorf("failed to read the status of %s", strings.Join(failed, ", "))
+	}
+	return behind, nil
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func TestShardDSNs(t *testing.T) {
+	t.Setenv("DATABASE_URLS", " postgres://one/db, ,postgres://two/db ")
+	require.Equal(t, []string{"postgres://one/db", "postgres://two/db"}, shardDSNs())
+}
+
+func TestShardsStatusFlagsShardsBehind(t *testing.T) {
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "create_widgets"},
+		{Version: "20240102000000", Name: "add_widget_name"},
+	}
+
+	shards := map[string]*gorm.DB{}
+	for _, dsn := range []string{"file:shard_one?mode=memory&cache=shared", "file:shard_two?mode=memory&cache=shared"} {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+		for _, mr := range migrations {
+			require.NoError(t, db.Create(&migration.MigrationRecord{Version: mr.Version, Name: mr.Name, AppliedAt: time.Now()}).Error)
+		}
+		shards[dsn] = db
+	}
+	// The second shard has not applied the latest migration yet
+	require.NoError(t, shards["file:shard_two?mode=memory&cache=shared"].Delete(&migration.MigrationRecord{Version: "20240102000000"}).Error)
+
+	// Each shard is opened again from its DSN, as the command does
+	var opened []string
+	var connections []*gorm.DB
+	open := func(dsn string) (*gorm.DB, error) {
+		opened = append(opened, dsn)
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		connections = append(connections, db)
+		return db, err
+	}
+
+	behind, err := shardsStatus([]string{"file:shard_one?mode=memory&cache=shared", "file:shard_two?mode=memory&cache=shared"}, open, migrations)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file:shard_one?mode=memory&cache=shared", "file:shard_two?mode=memory&cache=shared"}, opened)
+	require.Equal(t, []string{"shard 2"}, behind)
+
+	// Each shard's connection is closed once its status is printed
+	for _, db := range connections {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		require.Error(t, sqlDB.Ping())
+	}
+}
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 
 	"github.com/beesaferoot/gorm-migrate/migration"
 )
@@ -16,11 +20,16 @@ func UpCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dryRun, _ := cmd.Flags().GetBool("dry-run")
 			debug, _ := cmd.Flags().GetBool("debug")
-
-			db, err := getDB()
-			if err != nil {
-				return err
-			}
+			only, _ := cmd.Flags().GetString("only")
+			noTransaction, _ := cmd.Flags().GetBool("no-transaction")
+			allShards, _ := cmd.Flags().GetBool("all-shards")
+			continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+			timeout, _ := cmd.Flags().GetDuration("timeout-per-migration")
+			savepoints, _ := cmd.Flags().GetBool("savepoints")
+			tag, _ := cmd.Flags().GetString("tag")
+			stopOnError, _ := cmd.Flags().GetBool("stop-on-error")
+			to, _ := cmd.Flags().GetString("to")
+			opts := applyOptions{transactional: !noTransaction, timeout: timeout, skipFailed: !stopOnError}
 
 			loader, err := getMigrationLoader()
 			if err != nil {
@@ -28,78 +37,270 @@ func UpCmd() *cobra.Command {
 			}
 
 			loader.SetDebug(debug)
+			loader.SetSavepoints(savepoints)
 
 			migrations, err := loader.LoadMigrations()
 			if err != nil {
 				return fmt.Errorf("failed to load migrations: %v", err)
 			}
-
-			var records []migration.MigrationRecord
-			if err := db.Find(&records).Error; err != nil {
-				return fmt.Errorf("failed to get applied migrations: %v", err)
-			}
-
-			appliedMap := make(map[string]bool)
-			for _, record := range records {
-				appliedMap[record.Version] = true
-			}
-
-			var pending []*migration.Migration
-			for _, migration := range migrations {
-				if !appliedMap[migration.Version] {
-					pending = append(pending, migration)
+			if to != "" {
+				if only != "" {
+					return fmt.Errorf("--to cannot be combined with --only")
+				}
+				if migrations, err = migrationsUpTo(migrations, to); err != nil {
+					return err
 				}
 			}
-
-			if len(pending) == 0 {
-				fmt.Println("No pending migrations.")
-				return nil
+			if tag != "" {
+				migrations = migrationsWithTag(migrations, tag)
 			}
 
-			if dryRun {
-				fmt.Println("Pending migrations:")
-				for _, migration := range pending {
-					fmt.Printf("- %s (%s)\n", migration.Name, migration.Version)
+			if allShards {
+				if only != "" {
+					return fmt.Errorf("--only cannot be combined with --all-shards")
+				}
+				dsns := shardDSNs()
+				if len(dsns) == 0 {
+					return fmt.Errorf("--all-shards needs DATABASE_URLS, a comma-separated list of connection strings")
 				}
-				return nil
+				return upShards(dsns, openShard, migrations, dryRun, opts, continueOnError)
 			}
 
-			for _, mr := range pending {
-				fmt.Printf("Applying migration: %s (%s)\n", mr.Name, mr.Version)
-
-				tx := db.Begin()
-				if tx.Error != nil {
-					return fmt.Errorf("failed to start transaction: %v", tx.Error)
-				}
+			db, err := getDB()
+			if err != nil {
+				return err
+			}
 
-				if err := mr.Up(tx); err != nil {
-					tx.Rollback()
-					return fmt.Errorf("failed to apply migration %s: %v", mr.Name, err)
+			if only != "" {
+				pending, err := pendingMigrations(db, migrations)
+				if err != nil {
+					return err
 				}
-
-				record := migration.MigrationRecord{
-					Version:   mr.Version,
-					Name:      mr.Name,
-					AppliedAt: time.Now(),
+				mr := findMigration(migrations, only)
+				if mr == nil {
+					return fmt.Errorf("migration %s not found", only)
 				}
-				if err := tx.Create(&record).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("failed to record migration %s: %v", mr.Name, err)
+				if findMigration(pending, only) == nil {
+					return fmt.Errorf("migration %s is already applied", only)
 				}
-
-				if err := tx.Commit().Error; err != nil {
-					return fmt.Errorf("failed to commit transaction: %v", err)
+				fmt.Printf("Warning: --only applies migration %s regardless of pending order\n", only)
+				if dryRun {
+					fmt.Printf("Would apply migration: %s (%s)\n", mr.Name, mr.Version)
+					return nil
 				}
-
-				fmt.Printf("Successfully applied migration: %s\n", mr.Name)
+				return applyMigration(db, mr, opts)
 			}
 
-			return nil
+			return upPending(db, migrations, dryRun, opts)
 		},
 	}
 
 	cmd.Flags().Bool("dry-run", false, "Show pending migrations without executing them")
 	cmd.Flags().Bool("debug", false, "Enable debug output")
+	cmd.Flags().String("only", "", "Apply only the migration with this version (advanced)")
+	cmd.Flags().Bool("no-transaction", false, "Run migrations directly on the database without wrapping each in a transaction")
+	cmd.Flags().Bool("all-shards", false, "Apply pending migrations to every database in DATABASE_URLS")
+	cmd.Flags().Bool("continue-on-error", false, "With --all-shards, keep migrating the remaining shards after one fails")
+	cmd.Flags().Duration("timeout-per-migration", 0, "Roll back any single migration that runs longer than this (e.g. 30s); 0 means no limit")
+	cmd.Flags().String("to", "", "Apply pending migrations up to and including this version")
+	cmd.Flags().String("tag", "", "Apply only the pending migrations labelled with this tag")
+	cmd.Flags().Bool("savepoints", false, "Run each statement of a migration under its own savepoint, so a failing statement is rolled back on its own before the migration fails")
+	cmd.Flags().Bool("stop-on-error", true, "Stop at the first migration that fails; with --stop-on-error=false the failed migration is rolled back and the rest are still applied, though a migration run outside a transaction (--no-transaction or NonTransactional) still stops the run")
 
 	return cmd
 }
+
+// migrationsWithTag returns the migrations labelled with tag, in order
+func migrationsWithTag(migrations []*migration.Migration, tag string) []*migration.Migration {
+	var tagged []*migration.Migration
+	for _, mr := range migrations {
+		if mr.HasTag(tag) {
+			tagged = append(tagged, mr)
+		}
+	}
+	return tagged
+}
+
+// migrationsUpTo returns the migrations up to and including version to, in
+// order. The version must be one of the migrations.
+func migrationsUpTo(migrations []*migration.Migration, to string) ([]*migration.Migration, error) {
+	for i, mr := range migrations {
+		if mr.Version == to {
+			return migrations[:i+1], nil
+		}
+	}
+	return nil, fmt.Errorf("migration %s not found", to)
+}
+
+// pendingMigrations returns the migrations not yet applied to db
+func pendingMigrations(db *gorm.DB, migrations []*migration.Migration) ([]*migration.Migration, error) {
+	var records []migration.MigrationRecord
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+
+	appliedMap := make(map[string]bool)
+	for _, record := range records {
+		appliedMap[record.Version] = true
+	}
+
+	var pending []*migration.Migration
+	for _, migration := range migrations {
+		if !appliedMap[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// upPending applies the pending migrations to db in order, or lists them on
+// a dry run. The first failure stops the run unless opts.skipFailed is set
+// and the failed migration ran in a transaction; one that did not may have
+// left part of its changes behind.
+func upPending(db *gorm.DB, migrations []*migration.Migration, dryRun bool, opts applyOptions) error {
+	pending, err := pendingMigrations(db, migrations)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("No pending migrations.")
+		return nil
+	}
+
+	if dryRun {
+		fmt.Println("Pending migrations:")
+		for _, migration := range pending {
+			fmt.Printf("- %s (%s)\n", migration.Name, migration.Version)
+		}
+		return nil
+	}
+
+	var failed []string
+	for _, mr := range pending {
+		if err := applyMigration(db, mr, opts); err != nil {
+			if !opts.skipFailed || !opts.transactional || mr.NonTransactional {
+				return err
+			}
+			fmt.Printf("Error: %v\n", err)
+			failed = append(failed, mr.Version)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to apply migration(s) %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// upShards applies the pending migrations to every shard in turn. A failing
+// shard stops the run unless continueOnError is set, in which case the
+// remaining shards are still migrated and the failures reported at the end.
+func upShards(dsns []string, open func(string) (*gorm.DB, error), migrations []*migration.Migration, dryRun bool, opts applyOptions, continueOnError bool) error {
+	var failed []string
+	for i, dsn := range dsns {
+		shard := fmt.Sprintf("shard %d", i+1)
+		fmt.Printf("== %s ==\n", shard)
+
+		db, err := open(dsn)
+		if err == nil {
+			err = upPending(db, migrations, dryRun, opts)
+		}
+		closeDB(db)
+		if err != nil {
+			fmt.Printf("Error: %v\n\n", err)
+			if !continueOnError {
+				return fmt.Errorf("%s failed, remaining shards were not migrated: %w", shard, err)
+			}
+			failed = append(failed, shard)
+			continue
+		}
+		fmt.Println()
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to migrate %s", strings.Join(failed, ", "))
+	}
+	fmt.Printf("All %d shard(s) migrated.\n", len(dsns))
+	return nil
+}
+
+// applyOptions control how each migration is applied
+type applyOptions struct {
+	transactional bool          // run each migration in its own transaction
+	timeout       time.Duration // limit on a single migration, 0 for none
+	skipFailed    bool          // apply later migrations after a transactional one fails
+}
+
+// applyMigration runs a migration's Up and records it, in one transaction
+// unless opts.transactional is false or the migration is NonTransactional. A
+// migration running past opts.timeout is rolled back and not recorded.
+func applyMigration(db *gorm.DB, mr *migration.Migration, opts applyOptions) error {
+	fmt.Printf("Applying migration: %s (%s)\n", mr.Name, mr.Version)
+
+	ctx := context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+		db = db.WithContext(ctx)
+	}
+
+	record := migration.MigrationRecord{
+		Version:   mr.Version,
+		Name:      mr.Name,
+		AppliedAt: time.Now(),
+	}
+
+	if !opts.transactional || mr.NonTransactional {
+		if err := migrationTimeout(ctx, opts.timeout, mr.Up(db)); err != nil {
+			return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+		}
+		if err := db.Create(&record).Error; err != nil {
+			return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: fmt.Errorf("failed to record migration: %w", err)}
+		}
+		fmt.Printf("Successfully applied migration: %s\n", mr.Name)
+		return nil
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return fmt.Errorf("failed to start transaction: %v", tx.Error)
+	}
+
+	if err := migrationTimeout(ctx, opts.timeout, mr.Up(tx)); err != nil {
+		tx.Rollback()
+		return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+	}
+
+	if err := tx.Create(&record).Error; err != nil {
+		tx.Rollback()
+		return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: fmt.Errorf("failed to record migration: %w", err)}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	fmt.Printf("Successfully applied migration: %s\n", mr.Name)
+	return nil
+}
+
+// migrationTimeout reports a migration that ran past its deadline, even when
+// it returned without an error
+func migrationTimeout(ctx context.Context, timeout time.Duration, err error) error {
+	if ctxErr := ctx.Err(); errors.Is(ctxErr, context.DeadlineExceeded) {
+		return fmt.Errorf("exceeded --timeout-per-migration of %s: %w", timeout, ctxErr)
+	}
+	return err
+}
+
+// findMigration returns the migration with the given version, if any
+func findMigration(migrations []*migration.Migration, version string) *migration.Migration {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m
+		}
+	}
+	return nil
+}
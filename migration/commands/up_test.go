@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+// inTransaction reports whether db runs its statements inside a transaction
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(*sql.Tx)
+	return ok
+}
+
+func TestApplyMigrationTransactionWrapping(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		db := newMigrationsTestDB(t)
+
+		var wrapped bool
+		mr := &migration.Migration{
+			Version: "20240101000000",
+			Name:    "add_widgets",
+			Up: func(db *gorm.DB) error {
+				wrapped = inTransaction(db)
+				return db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)").Error
+			},
+		}
+
+		require.NoError(t, applyMigration(db, mr, applyOptions{transactional: transactional}))
+		require.Equal(t, transactional, wrapped)
+		require.True(t, db.Migrator().HasTable("widgets"))
+
+		var count int64
+		require.NoError(t, db.Model(&migration.MigrationRecord{}).Where("version = ?", mr.Version).Count(&count).Error)
+		require.Equal(t, int64(1), count)
+	}
+
+	// A NonTransactional migration is never wrapped
+	db := newMigrationsTestDB(t)
+	wrapped := true
+	mr := &migration.Migration{
+		Version:          "20240102000000",
+		Name:             "add_enum_value",
+		NonTransactional: true,
+		Up: func(db *gorm.DB) error {
+			wrapped = inTransaction(db)
+			return nil
+		},
+	}
+	require.NoError(t, applyMigration(db, mr, applyOptions{transactional: true}))
+	require.False(t, wrapped)
+}
+
+func TestUpShards(t *testing.T) {
+	dsns := []string{"file:up_shard_one?mode=memory&cache=shared", "file:up_shard_two?mode=memory&cache=shared"}
+	open := func(dsn string) (*gorm.DB, error) {
+		return gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	}
+	shards := make([]*gorm.DB, len(dsns))
+	for i, dsn := range dsns {
+		db, err := open(dsn)
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+		shards[i] = db
+	}
+
+	createTable := func(version, table string) *migration.Migration {
+		return &migration.Migration{
+			Version: version,
+			Name:    "create_" + table,
+			Up: func(db *gorm.DB) error {
+				return db.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error
+			},
+		}
+	}
+
+	// The first shard already has the first migration
+	widgets := createTable("20240101000000", "widgets")
+	require.NoError(t, applyMigration(shards[0], widgets, applyOptions{transactional: true}))
+
+	// Each shard's connection is closed after it is migrated, failing or not
+	var connections []*gorm.DB
+	openTracked := func(dsn string) (*gorm.DB, error) {
+		db, err := open(dsn)
+		connections = append(connections, db)
+		return db, err
+	}
+
+	migrations := []*migration.Migration{widgets, createTable("20240102000000", "gadgets")}
+	require.NoError(t, upShards(dsns, openTracked, migrations, false, applyOptions{transactional: true}, false))
+	for _, db := range shards {
+		require.True(t, db.Migrator().HasTable("widgets"))
+		require.True(t, db.Migrator().HasTable("gadgets"))
+	}
+
+	// gizmos already exists on the first shard, so migrating it fails there
+	require.NoError(t, shards[0].Exec("CREATE TABLE gizmos (id INTEGER PRIMARY KEY)").Error)
+	migrations = append(migrations, createTable("20240103000000", "gizmos"))
+
+	require.Error(t, upShards(dsns, openTracked, migrations, false, applyOptions{transactional: true}, false))
+	require.False(t, shards[1].Migrator().HasTable("gizmos"), "the run should stop at the failing shard")
+
+	require.Error(t, upShards(dsns, openTracked, migrations, false, applyOptions{transactional: true}, true))
+	require.True(t, shards[1].Migrator().HasTable("gizmos"), "--continue-on-error should migrate the remaining shards")
+
+	require.Len(t, connections, 5)
+	for _, db := range connections {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		require.Error(t, sqlDB.Ping())
+	}
+}
+
+func TestApplyMigrationTimeout(t *testing.T) {
+	// A cancelled transaction may close its connection, so use a file rather
+	// than a per-connection in-memory database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "timeout.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+
+	slow := &migration.Migration{
+		Version: "20240101000000",
+		Name:    "slow_backfill",
+		Up: func(db *gorm.DB) error {
+			if err := db.Exec("CREATE TABLE backfill (id INTEGER PRIMARY KEY)").Error; err != nil {
+				return err
+			}
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		},
+	}
+
+	err = applyMigration(db, slow, applyOptions{transactional: true, timeout: 20 * time.Millisecond})
+	var applyErr *migration.MigrationApplyError
+	require.True(t, errors.As(err, &applyErr), "got %v", err)
+	require.Equal(t, "slow_backfill", applyErr.Name)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.False(t, db.Migrator().HasTable("backfill"), "the timed out migration should be rolled back")
+	var count int64
+	require.NoError(t, db.Model(&migration.MigrationRecord{}).Count(&count).Error)
+	require.Zero(t, count)
+
+	require.NoError(t, applyMigration(db, slow, applyOptions{transactional: true, timeout: time.Second}))
+}
+
+func TestUpPendingWithTag(t *testing.T) {
+	db := newMigrationsTestDB(t)
+
+	create := func(table string) func(*gorm.DB) error {
+		return func(db *gorm.DB) error { return db.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error }
+	}
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "add_widgets", Up: create("widgets"), Tags: []string{"online", "safe"}},
+		{Version: "20240102000000", Name: "add_gadgets", Up: create("gadgets"), Tags: []string{"offline"}},
+		{Version: "20240103000000", Name: "add_gizmos", Up: create("gizmos"), Tags: []string{"online"}},
+	}
+
+	require.NoError(t, upPending(db, migrationsWithTag(migrations, "online"), false, applyOptions{transactional: true}))
+	require.True(t, db.Migrator().HasTable("widgets"))
+	require.False(t, db.Migrator().HasTable("gadgets"), "untagged migrations should stay pending")
+	require.True(t, db.Migrator().HasTable("gizmos"))
+
+	pending, err := pendingMigrations(db, migrations)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "add_gadgets", pending[0].Name)
+}
+
+func TestUpPendingRollsBackFailedMigration(t *testing.T) {
+	create := func(table string) func(*gorm.DB) error {
+		return func(db *gorm.DB) error { return db.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error }
+	}
+	migrations := []*migration.Migration{
+		{Version: "20240101000000", Name: "add_widgets", Up: func(db *gorm.DB) error {
+			if err := db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)").Error; err != nil {
+				return err
+			}
+			return db.Exec("ALTER TABLE missing ADD COLUMN name TEXT").Error
+		}},
+		{Version: "20240102000000", Name: "add_gadgets", Up: create("gadgets")},
+	}
+
+	// The second statement fails, so the first one is rolled back with it
+	db := newMigrationsTestDB(t)
+	require.Error(t, upPending(db, migrations, false, applyOptions{transactional: true}))
+	require.False(t, db.Migrator().HasTable("widgets"))
+	require.False(t, db.Migrator().HasTable("gadgets"), "the run should stop at the failed migration")
+
+	db = newMigrationsTestDB(t)
+	err := upPending(db, migrations, false, applyOptions{transactional: true, skipFailed: true})
+	require.ErrorContains(t, err, "20240101000000")
+	require.False(t, db.Migrator().HasTable("widgets"))
+	require.True(t, db.Migrator().HasTable("gadgets"), "--stop-on-error=false should apply the remaining migrations")
+
+	pending, err := pendingMigrations(db, migrations)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "add_widgets", pending[0].Name)
+
+	// A failure outside a transaction may leave changes behind, so it still
+	// stops the run
+	db = newMigrationsTestDB(t)
+	require.Error(t, upPending(db, migrations, false, applyOptions{skipFailed: true}))
+	require.False(t, db.Migrator().HasTable("gadgets"), "--stop-on-error=false has no effect with --no-transaction")
+}
+
+func TestUpAndDownToVersion(t *testing.T) {
+	db := newMigrationsTestDB(t)
+
+	migrate := func(version, table string) *migration.Migration {
+		return &migration.Migration{
+			Version: version,
+			Name:    "create_" + table,
+			Up:      func(db *gorm.DB) error { return db.Exec("CREATE TABLE " + table + " (id INTEGER PRIMARY KEY)").Error },
+			Down:    func(db *gorm.DB) error { return db.Exec("DROP TABLE " + table).Error },
+		}
+	}
+	migrations := []*migration.Migration{
+		migrate("20240101000000", "widgets"),
+		migrate("20240102000000", "gadgets"),
+		migrate("20240103000000", "gizmos"),
+	}
+
+	_, err := migrationsUpTo(migrations, "20240199000000")
+	require.ErrorContains(t, err, "not found")
+
+	upTo, err := migrationsUpTo(migrations, "20240102000000")
+	require.NoError(t, err)
+	require.NoError(t, upPending(db, upTo, false, applyOptions{transactional: true}))
+	require.True(t, db.Migrator().HasTable("widgets"))
+	require.True(t, db.Migrator().HasTable("gadgets"))
+	require.False(t, db.Migrator().HasTable("gizmos"), "migrations after the target should stay pending")
+
+	require.NoError(t, upPending(db, migrations, false, applyOptions{transactional: true}))
+	require.True(t, db.Migrator().HasTable("gizmos"))
+
+	// Down reverts the later migrations, latest first, and keeps the target
+	var reverted []string
+	check := func(mr *migration.Migration) error {
+		reverted = append(reverted, mr.Version)
+		return nil
+	}
+	require.ErrorContains(t, downTo(db, migrations, "20240199000000", check), "not found")
+	require.NoError(t, downTo(db, migrations, "20240101000000", check))
+	require.Equal(t, []string{"20240103000000", "20240102000000"}, reverted)
+	require.True(t, db.Migrator().HasTable("widgets"))
+	require.False(t, db.Migrator().HasTable("gadgets"))
+	require.False(t, db.Migrator().HasTable("gizmos"))
+
+	pending, err := pendingMigrations(db, migrations)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+}
@@ -11,19 +11,85 @@ import (
 	"go/token"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/beesaferoot/gorm-migrate/migration"
 	"github.com/beesaferoot/gorm-migrate/migration/file"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
 )
 
+// injectedDB, when set, is used by every command instead of opening a
+// connection from DATABASE_URL
+var injectedDB *gorm.DB
+
+// SetDB makes commands use an already opened database connection, which is
+// useful for tests and for embedding the commands in an application that
+// manages its own pool. Passing nil restores the DATABASE_URL behaviour.
+func SetDB(db *gorm.DB) {
+	injectedDB = db
+}
+
 func getDB() (*gorm.DB, error) {
+	if injectedDB != nil {
+		return injectedDB, nil
+	}
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		return nil, fmt.Errorf("DATABASE_URL not set in environment or .env file")
+		dsn = activeConfig.DatabaseURL
+	}
+	if dsn == "" {
+		return nil, migration.ErrNoDatabaseURL
+	}
+	return openDatabase(activeConfig.Dialect, dsn)
+}
+
+// openDatabase opens a connection string with the driver for dialect:
+// SQLite for sqlite and Postgres, which CockroachDB also speaks, for the rest
+func openDatabase(dialect, dsn string) (*gorm.DB, error) {
+	if dialect == generator.DialectSQLite {
+		return gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	}
 	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
 }
 
+// closeDB closes a connection a command opened itself. An injected
+// connection belongs to the caller and is left open.
+func closeDB(db *gorm.DB) {
+	if db == nil || db == injectedDB {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// replicaDSN returns the connection string of a read replica to introspect
+// instead of the primary, or "" when none is configured
+func replicaDSN() string {
+	if dsn := os.Getenv("REPLICA_DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	return activeConfig.ReplicaDatabaseURL
+}
+
+// getIntrospectionDB returns the connection used to read the current schema:
+// the read replica when one is configured, otherwise the primary, reusing
+// primary when it is already open. The replica is assumed to be
+// schema-equivalent to the primary.
+func getIntrospectionDB(primary *gorm.DB) (*gorm.DB, error) {
+	if injectedDB != nil {
+		return injectedDB, nil
+	}
+	if dsn := replicaDSN(); dsn != "" {
+		return openDatabase(activeConfig.Dialect, dsn)
+	}
+	if primary != nil {
+		return primary, nil
+	}
+	return getDB()
+}
+
 func validateMigrationsPath(path string) (string, error) {
 	cleanPath := filepath.Clean(path)
 
@@ -50,6 +116,9 @@ func validateMigrationsPath(path string) (string, error) {
 
 func getMigrationsDir() string {
 	dir := os.Getenv("MIGRATIONS_PATH")
+	if dir == "" {
+		dir = activeConfig.MigrationsPath
+	}
 	if dir == "" {
 		dir = "migrations"
 	}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration/config"
+)
+
+func TestReplicaDSNSelection(t *testing.T) {
+	previous := activeConfig
+	t.Cleanup(func() { activeConfig = previous })
+
+	t.Setenv("REPLICA_DATABASE_URL", "")
+	activeConfig = &config.Config{}
+	require.Empty(t, replicaDSN(), "no replica configured")
+
+	activeConfig = &config.Config{ReplicaDatabaseURL: "postgres://replica-from-config"}
+	require.Equal(t, "postgres://replica-from-config", replicaDSN())
+
+	t.Setenv("REPLICA_DATABASE_URL", "postgres://replica-from-env")
+	require.Equal(t, "postgres://replica-from-env", replicaDSN(), "environment takes precedence over config")
+}
+
+func TestIntrospectionDBFallsBackToPrimary(t *testing.T) {
+	previous := activeConfig
+	t.Cleanup(func() { activeConfig = previous })
+	activeConfig = &config.Config{}
+	t.Setenv("REPLICA_DATABASE_URL", "")
+
+	primary, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	db, err := getIntrospectionDB(primary)
+	require.NoError(t, err)
+	require.Same(t, primary, db, "without a replica the primary is introspected")
+
+	// An injected connection is used for everything, replica or not
+	t.Setenv("REPLICA_DATABASE_URL", "postgres://replica-from-env")
+	injected, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	SetDB(injected)
+	t.Cleanup(func() { SetDB(nil) })
+
+	db, err = getIntrospectionDB(primary)
+	require.NoError(t, err)
+	require.Same(t, injected, db)
+}
+
+func TestOpenShardUsesConfiguredDialect(t *testing.T) {
+	previous := activeConfig
+	t.Cleanup(func() { activeConfig = previous })
+	activeConfig = &config.Config{Dialect: "sqlite"}
+
+	db, err := openShard("file:open_shard?mode=memory&cache=shared")
+	require.NoError(t, err)
+	defer closeDB(db)
+	require.Equal(t, "sqlite", db.Name())
+}
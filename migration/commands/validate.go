@@ -1,28 +1,167 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
 )
 
+// errRoundTripRollback rolls back the scratch transaction once Down has been
+// checked
+var errRoundTripRollback = errors.New("round trip rollback")
+
 func ValidateCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "validate",
 		Short: "Validate all migrations",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			reversibility, _ := cmd.Flags().GetBool("reversibility")
+			roundTripCheck, _ := cmd.Flags().GetBool("round-trip")
+
 			loader, err := getMigrationLoader()
 			if err != nil {
 				return fmt.Errorf("failed to create migration loader: %v", err)
 			}
 
-			_, err = loader.LoadMigrations()
+			migrations, err := loader.LoadMigrations()
 			if err != nil {
 				return fmt.Errorf("validation failed: %v", err)
 			}
 
-			fmt.Println("All migrations are valid")
+			if reversibility {
+				warnings, err := loader.LintReversibility()
+				if err != nil {
+					return fmt.Errorf("validation failed: %v", err)
+				}
+				for _, warning := range warnings {
+					fmt.Fprintf(cmd.OutOrStdout(), "Warning: %s\n", warning)
+				}
+			}
+
+			if roundTripCheck {
+				dir, err := os.MkdirTemp("", "gorm-migrate-round-trip")
+				if err != nil {
+					return fmt.Errorf("failed to create scratch database: %v", err)
+				}
+				defer os.RemoveAll(dir)
+
+				scratch, err := gorm.Open(sqlite.Open(filepath.Join(dir, "scratch.db")), &gorm.Config{})
+				if err != nil {
+					return fmt.Errorf("failed to open scratch database: %v", err)
+				}
+				failures, err := roundTrip(scratch, migrations)
+				if err != nil {
+					return fmt.Errorf("validation failed: %v", err)
+				}
+				for _, failure := range failures {
+					fmt.Fprintf(cmd.OutOrStdout(), "Not reversible: %s\n", failure)
+				}
+				if len(failures) > 0 {
+					return fmt.Errorf("validation failed: %d migration(s) are not reversible", len(failures))
+				}
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "All migrations are valid")
 			return nil
 		},
 	}
+
+	cmd.Flags().Bool("reversibility", false, "Warn about DROP and ADD COLUMN statements in Up that Down does not undo")
+	cmd.Flags().Bool("round-trip", false, "Apply each migration's Up then Down to a scratch SQLite database and fail if the schema is not restored")
+
+	return cmd
+}
+
+// roundTrip applies each migration's Up and then its Down to the scratch
+// database and describes the migrations whose Down does not restore the
+// schema Up started from. The round trip runs in a transaction that is rolled
+// back, then Up is applied for good so the next migration runs against the
+// schema it expects.
+func roundTrip(scratch *gorm.DB, migrations []*migration.Migration) ([]string, error) {
+	var failures []string
+	for _, mr := range migrations {
+		if mr.Down == nil {
+			failures = append(failures, fmt.Sprintf("%s_%s: has no Down", mr.Version, mr.Name))
+		} else {
+			changes, err := roundTripChanges(scratch, mr)
+			if err != nil {
+				return nil, err
+			}
+			if hasChanges(changes) {
+				changed := "the schema"
+				if tables := changedTables(changes); len(tables) > 0 {
+					changed = strings.Join(tables, ", ")
+				}
+				failures = append(failures, fmt.Sprintf("%s_%s: Down leaves changes to %s", mr.Version, mr.Name, changed))
+			}
+		}
+
+		if err := mr.Up(scratch); err != nil {
+			return nil, &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+		}
+	}
+	return failures, nil
+}
+
+// roundTripChanges returns the schema changes left behind by applying a
+// migration's Up and then its Down, inside a transaction that is rolled back
+func roundTripChanges(scratch *gorm.DB, mr *migration.Migration) (*diff.SchemaDiff, error) {
+	var changes *diff.SchemaDiff
+	err := scratch.Transaction(func(tx *gorm.DB) error {
+		comparer := diff.NewSchemaComparer(tx)
+		comparer.SetPruneUnmanaged(true)
+
+		before, err := comparer.GetCurrentSchema()
+		if err != nil {
+			return fmt.Errorf("failed to read scratch database: %v", err)
+		}
+		if err := mr.Up(tx); err != nil {
+			return &migration.MigrationApplyError{Version: mr.Version, Name: mr.Name, Err: err}
+		}
+		if err := mr.Down(tx); err != nil {
+			return fmt.Errorf("failed to roll back migration %s_%s: %v", mr.Version, mr.Name, err)
+		}
+
+		after, err := comparer.GetCurrentSchema()
+		if err != nil {
+			return fmt.Errorf("failed to read scratch database: %v", err)
+		}
+		if changes, err = comparer.CompareSchemas(before, after); err != nil {
+			return fmt.Errorf("failed to compare schemas: %v", err)
+		}
+		return errRoundTripRollback
+	})
+	if !errors.Is(err, errRoundTripRollback) {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// changedTables returns the sorted names of the tables a schema diff touches
+func changedTables(changes *diff.SchemaDiff) []string {
+	var tables []string
+	for _, table := range changes.TablesToCreate {
+		tables = append(tables, table.Schema.Table)
+	}
+	tables = append(tables, changes.TablesToDrop...)
+	for _, rename := range changes.TablesToRename {
+		tables = append(tables, rename.OldName)
+	}
+	for _, table := range changes.TablesToModify {
+		if !table.IsEmpty() {
+			tables = append(tables, table.Schema.Table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
 }
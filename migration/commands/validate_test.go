@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
+)
+
+func TestRoundTripFlagsIrreversibleMigration(t *testing.T) {
+	scratch, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scratch.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	exec := func(sql string) func(*gorm.DB) error {
+		return func(db *gorm.DB) error { return db.Exec(sql).Error }
+	}
+	migrations := []*migration.Migration{
+		{
+			Version: "20240101000000", Name: "create_widgets",
+			Up:   exec(`CREATE TABLE widgets (id integer PRIMARY KEY, name text)`),
+			Down: exec(`DROP TABLE widgets`),
+		},
+		{
+			Version: "20240102000000", Name: "add_widget_color",
+			Up:   exec(`ALTER TABLE widgets ADD COLUMN color text`),
+			Down: func(db *gorm.DB) error { return nil }, // forgets to drop color
+		},
+		{
+			Version: "20240103000000", Name: "add_widget_size",
+			Up:   exec(`ALTER TABLE widgets ADD COLUMN size integer`),
+			Down: exec(`ALTER TABLE widgets DROP COLUMN size`),
+		},
+	}
+
+	failures, err := roundTrip(scratch, migrations)
+	require.NoError(t, err)
+	require.Equal(t, []string{"20240102000000_add_widget_color: Down leaves changes to widgets"}, failures)
+}
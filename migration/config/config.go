@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file looked up in the working directory when no
+// explicit path is given
+const DefaultFile = "gorm-migrate.yaml"
+
+// Config holds the settings that can be provided through a config file.
+// Environment variables take precedence over the values set here.
+type Config struct {
+	DatabaseURL        string            `yaml:"database_url"`
+	ReplicaDatabaseURL string            `yaml:"replica_database_url"`
+	MigrationsPath     string            `yaml:"migrations_path"`
+	Dialect            string            `yaml:"dialect"`
+	IgnoreTables       []string          `yaml:"ignore_tables"`
+	RenameTables       map[string]string `yaml:"rename_tables"`
+	RenameThreshold    *float64          `yaml:"rename_threshold"` // defaults to 0, no detection
+	RenameIndexes      map[string]string `yaml:"rename_indexes"`
+	MySQLCharset       string            `yaml:"mysql_charset"`
+	MySQLCollation     string            `yaml:"mysql_collation"`
+	TypeOverrides      map[string]string `yaml:"type_overrides"`
+	Preamble           []string          `yaml:"preamble"`
+	Postamble          []string          `yaml:"postamble"`
+	QuoteIdentifiers   *bool             `yaml:"quote_identifiers"` // defaults to true
+	DefaultOnDelete    string            `yaml:"default_on_delete"` // defaults to CASCADE
+}
+
+// Load reads the config file at path. The file must exist and be readable.
+func Load(path string) (*Config, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("config file %s is a directory", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// LoadDefault reads DefaultFile from the working directory, returning an
+// empty config when the file does not exist
+func LoadDefault() (*Config, error) {
+	if _, err := os.Stat(DefaultFile); os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	return Load(DefaultFile)
+}
+
+// IsIgnored reports whether a table is listed in IgnoreTables
+func (c *Config) IsIgnored(table string) bool {
+	for _, ignored := range c.IgnoreTables {
+		if ignored == table {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,13 @@ type Migrator interface {
 	GetTables() ([]string, error)
 	GetIndexes(tableName string) ([]*schema.Index, error)
 	GetRelationships(tableName string) ([]*schema.Relationship, error)
+	GetChecks(tableName string) ([]*schema.CheckConstraint, error)
+	GetUniqueConstraints(tableName string) ([]UniqueConstraint, error)
+	GetTableComment(tableName string) (string, error)
+	GetIdentityColumns(tableName string) (map[string]bool, error)
+	GetSequenceDefaults(tableName string) (map[string]string, error)
+	GetMaterializedViews() (map[string]bool, error)
+	GetEnums() (map[string][]string, error)
 }
 
 type SchemaMigrator struct {
@@ -45,6 +52,10 @@ func (m *SchemaMigrator) GetIndexes(tableName string) ([]*schema.Index, error) {
 		return []*schema.Index{}, nil
 	}
 
+	if m.db.Name() == "sqlite" {
+		return m.sqliteIndexes(tableName)
+	}
+
 	if m.db.Name() != "postgres" {
 		return []*schema.Index{}, nil
 	}
@@ -57,14 +68,15 @@ func (m *SchemaMigrator) GetIndexes(tableName string) ([]*schema.Index, error) {
 		i.indexname,
 		ix.indisunique,
 		ix.indisprimary,
-		array_to_string(array_agg(a.attname ORDER BY t.ordinality), ',') as column_names
+		array_to_string(array_agg(a.attname ORDER BY t.ordinality), ',') as column_names,
+		COALESCE(pg_get_expr(ix.indpred, ix.indrelid), '') as predicate
 	FROM pg_indexes i
 	JOIN pg_class c ON c.relname = i.tablename
 	JOIN pg_index ix ON ix.indexrelid = (i.schemaname||'.'||i.indexname)::regclass
 	JOIN unnest(ix.indkey) WITH ORDINALITY t(attnum, ordinality) ON true
 	JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = t.attnum
 		WHERE i.tablename = $1
-		GROUP BY i.indexname, i.indexdef, ix.indisunique, ix.indisprimary;
+		GROUP BY i.indexname, i.indexdef, ix.indisunique, ix.indisprimary, ix.indpred, ix.indrelid;
 	`
 
 	rows, err := m.db.Raw(query, tableName).Rows()
@@ -74,21 +86,23 @@ func (m *SchemaMigrator) GetIndexes(tableName string) ([]*schema.Index, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var indexName, columnNames string
+		var indexName, columnNames, predicate string
 		var isUnique, isPrimaryKey bool
 
-		if err := rows.Scan(&indexName, &isUnique, &isPrimaryKey, &columnNames); err != nil {
+		if err := rows.Scan(&indexName, &isUnique, &isPrimaryKey, &columnNames, &predicate); err != nil {
 			return nil, fmt.Errorf("failed to scan index row: %w", err)
 		}
 
-		// Parse column names
+		// Parse column names, keeping the key position as the priority so
+		// composite indexes line up with the model's declared order
 		columns := strings.Split(columnNames, ",")
 		var fields []schema.IndexOption
 		for _, col := range columns {
 			col = strings.TrimSpace(col)
 			if col != "" {
 				fields = append(fields, schema.IndexOption{
-					Field: &schema.Field{DBName: col},
+					Field:    &schema.Field{DBName: col},
+					Priority: len(fields) + 1,
 				})
 			}
 		}
@@ -98,6 +112,7 @@ func (m *SchemaMigrator) GetIndexes(tableName string) ([]*schema.Index, error) {
 			Name:   indexName,
 			Type:   "BTREE", // PostgreSQL default index type
 			Fields: fields,
+			Where:  predicate,
 			Option: func() string {
 				if isPrimaryKey {
 					return "PRIMARY KEY"
@@ -125,6 +140,10 @@ func (m *SchemaMigrator) GetRelationships(tableName string) ([]*schema.Relations
 		return []*schema.Relationship{}, nil
 	}
 
+	if m.db.Name() == "sqlite" {
+		return m.sqliteRelationships(tableName)
+	}
+
 	if m.db.Name() != "postgres" {
 		return []*schema.Relationship{}, nil
 	}
@@ -182,6 +201,9 @@ func (m *SchemaMigrator) GetRelationships(tableName string) ([]*schema.Relations
 				Schema: &schema.Schema{
 					Table: tableName,
 				},
+				TagSettings: map[string]string{
+					"CONSTRAINT": fmt.Sprintf("OnDelete:%s,OnUpdate:%s", onDelete, onUpdate),
+				},
 			},
 			Schema: &schema.Schema{
 				Table: referencedTableName,
@@ -203,3 +225,240 @@ func (m *SchemaMigrator) GetRelationships(tableName string) ([]*schema.Relations
 
 	return relationships, nil
 }
+
+func (m *SchemaMigrator) GetChecks(tableName string) ([]*schema.CheckConstraint, error) {
+	// Handle empty table name
+	if tableName == "" {
+		return []*schema.CheckConstraint{}, nil
+	}
+
+	if m.db == nil {
+		return []*schema.CheckConstraint{}, nil
+	}
+
+	if m.db.Name() != "postgres" {
+		return []*schema.CheckConstraint{}, nil
+	}
+
+	var checks []*schema.CheckConstraint
+
+	// Query to get check constraints from PostgreSQL system catalogs
+	query := `
+	SELECT
+		con.conname,
+		pg_get_constraintdef(con.oid) AS definition
+	FROM pg_constraint con
+	JOIN pg_class rel ON rel.oid = con.conrelid
+	WHERE con.contype = 'c'
+		AND rel.relname = $1
+	ORDER BY con.conname;
+	`
+
+	rows, err := m.db.Raw(query, tableName).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get check constraints for table %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return nil, fmt.Errorf("failed to scan check constraint row: %w", err)
+		}
+
+		checks = append(checks, &schema.CheckConstraint{
+			Name:       name,
+			Constraint: checkExpression(definition),
+		})
+	}
+
+	return checks, nil
+}
+
+func (m *SchemaMigrator) GetUniqueConstraints(tableName string) ([]UniqueConstraint, error) {
+	// Handle empty table name
+	if tableName == "" {
+		return []UniqueConstraint{}, nil
+	}
+
+	if m.db == nil {
+		return []UniqueConstraint{}, nil
+	}
+
+	if m.db.Name() != "postgres" {
+		return []UniqueConstraint{}, nil
+	}
+
+	var uniques []UniqueConstraint
+
+	// Query to get unique constraints and their columns in key order
+	query := `
+	SELECT
+		con.conname,
+		array_to_string(array_agg(a.attname ORDER BY k.ordinality), ',') AS column_names
+	FROM pg_constraint con
+	JOIN pg_class rel ON rel.oid = con.conrelid
+	JOIN unnest(con.conkey) WITH ORDINALITY k(attnum, ordinality) ON true
+	JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+	WHERE con.contype = 'u'
+		AND rel.relname = $1
+	GROUP BY con.conname
+	ORDER BY con.conname;
+	`
+
+	rows, err := m.db.Raw(query, tableName).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unique constraints for table %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, columnNames string
+		if err := rows.Scan(&name, &columnNames); err != nil {
+			return nil, fmt.Errorf("failed to scan unique constraint row: %w", err)
+		}
+
+		uniques = append(uniques, UniqueConstraint{
+			Name:    name,
+			Columns: strings.Split(columnNames, ","),
+		})
+	}
+
+	return uniques, nil
+}
+
+func (m *SchemaMigrator) GetTableComment(tableName string) (string, error) {
+	if tableName == "" || m.db == nil || m.db.Name() != "postgres" {
+		return "", nil
+	}
+
+	var comment string
+	query := `SELECT COALESCE(obj_description(to_regclass($1), 'pg_class'), '')`
+	if err := m.db.Raw(query, tableName).Scan(&comment).Error; err != nil {
+		return "", fmt.Errorf("failed to get comment for table %s: %w", tableName, err)
+	}
+
+	return comment, nil
+}
+
+func (m *SchemaMigrator) GetIdentityColumns(tableName string) (map[string]bool, error) {
+	columns := make(map[string]bool)
+	if tableName == "" || m.db == nil || m.db.Name() != "postgres" {
+		return columns, nil
+	}
+
+	var names []string
+	query := `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND is_identity = 'YES'`
+	if err := m.db.Raw(query, tableName).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to get identity columns for table %s: %w", tableName, err)
+	}
+	for _, name := range names {
+		columns[name] = true
+	}
+
+	return columns, nil
+}
+
+// GetSequenceDefaults returns the nextval() defaults of a table's columns by
+// column name. The driver reports these columns as auto-increment without
+// their default, which loses the sequence of columns other than the key.
+func (m *SchemaMigrator) GetSequenceDefaults(tableName string) (map[string]string, error) {
+	defaults := make(map[string]string)
+	if tableName == "" || m.db == nil || m.db.Name() != "postgres" {
+		return defaults, nil
+	}
+
+	var columns []struct {
+		ColumnName    string
+		ColumnDefault string
+	}
+	query := `SELECT column_name, column_default FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND column_default LIKE 'nextval(%'`
+	if err := m.db.Raw(query, tableName).Scan(&columns).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sequence defaults for table %s: %w", tableName, err)
+	}
+	for _, column := range columns {
+		defaults[column.ColumnName] = column.ColumnDefault
+	}
+
+	return defaults, nil
+}
+
+func (m *SchemaMigrator) GetMaterializedViews() (map[string]bool, error) {
+	views := make(map[string]bool)
+	if m.db == nil || m.db.Name() != "postgres" {
+		return views, nil
+	}
+
+	var names []string
+	query := `SELECT matviewname FROM pg_matviews WHERE schemaname = current_schema()`
+	if err := m.db.Raw(query).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to get materialized views: %w", err)
+	}
+	for _, name := range names {
+		views[strings.ToLower(name)] = true
+	}
+
+	return views, nil
+}
+
+// GetEnums returns the values of each enum type in the current schema, in
+// their sort order
+func (m *SchemaMigrator) GetEnums() (map[string][]string, error) {
+	enums := make(map[string][]string)
+	if m.db == nil || m.db.Name() != "postgres" {
+		return enums, nil
+	}
+
+	var rows []struct {
+		TypeName  string
+		EnumLabel string
+	}
+	query := `SELECT t.typname AS type_name, e.enumlabel AS enum_label
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = current_schema()
+		ORDER BY t.typname, e.enumsortorder`
+	if err := m.db.Raw(query).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get enum types: %w", err)
+	}
+	for _, row := range rows {
+		name := strings.ToLower(row.TypeName)
+		enums[name] = append(enums[name], row.EnumLabel)
+	}
+
+	return enums, nil
+}
+
+// checkExpression strips the CHECK keyword and the outer parentheses that
+// pg_get_constraintdef wraps around a check expression
+func checkExpression(definition string) string {
+	expr := strings.TrimSpace(definition)
+	expr = strings.TrimSuffix(expr, " NOT VALID")
+	if strings.HasPrefix(strings.ToUpper(expr), "CHECK") {
+		expr = strings.TrimSpace(expr[len("CHECK"):])
+	}
+	for strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") && balancedParens(expr[1:len(expr)-1]) {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// balancedParens reports whether the parentheses in s are balanced
+func balancedParens(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// sqliteConstraintFK matches a named foreign key in a SQLite CREATE TABLE
+// statement, capturing the constraint name and its columns
+var sqliteConstraintFK = regexp.MustCompile("(?i)CONSTRAINT\\s+[`\"]?(\\w+)[`\"]?\\s+FOREIGN\\s+KEY\\s*\\(([^)]*)\\)")
+
+// sqliteIndexes reads the indexes of a SQLite table from PRAGMA index_list
+// and index_info. Indexes SQLite creates for PRIMARY KEY and UNIQUE column
+// constraints are left out, as they are compared with their columns.
+func (m *SchemaMigrator) sqliteIndexes(tableName string) ([]*schema.Index, error) {
+	type indexRow struct {
+		Name    string
+		Unique  bool
+		Partial bool
+	}
+	var rows []indexRow
+	query := `SELECT name, "unique", partial FROM pragma_index_list(?) WHERE origin = 'c' ORDER BY name`
+	if err := m.db.Raw(query, tableName).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get indexes for table %s: %w", tableName, err)
+	}
+
+	var indexes []*schema.Index
+	for _, row := range rows {
+		var columns []string
+		if err := m.db.Raw(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, row.Name).Scan(&columns).Error; err != nil {
+			return nil, fmt.Errorf("failed to get columns of index %s: %w", row.Name, err)
+		}
+
+		var fields []schema.IndexOption
+		for _, col := range columns {
+			fields = append(fields, schema.IndexOption{
+				Field:    &schema.Field{DBName: col},
+				Priority: len(fields) + 1,
+			})
+		}
+
+		index := &schema.Index{
+			Name:   row.Name,
+			Fields: fields,
+		}
+		if row.Unique {
+			index.Option = "UNIQUE"
+		}
+		if row.Partial {
+			var definition string
+			if err := m.db.Raw(`SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?`, row.Name).Scan(&definition).Error; err != nil {
+				return nil, fmt.Errorf("failed to get definition of index %s: %w", row.Name, err)
+			}
+			if i := strings.Index(strings.ToUpper(definition), " WHERE "); i >= 0 {
+				index.Where = strings.TrimSpace(definition[i+len(" WHERE "):])
+			}
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// sqliteRelationships reads the foreign keys of a SQLite table from PRAGMA
+// foreign_key_list, one relationship per column. SQLite does not report
+// constraint names, so they are taken from the table's CREATE statement.
+func (m *SchemaMigrator) sqliteRelationships(tableName string) ([]*schema.Relationship, error) {
+	type foreignKeyRow struct {
+		Table    string
+		From     string
+		To       string
+		OnUpdate string
+		OnDelete string
+	}
+	var rows []foreignKeyRow
+	query := `SELECT "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`
+	if err := m.db.Raw(query, tableName).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get relationships for table %s: %w", tableName, err)
+	}
+	if len(rows) == 0 {
+		return []*schema.Relationship{}, nil
+	}
+
+	var definition string
+	if err := m.db.Raw(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, tableName).Scan(&definition).Error; err != nil {
+		return nil, fmt.Errorf("failed to get definition of table %s: %w", tableName, err)
+	}
+	names := make(map[string]string)
+	for _, match := range sqliteConstraintFK.FindAllStringSubmatch(definition, -1) {
+		for _, column := range strings.Split(match[2], ",") {
+			names[strings.Trim(strings.TrimSpace(column), "`\"")] = match[1]
+		}
+	}
+
+	var relationships []*schema.Relationship
+	for _, row := range rows {
+		// A reference without a column is to the primary key
+		referencedColumn := row.To
+		if referencedColumn == "" {
+			referencedColumn = "id"
+		}
+
+		relationships = append(relationships, &schema.Relationship{
+			Name: names[row.From],
+			Type: schema.BelongsTo,
+			Field: &schema.Field{
+				DBName: row.From,
+				Schema: &schema.Schema{
+					Table: tableName,
+				},
+				TagSettings: map[string]string{
+					"CONSTRAINT": fmt.Sprintf("OnDelete:%s,OnUpdate:%s", row.OnDelete, row.OnUpdate),
+				},
+			},
+			Schema: &schema.Schema{
+				Table: row.Table,
+			},
+			References: []*schema.Reference{
+				{
+					ForeignKey: &schema.Field{
+						DBName: row.From,
+					},
+					PrimaryKey: &schema.Field{
+						DBName: referencedColumn,
+					},
+				},
+			},
+		})
+	}
+
+	return relationships, nil
+}
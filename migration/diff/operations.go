@@ -0,0 +1,138 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// Operations describes each change of the diff in plain English, one line
+// per operation, in the order the generator applies them
+func (d *SchemaDiff) Operations() []string {
+	var ops []string
+
+	for _, enum := range d.EnumsToCreate {
+		ops = append(ops, fmt.Sprintf("create enum %s (%s)", enum.Name, strings.Join(enum.Values, ", ")))
+	}
+	for _, enum := range d.EnumsToAlter {
+		for _, value := range enum.Added {
+			ops = append(ops, fmt.Sprintf("add value %s to enum %s", value, enum.Name))
+		}
+		for _, value := range enum.Removed {
+			ops = append(ops, fmt.Sprintf("remove value %s from enum %s", value, enum.Name))
+		}
+	}
+	for _, rename := range d.TablesToRename {
+		ops = append(ops, fmt.Sprintf("rename table %s to %s", rename.OldName, rename.NewName))
+	}
+	for _, table := range d.TablesToCreate {
+		ops = append(ops, "create table "+table.Schema.Table)
+		for _, fk := range table.ForeignKeysToAdd {
+			ops = append(ops, "add "+foreignKeyDescription(table.Schema.Table, fk))
+		}
+	}
+	for _, table := range d.TablesToModify {
+		ops = append(ops, table.Operations()...)
+	}
+	for _, table := range d.TablesToDrop {
+		ops = append(ops, "drop table "+table)
+	}
+	for _, view := range d.ViewsToCreate {
+		ops = append(ops, "create materialized view "+view.Name)
+	}
+	for _, data := range d.RowsToInsert {
+		ops = append(ops, fmt.Sprintf("insert %d row(s) into %s", len(data.Rows), data.Table))
+	}
+	for _, update := range d.RowsToUpdate {
+		columns := make([]string, 0, len(update.Values))
+		for column := range update.Values {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		ops = append(ops, fmt.Sprintf("update %s of a row in %s", strings.Join(columns, ", "), update.Table))
+	}
+
+	return ops
+}
+
+// Operations describes each change of the table in plain English
+func (d *TableDiff) Operations() []string {
+	table := d.Schema.Table
+	var ops []string
+
+	for _, rename := range d.FieldsToRename {
+		ops = append(ops, fmt.Sprintf("rename column %s.%s to %s", table, rename.OldName, rename.NewName))
+	}
+	for _, field := range d.FieldsToAdd {
+		ops = append(ops, fmt.Sprintf("add column %s.%s %s", table, field.DBName, NormalizedType(field)))
+	}
+	for _, field := range d.FieldsToModify {
+		op := fmt.Sprintf("modify column %s.%s", table, field.DBName)
+		if prev := d.PreviousFields[field.DBName]; prev != nil && NormalizedType(prev) != NormalizedType(field) {
+			op += fmt.Sprintf(" from %s to %s", NormalizedType(prev), NormalizedType(field))
+		}
+		ops = append(ops, op)
+	}
+	for _, field := range d.FieldsToDrop {
+		ops = append(ops, fmt.Sprintf("drop column %s.%s", table, field.DBName))
+	}
+	for _, rename := range d.IndexesToRename {
+		ops = append(ops, fmt.Sprintf("rename index %s to %s", rename.OldName, rename.NewName))
+	}
+	for _, idx := range d.IndexesToAdd {
+		ops = append(ops, fmt.Sprintf("add index %s on %s", idx.Name, table))
+	}
+	for _, idx := range d.IndexesToModify {
+		ops = append(ops, fmt.Sprintf("modify index %s on %s", idx.Name, table))
+	}
+	for _, idx := range d.IndexesToDrop {
+		ops = append(ops, fmt.Sprintf("drop index %s on %s", idx.Name, table))
+	}
+	for _, uq := range d.UniquesToAdd {
+		ops = append(ops, fmt.Sprintf("add unique constraint %s on %s(%s)", uq.Name, table, strings.Join(uq.Columns, ", ")))
+	}
+	for _, uq := range d.UniquesToDrop {
+		ops = append(ops, fmt.Sprintf("drop unique constraint %s on %s", uq.Name, table))
+	}
+	for _, fk := range d.ForeignKeysToAdd {
+		ops = append(ops, "add "+foreignKeyDescription(table, fk))
+	}
+	for _, fk := range d.ForeignKeysToDrop {
+		ops = append(ops, "drop "+foreignKeyDescription(table, fk))
+	}
+	for _, chk := range d.ChecksToAdd {
+		ops = append(ops, fmt.Sprintf("add check %s on %s", chk.Name, table))
+	}
+	for _, chk := range d.ChecksToDrop {
+		ops = append(ops, fmt.Sprintf("drop check %s on %s", chk.Name, table))
+	}
+	if d.Comment != d.PreviousComment {
+		ops = append(ops, "change comment of table "+table)
+	}
+
+	return ops
+}
+
+// foreignKeyDescription describes a foreign key of table as
+// "foreign key table.column → referenced"
+func foreignKeyDescription(table string, fk *schema.Relationship) string {
+	var column, referenced string
+	if len(fk.References) > 0 && fk.References[0] != nil {
+		ref := fk.References[0]
+		if ref.ForeignKey != nil {
+			column = ref.ForeignKey.DBName
+		}
+		if ref.PrimaryKey != nil && ref.PrimaryKey.Schema != nil {
+			referenced = ref.PrimaryKey.Schema.Table
+		}
+	}
+	if column == "" && fk.Field != nil {
+		column = fk.Field.DBName
+	}
+	if referenced == "" && fk.Schema != nil {
+		referenced = fk.Schema.Table
+	}
+	return fmt.Sprintf("foreign key %s.%s → %s", table, column, referenced)
+}
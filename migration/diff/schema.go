@@ -3,6 +3,9 @@ package diff
 import (
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -13,12 +16,24 @@ import (
 // Add a package-level debug flag
 var debugDiffOutput = false // Set to true for detailed debug
 
+// decimalTypePattern matches a decimal type declared with its precision and
+// optional scale, as in a `type:decimal(12,2)` tag
+var decimalTypePattern = regexp.MustCompile(`^(?:decimal|numeric)\((\d+)(?:,\s*(\d+))?\)$`)
+
+// checkNamePattern matches an explicit constraint name in a `check:name,expr` tag
+var checkNamePattern = regexp.MustCompile(`^[\w-]+$`)
+
 // SchemaDiff represents the differences between two database schemas
 type SchemaDiff struct {
 	TablesToCreate []TableDiff
 	TablesToDrop   []string
 	TablesToModify []TableDiff
 	TablesToRename []TableRename
+	ViewsToCreate  []MaterializedView
+	EnumsToCreate  []Enum
+	EnumsToAlter   []EnumChange
+	RowsToInsert   []ReferenceData
+	RowsToUpdate   []ReferenceRowUpdate
 }
 
 // TableDiff represents the differences in a table, using GORM types
@@ -27,12 +42,21 @@ type TableDiff struct {
 	FieldsToAdd       []*schema.Field
 	FieldsToDrop      []*schema.Field
 	FieldsToModify    []*schema.Field
+	PreviousFields    map[string]*schema.Field // current definition of modified fields, by DBName
 	FieldsToRename    []ColumnRename
 	IndexesToAdd      []*schema.Index
 	IndexesToDrop     []*schema.Index
 	IndexesToModify   []*schema.Index
+	PreviousIndexes   map[string]*schema.Index // current definition of modified indexes, by name
+	IndexesToRename   []IndexRename
+	UniquesToAdd      []UniqueConstraint
+	UniquesToDrop     []UniqueConstraint
 	ForeignKeysToAdd  []*schema.Relationship
 	ForeignKeysToDrop []*schema.Relationship
+	ChecksToAdd       []*schema.CheckConstraint
+	ChecksToDrop      []*schema.CheckConstraint
+	Comment           string // table comment declared by the model
+	PreviousComment   string // table comment currently in the database
 }
 
 // IsEmpty checks if a TableDiff is empty
@@ -40,10 +64,37 @@ func (d *TableDiff) IsEmpty() bool {
 	return len(d.FieldsToAdd) == 0 &&
 		len(d.FieldsToModify) == 0 &&
 		len(d.FieldsToDrop) == 0 &&
+		len(d.FieldsToRename) == 0 &&
 		len(d.IndexesToAdd) == 0 &&
 		len(d.IndexesToDrop) == 0 &&
+		len(d.IndexesToModify) == 0 &&
+		len(d.IndexesToRename) == 0 &&
+		len(d.UniquesToAdd) == 0 &&
+		len(d.UniquesToDrop) == 0 &&
 		len(d.ForeignKeysToAdd) == 0 &&
-		len(d.ForeignKeysToDrop) == 0
+		len(d.ForeignKeysToDrop) == 0 &&
+		len(d.ChecksToAdd) == 0 &&
+		len(d.ChecksToDrop) == 0 &&
+		d.Comment == d.PreviousComment
+}
+
+// TableCommenter is implemented by models that carry a table comment
+type TableCommenter interface {
+	TableComment() string
+}
+
+// FKAction sets the ON DELETE and ON UPDATE actions of a foreign key, such as
+// "CASCADE" or "SET NULL". An empty action is left to the `constraint` tag.
+type FKAction struct {
+	OnDelete string
+	OnUpdate string
+}
+
+// ForeignKeyActioner is implemented by models that declare the actions of
+// their foreign keys in one place, keyed by relationship field name. The
+// actions take precedence over a `constraint` tag on the same field.
+type ForeignKeyActioner interface {
+	ForeignKeyActions() map[string]FKAction
 }
 
 // ColumnRename represents a column rename operation
@@ -58,16 +109,141 @@ type TableRename struct {
 	NewName string
 }
 
+// IndexRename represents an index rename operation
+type IndexRename struct {
+	OldName string
+	NewName string
+}
+
+// UniqueConstraint is a named UNIQUE table constraint, as opposed to a unique
+// index. Columns sharing a `uniqueConstraint:name` tag form one constraint.
+type UniqueConstraint struct {
+	Name    string
+	Columns []string
+}
+
+// Enum is a Postgres enum type and its values, in order
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+// EnumChange lists the values added to and removed from an existing enum type
+type EnumChange struct {
+	Name    string
+	Added   []string
+	Removed []string // Postgres cannot drop enum values
+}
+
+// AddsEnumValues reports whether any enum change adds values. Removed values
+// cannot be migrated, only flagged in a migration made for other changes, so
+// on their own they are no change.
+func (d *SchemaDiff) AddsEnumValues() bool {
+	for _, enum := range d.EnumsToAlter {
+		if len(enum.Added) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferenceData is a set of rows, keyed by column name, that a reference
+// table must contain
+type ReferenceData struct {
+	Table string
+	Rows  []map[string]any
+}
+
+// ReferenceRowUpdate is a reference row whose key matches a row in the
+// database but whose other values differ
+type ReferenceRowUpdate struct {
+	Table    string
+	Key      map[string]any // key columns identifying the row
+	Values   map[string]any // values the row must have, by changed column
+	Previous map[string]any // values currently in the database, by changed column
+}
+
+// MaterializedView is a Postgres materialized view defined by a query
+type MaterializedView struct {
+	Name string
+	SQL  string
+}
+
 // SchemaComparer compares database schemas
 type SchemaComparer struct {
-	db *gorm.DB
+	db                *gorm.DB
+	tableRenames      map[string]string
+	renameThreshold   float64
+	detectColumns     bool
+	indexRenames      map[string]string
+	pruneUnmanaged    bool
+	materializedViews []MaterializedView
+	enums             []Enum
+	referenceData     []ReferenceData
 }
 
 // NewSchemaComparer creates a new schema comparer
 func NewSchemaComparer(db *gorm.DB) *SchemaComparer {
-	return &SchemaComparer{
-		db: db,
-	}
+	return &SchemaComparer{db: db}
+}
+
+// SetTableRenames sets explicit table rename hints, mapping an old table name
+// to its new name. A hinted pair is reported as a rename instead of a drop
+// and a create.
+func (c *SchemaComparer) SetTableRenames(renames map[string]string) {
+	c.tableRenames = renames
+}
+
+// SetRenameThreshold sets how closely the columns of a table that only
+// exists in the database must match those of a table that only exists in
+// the models for the pair to be reported as a rename: the share of columns,
+// by name and type, that both have. 1 requires identical columns; the
+// default of 0 turns detection off. Only unambiguous pairs are renamed, and
+// only when unmanaged tables are pruned, as other tables are never dropped.
+func (c *SchemaComparer) SetRenameThreshold(threshold float64) {
+	c.renameThreshold = threshold
+}
+
+// SetDetectColumnRenames sets whether a table's only dropped column and only
+// added column are reported as a rename when their types match. Off by
+// default, as a drop and an add can also be two unrelated changes.
+func (c *SchemaComparer) SetDetectColumnRenames(enabled bool) {
+	c.detectColumns = enabled
+}
+
+// SetIndexRenames sets explicit index rename hints, mapping an old index name
+// to its new name. A hinted pair with the same definition is reported as a
+// rename instead of a drop and a create.
+func (c *SchemaComparer) SetIndexRenames(renames map[string]string) {
+	c.indexRenames = renames
+}
+
+// SetPruneUnmanaged sets whether tables in the database without a matching
+// model are reported as tables to drop. By default they are left alone, as
+// they may belong to another application.
+func (c *SchemaComparer) SetPruneUnmanaged(prune bool) {
+	c.pruneUnmanaged = prune
+}
+
+// SetMaterializedViews sets the materialized views the database should have.
+// Missing views are reported as views to create, and tables or models with a
+// view's name are left out of the table comparison.
+func (c *SchemaComparer) SetMaterializedViews(views []MaterializedView) {
+	c.materializedViews = views
+}
+
+// SetEnums sets the enum types the database should have. Missing types are
+// reported as enums to create and changed value lists as enums to alter.
+func (c *SchemaComparer) SetEnums(enums []Enum) {
+	c.enums = enums
+}
+
+// SetReferenceData sets the rows reference tables must contain. Rows are
+// matched on the table's primary key, or a unique column, when they set it:
+// rows missing from the database are reported as rows to insert, and rows
+// whose other values differ as rows to update.
+func (c *SchemaComparer) SetReferenceData(data []ReferenceData) {
+	c.referenceData = data
 }
 
 // Compare compares the current database schema with the provided models
@@ -144,6 +320,7 @@ func (c *SchemaComparer) GetCurrentSchema() (map[string]*schema.Schema, error) {
 func (c *SchemaComparer) GetModelSchemas(models ...any) (map[string]*schema.Schema, error) {
 	modelSchemas := make(map[string]*schema.Schema)
 	originalRelationships := make(map[string]*schema.Relationships)
+	foreignKeyActions := make(map[string]map[string]FKAction)
 
 	// First pass: create schemas with fields and store original relationships
 	for _, model := range models {
@@ -166,6 +343,14 @@ func (c *SchemaComparer) GetModelSchemas(models ...any) (map[string]*schema.Sche
 				continue
 			}
 			seenColumns[field.DBName] = true
+			if binarySerializer(field) {
+				// gorm stores serialized fields as strings; gob and custom
+				// serializers produce bytes. Copy the field so gorm's cached
+				// schema is left alone.
+				binary := *field
+				binary.DataType = schema.Bytes
+				field = &binary
+			}
 			columns = append(columns, field)
 		}
 		// If the model embeds gorm.Model, ensure default columns are present
@@ -182,11 +367,15 @@ func (c *SchemaComparer) GetModelSchemas(models ...any) (map[string]*schema.Sche
 
 		// Store original relationships for later processing
 		originalRelationships[s.Table] = &s.Relationships
+		if actioner, ok := reflect.New(s.ModelType).Interface().(ForeignKeyActioner); ok {
+			foreignKeyActions[s.Table] = actioner.ForeignKeyActions()
+		}
 
 		// Create a copy of the schema with fields and empty relationships
 		copySchema := schema.Schema{
 			Name:          s.Name,
 			Table:         s.Table,
+			ModelType:     s.ModelType,
 			Fields:        columns,
 			Relationships: schema.Relationships{}, // Create empty relationships to avoid copying locks
 		}
@@ -243,9 +432,12 @@ func (c *SchemaComparer) GetModelSchemas(models ...any) (map[string]*schema.Sche
 					// Create a new relationship with the correct foreign key field and referenced schema
 					newRel := &schema.Relationship{
 						Type:        schema.BelongsTo,
-						Field:       fkField,
+						Field:       withConstraintTag(fkField, rel.Field, foreignKeyActions[tableName][rel.Name]),
 						Schema:      referencedSchema,
 						FieldSchema: rel.FieldSchema,
+						References: []*schema.Reference{
+							{ForeignKey: fkField, PrimaryKey: primaryKeyField(referencedSchema)},
+						},
 					}
 					relationships.BelongsTo = append(relationships.BelongsTo, newRel)
 				}
@@ -348,6 +540,15 @@ func (c *SchemaComparer) getCurrentSchema() (map[string]*schema.Schema, error) {
 			continue
 		}
 
+		identityColumns, err := migrator.GetIdentityColumns(tableName)
+		if err != nil && debugDiffOutput {
+			fmt.Printf("[DEBUG] Failed to get identity columns for table %s: %v\n", tableName, err)
+		}
+		sequenceDefaults, err := migrator.GetSequenceDefaults(tableName)
+		if err != nil && debugDiffOutput {
+			fmt.Printf("[DEBUG] Failed to get sequence defaults for table %s: %v\n", tableName, err)
+		}
+
 		var fields []*schema.Field
 		for _, col := range columns {
 			isPrimaryKey, _ := col.PrimaryKey()
@@ -360,7 +561,7 @@ func (c *SchemaComparer) getCurrentSchema() (map[string]*schema.Schema, error) {
 			field := &schema.Field{
 				Name:          toExportedFieldName(col.Name()),
 				DBName:        col.Name(),
-				DataType:      schema.DataType(col.DatabaseTypeName()),
+				DataType:      schema.DataType(introspectedDataType(db.Name(), col)),
 				NotNull:       !nullable,
 				PrimaryKey:    isPrimaryKey,
 				AutoIncrement: isAutoIncrement,
@@ -372,6 +573,15 @@ func (c *SchemaComparer) getCurrentSchema() (map[string]*schema.Schema, error) {
 				Updatable:     true,
 				Readable:      true,
 			}
+			if identityColumns[col.Name()] {
+				field.TagSettings = map[string]string{"IDENTITY": "IDENTITY"}
+			}
+			// Only a serial key auto-increments, other columns keep the
+			// sequence default they draw from
+			if sequence, ok := sequenceDefaults[col.Name()]; ok && !isPrimaryKey {
+				field.DefaultValue = sequence
+				field.AutoIncrement = false
+			}
 			fields = append(fields, field)
 		}
 
@@ -453,8 +663,137 @@ func (c *SchemaComparer) compareSchemas(current, target map[string]*schema.Schem
 		normalizedTarget[normalizeTableName(name)] = schema
 	}
 
+	// Materialized views are not tables, even when a read model maps to one.
+	// Only Postgres has them; elsewhere they could never be found and would
+	// be created again on every run.
+	if len(c.materializedViews) > 0 && c.db.Name() != "postgres" {
+		return nil, fmt.Errorf("materialized views are only supported on Postgres, not %s", c.db.Name())
+	}
+	if len(c.materializedViews) > 0 {
+		views := make(map[string]bool)
+		for _, view := range c.materializedViews {
+			views[normalizeTableName(view.Name)] = true
+		}
+		for name, s := range normalizedCurrent {
+			if views[normalizeTableName(s.Table)] {
+				delete(normalizedCurrent, name)
+			}
+		}
+		for name, s := range normalizedTarget {
+			if views[normalizeTableName(s.Table)] {
+				delete(normalizedTarget, name)
+			}
+		}
+
+		existing, err := NewSchemaMigrator(c.db).GetMaterializedViews()
+		if err != nil {
+			return nil, err
+		}
+		for _, view := range c.materializedViews {
+			if !existing[normalizeTableName(view.Name)] {
+				diff.ViewsToCreate = append(diff.ViewsToCreate, view)
+			}
+		}
+	}
+
+	// Enum types exist only on Postgres
+	if len(c.enums) > 0 && c.db.Name() == "postgres" {
+		existing, err := NewSchemaMigrator(c.db).GetEnums()
+		if err != nil {
+			return nil, err
+		}
+		for _, enum := range c.enums {
+			current, ok := existing[strings.ToLower(enum.Name)]
+			if !ok {
+				diff.EnumsToCreate = append(diff.EnumsToCreate, enum)
+				continue
+			}
+			change := EnumChange{Name: enum.Name, Added: missingValues(enum.Values, current), Removed: missingValues(current, enum.Values)}
+			if len(change.Added) > 0 || len(change.Removed) > 0 {
+				diff.EnumsToAlter = append(diff.EnumsToAlter, change)
+			}
+		}
+	}
+
+	for _, data := range c.referenceData {
+		missing := ReferenceData{Table: data.Table}
+		exists := c.db.Migrator().HasTable(data.Table)
+		var keys [][]string
+		if exists {
+			var err error
+			if keys, err = c.referenceKeys(data.Table); err != nil {
+				return nil, err
+			}
+		}
+		for _, row := range data.Rows {
+			if exists {
+				update, found, err := c.compareReferenceRow(data.Table, keys, row)
+				if err != nil {
+					return nil, err
+				}
+				if update != nil {
+					diff.RowsToUpdate = append(diff.RowsToUpdate, *update)
+				}
+				if found {
+					continue
+				}
+			}
+			missing.Rows = append(missing.Rows, row)
+		}
+		if len(missing.Rows) > 0 {
+			diff.RowsToInsert = append(diff.RowsToInsert, missing)
+		}
+	}
+
+	// Rename hints take precedence over dropping and creating tables
+	renamedCurrent := make(map[string]bool)
+	renamedTarget := make(map[string]bool)
+	oldNames := make([]string, 0, len(c.tableRenames))
+	for oldName := range c.tableRenames {
+		oldNames = append(oldNames, oldName)
+	}
+	sort.Strings(oldNames)
+	for _, oldName := range oldNames {
+		oldNormalized := normalizeTableName(oldName)
+		newNormalized := normalizeTableName(c.tableRenames[oldName])
+		currentSchema, inCurrent := normalizedCurrent[oldNormalized]
+		targetSchema, inTarget := normalizedTarget[newNormalized]
+		if !inCurrent || !inTarget {
+			continue
+		}
+		if _, taken := normalizedCurrent[newNormalized]; taken {
+			continue
+		}
+
+		diff.TablesToRename = append(diff.TablesToRename, TableRename{OldName: currentSchema.Table, NewName: targetSchema.Table})
+		renamedCurrent[oldNormalized] = true
+		renamedTarget[newNormalized] = true
+
+		tableDiff := c.compareTable(currentSchema, targetSchema)
+		if !tableDiff.IsEmpty() {
+			diff.TablesToModify = append(diff.TablesToModify, tableDiff)
+		}
+	}
+
+	// Tables left over on both sides with matching columns were renamed. A
+	// table that is not pruned is left alone rather than taken over.
+	for _, rename := range c.detectTableRenames(normalizedCurrent, normalizedTarget, renamedCurrent, renamedTarget) {
+		currentSchema, targetSchema := normalizedCurrent[rename.OldName], normalizedTarget[rename.NewName]
+		diff.TablesToRename = append(diff.TablesToRename, TableRename{OldName: currentSchema.Table, NewName: targetSchema.Table})
+		renamedCurrent[rename.OldName] = true
+		renamedTarget[rename.NewName] = true
+
+		tableDiff := c.compareTable(currentSchema, targetSchema)
+		if !tableDiff.IsEmpty() {
+			diff.TablesToModify = append(diff.TablesToModify, tableDiff)
+		}
+	}
+
 	// Find tables to create and modify
 	for normalizedName, targetSchema := range normalizedTarget {
+		if renamedTarget[normalizedName] {
+			continue
+		}
 		currentSchema, exists := normalizedCurrent[normalizedName]
 		if !exists {
 			// Table needs to be created
@@ -475,8 +814,11 @@ func (c *SchemaComparer) compareSchemas(current, target map[string]*schema.Schem
 		}
 	}
 
-	// Find tables to drop
+	// Find tables to drop, only when unmanaged tables are to be pruned
 	for normalizedName := range normalizedCurrent {
+		if !c.pruneUnmanaged || renamedCurrent[normalizedName] {
+			continue
+		}
 		if _, exists := normalizedTarget[normalizedName]; !exists {
 			// Find the original table name to add to TablesToDrop
 			for originalName := range current {
@@ -491,6 +833,206 @@ func (c *SchemaComparer) compareSchemas(current, target map[string]*schema.Schem
 	return diff, nil
 }
 
+// detectTableRenames pairs tables that only exist in current with tables
+// that only exist in target when their columns match by at least the rename
+// threshold. A table with more than one such match is ambiguous and left
+// out. The renames are returned by normalized name, sorted by old name.
+func (c *SchemaComparer) detectTableRenames(current, target map[string]*schema.Schema, renamedCurrent, renamedTarget map[string]bool) []TableRename {
+	if c.renameThreshold <= 0 || !c.pruneUnmanaged {
+		return nil
+	}
+
+	candidates := make(map[string][]string)
+	matchedBy := make(map[string]int)
+	for newName, targetSchema := range target {
+		if _, exists := current[newName]; exists || renamedTarget[newName] {
+			continue
+		}
+		for oldName, currentSchema := range current {
+			if _, exists := target[oldName]; exists || renamedCurrent[oldName] {
+				continue
+			}
+			if columnSimilarity(currentSchema, targetSchema) >= c.renameThreshold {
+				candidates[newName] = append(candidates[newName], oldName)
+				matchedBy[oldName]++
+			}
+		}
+	}
+
+	var renames []TableRename
+	for newName, oldNames := range candidates {
+		if len(oldNames) == 1 && matchedBy[oldNames[0]] == 1 {
+			renames = append(renames, TableRename{OldName: oldNames[0], NewName: newName})
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].OldName < renames[j].OldName })
+	return renames
+}
+
+// columnSimilarity returns the share of the columns of two tables, by name
+// and normalized type, that both have
+func columnSimilarity(a, b *schema.Schema) float64 {
+	columns := func(s *schema.Schema) map[string]string {
+		byName := make(map[string]string)
+		for _, field := range s.Fields {
+			if field.DBName != "" {
+				byName[strings.ToLower(field.DBName)] = normalizeDBType(field.DataType)
+			}
+		}
+		return byName
+	}
+	aColumns, bColumns := columns(a), columns(b)
+
+	union := len(aColumns)
+	shared := 0
+	for name, dataType := range bColumns {
+		aType, ok := aColumns[name]
+		if !ok {
+			union++
+		} else if aType == dataType {
+			shared++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// missingValues returns the values that are not in other, keeping their order
+func missingValues(values, other []string) []string {
+	present := make(map[string]bool, len(other))
+	for _, value := range other {
+		present[value] = true
+	}
+	var missing []string
+	for _, value := range values {
+		if !present[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+// referenceKeys returns the keys a reference row can be matched on: the
+// primary key columns of the table, then each of its unique columns
+func (c *SchemaComparer) referenceKeys(table string) ([][]string, error) {
+	columnTypes, err := c.db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+	}
+	var primary []string
+	var keys [][]string
+	for _, col := range columnTypes {
+		if isPrimary, ok := col.PrimaryKey(); ok && isPrimary {
+			primary = append(primary, col.Name())
+		} else if isUnique, ok := col.Unique(); ok && isUnique {
+			keys = append(keys, []string{col.Name()})
+		}
+	}
+	if len(primary) > 0 {
+		keys = append([][]string{primary}, keys...)
+	}
+	return keys, nil
+}
+
+// compareReferenceRow looks a reference row up by the first key whose
+// columns it sets, or by all of its values when it sets none. It reports
+// whether the row exists and, when its other values differ, the update that
+// brings it in line.
+func (c *SchemaComparer) compareReferenceRow(table string, keys [][]string, row map[string]any) (*ReferenceRowUpdate, bool, error) {
+	key := map[string]any(row)
+	for _, columns := range keys {
+		if rowKey := referenceRowKey(row, columns); rowKey != nil {
+			key = rowKey
+			break
+		}
+	}
+
+	var existing []map[string]any
+	if err := c.db.Table(table).Where(key).Limit(1).Find(&existing).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to check reference data in %s: %w", table, err)
+	}
+	if len(existing) == 0 {
+		return nil, false, nil
+	}
+
+	update := ReferenceRowUpdate{Table: table, Key: key, Values: map[string]any{}, Previous: map[string]any{}}
+	for column, value := range row {
+		if _, isKey := key[column]; isKey {
+			continue
+		}
+		current := existing[0][column]
+		if b, ok := current.([]byte); ok {
+			current = string(b)
+		}
+		if referenceValuesEqual(value, current) {
+			continue
+		}
+		update.Values[column] = value
+		update.Previous[column] = current
+	}
+	if len(update.Values) == 0 {
+		return nil, true, nil
+	}
+	return &update, true, nil
+}
+
+// referenceRowKey returns the values of the key columns of a row, or nil
+// when the row does not set all of them
+func referenceRowKey(row map[string]any, columns []string) map[string]any {
+	key := make(map[string]any, len(columns))
+	for _, column := range columns {
+		value, ok := row[column]
+		if !ok || value == nil {
+			return nil
+		}
+		key[column] = value
+	}
+	return key
+}
+
+// referenceValuesEqual reports whether a reference value matches the value
+// read back from the database, which drivers return in their own types
+func referenceValuesEqual(want, got any) bool {
+	if want == nil || got == nil {
+		return want == nil && got == nil
+	}
+	if b, ok := want.(bool); ok {
+		switch strings.ToLower(fmt.Sprint(got)) {
+		case "true", "t", "1":
+			return b
+		case "false", "f", "0":
+			return !b
+		}
+	}
+	return fmt.Sprint(want) == fmt.Sprint(got)
+}
+
+// detectColumnRename turns a table's single dropped and single added column
+// into a rename when their types match. Any other attribute change of the
+// column is kept as a modification of the renamed column.
+func detectColumnRename(diff *TableDiff) {
+	if len(diff.FieldsToDrop) != 1 || len(diff.FieldsToAdd) != 1 {
+		return
+	}
+	dropped, added := diff.FieldsToDrop[0], diff.FieldsToAdd[0]
+	if normalizeDBType(dropped.DataType) != normalizeDBType(added.DataType) {
+		return
+	}
+
+	diff.FieldsToRename = append(diff.FieldsToRename, ColumnRename{OldName: dropped.DBName, NewName: added.DBName})
+	diff.FieldsToDrop = diff.FieldsToDrop[:0]
+	diff.FieldsToAdd = diff.FieldsToAdd[:0]
+
+	renamed := *dropped
+	renamed.DBName = added.DBName
+	if !fieldsEqual(&renamed, added) {
+		diff.FieldsToModify = append(diff.FieldsToModify, added)
+		diff.PreviousFields[added.DBName] = &renamed
+	}
+}
+
 // CompareTable compares two table schemas and returns a TableDiff using GORM types
 func (c *SchemaComparer) CompareTable(current, target *schema.Schema) TableDiff {
 	return c.compareTable(current, target)
@@ -503,12 +1045,19 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 		FieldsToAdd:       make([]*schema.Field, 0),
 		FieldsToDrop:      make([]*schema.Field, 0),
 		FieldsToModify:    make([]*schema.Field, 0),
+		PreviousFields:    make(map[string]*schema.Field),
 		FieldsToRename:    make([]ColumnRename, 0),
 		IndexesToAdd:      make([]*schema.Index, 0),
 		IndexesToDrop:     make([]*schema.Index, 0),
 		IndexesToModify:   make([]*schema.Index, 0),
+		PreviousIndexes:   make(map[string]*schema.Index),
+		IndexesToRename:   make([]IndexRename, 0),
+		UniquesToAdd:      make([]UniqueConstraint, 0),
+		UniquesToDrop:     make([]UniqueConstraint, 0),
 		ForeignKeysToAdd:  make([]*schema.Relationship, 0),
 		ForeignKeysToDrop: make([]*schema.Relationship, 0),
+		ChecksToAdd:       make([]*schema.CheckConstraint, 0),
+		ChecksToDrop:      make([]*schema.CheckConstraint, 0),
 	}
 
 	currentFields := make(map[string]*schema.Field)
@@ -529,6 +1078,14 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 		}
 	}
 
+	// Columns the model opts out of are left alone on both sides
+	for _, field := range target.Fields {
+		if field != nil && MigrationIgnored(field) {
+			delete(targetFields, field.DBName)
+			delete(currentFields, field.DBName)
+		}
+	}
+
 	for _, field := range gormDefaultFields() {
 		if _, exists := targetFields[normalizeFieldName(field.DBName)]; exists {
 			targetFields[normalizeFieldName(field.DBName)].IgnoreMigration = true
@@ -538,8 +1095,11 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 		}
 	}
 
-	for normName, targetField := range targetFields {
-		if targetField == nil || targetField.DBName == "" {
+	// Columns are visited in declared order, so a new table's columns follow
+	// the model and a column inserted mid-struct is a single addition
+	for _, normName := range fieldOrder(target.Fields) {
+		targetField, ok := targetFields[normName]
+		if !ok || targetField == nil || targetField.DBName == "" {
 			continue
 		}
 
@@ -555,9 +1115,14 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 				fmt.Printf("[DEBUG] Field modification detected for %s.%s: current type=%v, target type=%v\n\n", target.Table, targetField.DBName, currentField.DataType, targetField.DataType)
 			}
 			diff.FieldsToModify = append(diff.FieldsToModify, targetField)
+			diff.PreviousFields[targetField.DBName] = currentField
 		}
 	}
-	for normName, currentField := range currentFields {
+	for _, normName := range fieldOrder(current.Fields) {
+		currentField, ok := currentFields[normName]
+		if !ok {
+			continue
+		}
 		if _, exists := targetFields[normName]; !exists {
 			if debugDiffOutput {
 				fmt.Printf("[DEBUG] currentField: %+v\n", currentField.Name)
@@ -566,6 +1131,9 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 			diff.FieldsToDrop = append(diff.FieldsToDrop, currentField)
 		}
 	}
+	if c.detectColumns {
+		detectColumnRename(&diff)
+	}
 
 	migrator := NewSchemaMigrator(c.db)
 
@@ -589,38 +1157,172 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 		}
 	}
 
-	for name, targetIdx := range targetIndexes {
-		if _, exists := currentIndexes[name]; !exists {
+	// Unique constraints are compared by name and columns. Their backing
+	// indexes are left to them, except for those still declared as indexes.
+	// Only Postgres constraints are introspected, so elsewhere they are only
+	// created with new tables rather than added again on every run.
+	var currentUniques, targetUniques []UniqueConstraint
+	if c.db.Name() == "postgres" {
+		currentUniques, err = migrator.GetUniqueConstraints(current.Table)
+		if err != nil && debugDiffOutput {
+			fmt.Printf("[DEBUG] Failed to get unique constraints for table %s: %v\n", current.Table, err)
+		}
+	}
+	if c.db.Name() == "postgres" || len(current.Fields) == 0 {
+		targetUniques = parseUniqueConstraints(target)
+	}
+	currentUniqueByName := make(map[string]UniqueConstraint)
+	for _, uq := range currentUniques {
+		currentUniqueByName[uq.Name] = uq
+	}
+	targetUniqueNames := make(map[string]bool)
+	for _, uq := range targetUniques {
+		targetUniqueNames[uq.Name] = true
+		currentUq, exists := currentUniqueByName[uq.Name]
+		if !exists {
+			diff.UniquesToAdd = append(diff.UniquesToAdd, uq)
+		} else if strings.Join(currentUq.Columns, ",") != strings.Join(uq.Columns, ",") {
+			diff.UniquesToDrop = append(diff.UniquesToDrop, currentUq)
+			diff.UniquesToAdd = append(diff.UniquesToAdd, uq)
+		}
+	}
+	for _, uq := range currentUniques {
+		if _, isIndex := targetIndexes[uq.Name]; isIndex {
+			continue
+		}
+		delete(currentIndexes, uq.Name)
+		if !targetUniqueNames[uq.Name] && len(current.Fields) > 0 {
+			diff.UniquesToDrop = append(diff.UniquesToDrop, uq)
+		}
+	}
+
+	// Rename hints take precedence over dropping and creating indexes
+	oldIndexNames := make([]string, 0, len(c.indexRenames))
+	for oldName := range c.indexRenames {
+		oldIndexNames = append(oldIndexNames, oldName)
+	}
+	sort.Strings(oldIndexNames)
+	for _, oldName := range oldIndexNames {
+		newName := c.indexRenames[oldName]
+		currentIdx, inCurrent := currentIndexes[oldName]
+		targetIdx, inTarget := targetIndexes[newName]
+		if !inCurrent || !inTarget {
+			continue
+		}
+		if _, taken := currentIndexes[newName]; taken {
+			continue
+		}
+		renamed := *currentIdx
+		renamed.Name = newName
+		if !indexesEqual(&renamed, targetIdx) {
+			continue
+		}
+
+		diff.IndexesToRename = append(diff.IndexesToRename, IndexRename{OldName: oldName, NewName: newName})
+		delete(currentIndexes, oldName)
+		delete(targetIndexes, newName)
+	}
+
+	// The database may name an index differently than GORM, such as
+	// users_email_idx for idx_users_email, so an index only one side knows by
+	// name matches one with the same definition instead of being recreated
+	for _, name := range sortedIndexNames(targetIndexes) {
+		if _, exists := currentIndexes[name]; exists {
+			continue
+		}
+		definition := indexDefinition(targetIndexes[name])
+		for _, currentName := range sortedIndexNames(currentIndexes) {
+			if _, declared := targetIndexes[currentName]; declared {
+				continue
+			}
+			if indexDefinition(currentIndexes[currentName]) == definition {
+				delete(currentIndexes, currentName)
+				delete(targetIndexes, name)
+				break
+			}
+		}
+	}
+
+	for _, name := range sortedIndexNames(targetIndexes) {
+		targetIdx := targetIndexes[name]
+		if currentIdx, exists := currentIndexes[name]; !exists {
 			diff.IndexesToAdd = append(diff.IndexesToAdd, targetIdx)
-		} else if !indexesEqual(currentIndexes[name], targetIdx) {
+		} else if !indexesEqual(currentIdx, targetIdx) {
 			diff.IndexesToModify = append(diff.IndexesToModify, targetIdx)
+			diff.PreviousIndexes[name] = currentIdx
 		}
 	}
 
 	if len(current.Fields) > 0 {
-		for name, currentIdx := range currentIndexes {
+		for _, name := range sortedIndexNames(currentIndexes) {
 			if strings.HasSuffix(name, "pkey") {
 				continue
 			}
 			if _, exists := targetIndexes[name]; !exists {
-				diff.IndexesToDrop = append(diff.IndexesToDrop, currentIdx)
+				diff.IndexesToDrop = append(diff.IndexesToDrop, currentIndexes[name])
 			}
 		}
 	}
 
+	// Check constraints are compared by name, independently of any index on
+	// the same column
+	currentChecks, err := migrator.GetChecks(current.Table)
+	if err != nil {
+		fmt.Printf("[DEBUG] failed to get check constraints for table %s: %v\n", current.Table, err)
+	}
+	currentCheckByName := make(map[string]*schema.CheckConstraint)
+	for _, chk := range currentChecks {
+		currentCheckByName[chk.Name] = chk
+	}
+
+	// A changed expression is dropped and added again under the same name
+	targetChecks := parseCheckConstraints(target)
+	targetCheckNames := make(map[string]bool)
+	for _, chk := range targetChecks {
+		targetCheckNames[chk.Name] = true
+		currentChk, exists := currentCheckByName[chk.Name]
+		if !exists {
+			diff.ChecksToAdd = append(diff.ChecksToAdd, chk)
+		} else if normalizePredicate(currentChk.Constraint) != normalizePredicate(chk.Constraint) {
+			diff.ChecksToDrop = append(diff.ChecksToDrop, currentChk)
+			diff.ChecksToAdd = append(diff.ChecksToAdd, chk)
+		}
+	}
+
+	if len(current.Fields) > 0 {
+		for _, chk := range currentChecks {
+			if !targetCheckNames[chk.Name] {
+				diff.ChecksToDrop = append(diff.ChecksToDrop, chk)
+			}
+		}
+	}
+
+	// Only Postgres table comments are introspected, so elsewhere only new
+	// tables get one rather than an existing table's being set on every run
+	switch {
+	case c.db.Name() == "postgres":
+		if len(current.Fields) > 0 {
+			diff.PreviousComment, err = migrator.GetTableComment(current.Table)
+			if err != nil && debugDiffOutput {
+				fmt.Printf("[DEBUG] Failed to get comment for table %s: %v\n", current.Table, err)
+			}
+		}
+		diff.Comment = tableComment(target)
+	case len(current.Fields) == 0:
+		diff.Comment = tableComment(target)
+	}
+
 	currentRelationships := make(map[string]*schema.Relationship)
 	for _, rel := range current.Relationships.BelongsTo {
-		if rel.Field != nil {
-			column_rel_ident := fmt.Sprintf("%s_%s", rel.Field.Schema.Table, rel.References[0].ForeignKey.DBName)
-			currentRelationships[column_rel_ident] = rel
+		if key := relationshipKey(rel); key != "" {
+			currentRelationships[key] = rel
 		}
 	}
 
 	targetRelationships := make(map[string]*schema.Relationship)
 	for _, rel := range target.Relationships.BelongsTo {
-		if rel.Field != nil && len(rel.References) > 0 {
-			column_rel_ident := fmt.Sprintf("%s_%s", rel.Field.Schema.Table, rel.References[0].ForeignKey.DBName)
-			targetRelationships[column_rel_ident] = rel
+		if key := relationshipKey(rel); key != "" {
+			targetRelationships[key] = rel
 		}
 	}
 
@@ -632,12 +1334,17 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 			diff.ForeignKeysToAdd = append(diff.ForeignKeysToAdd, targetRel)
 		} else if !relationshipsEqual(currentRelationships[fieldName], targetRel) {
 			diff.ForeignKeysToAdd = append(diff.ForeignKeysToAdd, targetRel)
+		} else if actionsChanged(currentRelationships[fieldName], targetRel) {
+			// Changed actions need the constraint dropped and added again
+			diff.ForeignKeysToDrop = append(diff.ForeignKeysToDrop, currentRelationships[fieldName])
+			diff.ForeignKeysToAdd = append(diff.ForeignKeysToAdd, targetRel)
 		}
 	}
 
 	if len(current.Fields) > 0 {
-		for fieldName, currentRel := range currentRelationships {
-			if _, exists := targetRelationships[fieldName]; !exists {
+		for _, currentRel := range current.Relationships.BelongsTo {
+			key := relationshipKey(currentRel)
+			if _, exists := targetRelationships[key]; key != "" && !exists {
 				diff.ForeignKeysToDrop = append(diff.ForeignKeysToDrop, currentRel)
 			}
 		}
@@ -646,6 +1353,30 @@ func (c *SchemaComparer) compareTable(current, target *schema.Schema) TableDiff
 	return diff
 }
 
+// introspectedDataType returns the type of an introspected column. MySQL
+// reports booleans as plain "tinyint", so the full column type is kept there
+// to tell TINYINT(1) apart from other tinyint columns.
+func introspectedDataType(dialect string, col gorm.ColumnType) string {
+	if dialect == "mysql" {
+		if columnType, ok := col.ColumnType(); ok && strings.EqualFold(columnType, "tinyint(1)") {
+			return strings.ToLower(columnType)
+		}
+	}
+	return col.DatabaseTypeName()
+}
+
+// tableComment returns the comment declared by the model behind a schema
+// through TableCommenter, if any
+func tableComment(s *schema.Schema) string {
+	if s == nil || s.ModelType == nil {
+		return ""
+	}
+	if commenter, ok := reflect.New(s.ModelType).Interface().(TableCommenter); ok {
+		return commenter.TableComment()
+	}
+	return ""
+}
+
 // normalizeFieldMetadata normalizes field metadata for comparison, ignoring GORM-specific metadata that doesn't affect DB schema
 func normalizeFieldMetadata(field *schema.Field) *schema.Field {
 	if field == nil {
@@ -663,12 +1394,12 @@ func normalizeFieldMetadata(field *schema.Field) *schema.Field {
 		Unique:          field.Unique,
 		DefaultValue:    field.DefaultValue,
 		Size:            field.Size,
-		Precision:       field.Precision,
-		Scale:           field.Scale,
 		Comment:         field.Comment,
+		TagSettings:     field.TagSettings,
 		IgnoreMigration: field.IgnoreMigration,
 		Schema:          field.Schema,
 	}
+	normalized.Precision, normalized.Scale = DecimalSize(field)
 
 	return normalized
 }
@@ -683,6 +1414,10 @@ func fieldsEqual(a, b *schema.Field) bool {
 		return false
 	}
 
+	if !decimalSizeEqual(a, b) {
+		return false
+	}
+
 	// For primary keys and auto-increment fields, ignore nullability differences
 	// (GORM often sets these differently than the database)
 	if a.PrimaryKey != b.PrimaryKey {
@@ -693,11 +1428,19 @@ func fieldsEqual(a, b *schema.Field) bool {
 		return false
 	}
 
-	if normalizeDefaultValue(a.DefaultValue) != normalizeDefaultValue(b.DefaultValue) {
-		return false
+	// Serial and identity columns introspect with a nextval() default or none
+	// at all, so two auto-increment columns never differ on their default
+	if !isAutoIncrementField(a) || !isAutoIncrementField(b) {
+		if normalizeDefaultValue(a.DefaultValue) != normalizeDefaultValue(b.DefaultValue) {
+			return false
+		}
+
+		if a.AutoIncrement != b.AutoIncrement {
+			return false
+		}
 	}
 
-	if a.AutoIncrement != b.AutoIncrement {
+	if IsIdentity(a) != IsIdentity(b) {
 		return false
 	}
 
@@ -708,19 +1451,129 @@ func fieldsEqual(a, b *schema.Field) bool {
 	return true
 }
 
+// decimalSizeEqual reports whether two decimal columns have the same
+// precision and scale. A side without a precision, such as a float field
+// without a precision tag, matches any.
+func decimalSizeEqual(a, b *schema.Field) bool {
+	if normalizeDBType(a.DataType) != "decimal" || a.Precision == 0 || b.Precision == 0 {
+		return true
+	}
+	return a.Precision == b.Precision && a.Scale == b.Scale
+}
+
+// DecimalSize returns the precision and scale of a field, from its precision
+// and scale tags or else from a decimal type tag such as decimal(12,2)
+func DecimalSize(f *schema.Field) (precision, scale int) {
+	match := decimalTypePattern.FindStringSubmatch(strings.ToLower(string(f.DataType)))
+	if f.Precision > 0 || match == nil {
+		return f.Precision, f.Scale
+	}
+	precision, _ = strconv.Atoi(match[1])
+	scale, _ = strconv.Atoi(match[2])
+	return precision, scale
+}
+
+// DecimalSizeOnlyChange reports whether current and target differ only in
+// their precision and/or scale
+func DecimalSizeOnlyChange(current, target *schema.Field) bool {
+	if current == nil || target == nil || fieldsEqual(current, target) {
+		return false
+	}
+	withCurrentSize := *target
+	withCurrentSize.Precision = current.Precision
+	withCurrentSize.Scale = current.Scale
+	return fieldsEqual(current, &withCurrentSize)
+}
+
+// isAutoIncrementField reports whether a field is auto-incremented, either
+// declared as such or a primary key backed by a sequence default. Other
+// columns with a sequence default are compared on the sequence they use.
+func isAutoIncrementField(f *schema.Field) bool {
+	return f.AutoIncrement || f.PrimaryKey && SequenceDefault(f) != ""
+}
+
+// SequenceDefault returns the sequence behind a nextval() default, e.g.
+// order_number_seq for nextval('order_number_seq'::regclass), or "" when the
+// field has no such default
+func SequenceDefault(f *schema.Field) string {
+	value := strings.TrimSpace(f.DefaultValue)
+	if len(value) < len("nextval()") || !strings.EqualFold(value[:len("nextval(")], "nextval(") || !strings.HasSuffix(value, ")") {
+		return ""
+	}
+	sequence := strings.TrimSpace(value[len("nextval(") : len(value)-1])
+	sequence = strings.TrimSuffix(sequence, "::regclass")
+	return strings.Trim(sequence, "'\"")
+}
+
+// AttributesOnlyChange reports whether current and target differ only in
+// their NOT NULL setting and/or default value, so the column type is kept
+func AttributesOnlyChange(current, target *schema.Field) bool {
+	if current == nil || target == nil || fieldsEqual(current, target) {
+		return false
+	}
+	withCurrentAttributes := *target
+	withCurrentAttributes.NotNull = current.NotNull
+	withCurrentAttributes.DefaultValue = current.DefaultValue
+	return fieldsEqual(current, &withCurrentAttributes)
+}
+
+// PrimaryKeyAdded reports whether an existing column becomes the primary key
+func PrimaryKeyAdded(current, target *schema.Field) bool {
+	return current != nil && target != nil && !current.PrimaryKey && target.PrimaryKey
+}
+
+// PrimaryKeyChanged reports whether an existing column joins or leaves the
+// primary key
+func PrimaryKeyChanged(current, target *schema.Field) bool {
+	return current != nil && target != nil && current.PrimaryKey != target.PrimaryKey
+}
+
+// FieldsEqual reports whether two field definitions match in the database
+func FieldsEqual(current, target *schema.Field) bool {
+	return fieldsEqual(current, target)
+}
+
+// PrimaryKeyStrategyChanged reports whether an auto-increment primary key
+// switches between a serial (sequence default) and an identity column
+func PrimaryKeyStrategyChanged(current, target *schema.Field) bool {
+	return current != nil && target != nil && current.PrimaryKey && target.PrimaryKey &&
+		isAutoIncrementField(current) && isAutoIncrementField(target) &&
+		IsIdentity(current) != IsIdentity(target)
+}
+
+// IsIdentity reports whether a field is an identity column. Models declare
+// one with the identity tag, e.g. `gorm:"primaryKey;identity"`.
+func IsIdentity(f *schema.Field) bool {
+	_, ok := f.TagSettings["IDENTITY"]
+	return ok
+}
+
+// DefaultChanged reports whether current and target have different defaults
+func DefaultChanged(current, target *schema.Field) bool {
+	return normalizeDefaultValue(current.DefaultValue) != normalizeDefaultValue(target.DefaultValue)
+}
+
+// NormalizedType returns the type a field is compared on, the same for a
+// Go type and the database types it maps to, e.g. "varchar" for string and
+// text columns
+func NormalizedType(f *schema.Field) string {
+	return normalizeDBType(f.DataType)
+}
+
 // normalizeDBType normalizes Go/GORM/Postgres types for DB comparison
 func normalizeDBType(dt schema.DataType) string {
 	dtStr := strings.ToLower(string(dt))
 	if dtStr == "int" || dtStr == "int32" || dtStr == "int4" || dtStr == "int64" || dtStr == "int8" || dtStr == "uint" || dtStr == "bigint" {
 		return "bigint"
 	}
-	if dtStr == "float64" || dtStr == "float32" || dtStr == "float" || dtStr == "real" || dtStr == "numeric" || dtStr == "decimal" || strings.HasPrefix(dtStr, "decimal(") || dtStr == "float8" || dtStr == "double precision" {
+	if dtStr == "float64" || dtStr == "float32" || dtStr == "float" || dtStr == "real" || dtStr == "numeric" || dtStr == "decimal" || strings.HasPrefix(dtStr, "decimal(") || strings.HasPrefix(dtStr, "numeric(") || dtStr == "float8" || dtStr == "double precision" {
 		return "decimal"
 	}
 	if dtStr == "string" || dtStr == "varchar" || dtStr == "text" || dtStr == "character varying" {
 		return "varchar"
 	}
-	if dtStr == "bool" || dtStr == "boolean" {
+	// MySQL stores booleans as TINYINT(1)
+	if dtStr == "bool" || dtStr == "boolean" || dtStr == "tinyint(1)" {
 		return "boolean"
 	}
 	if dtStr == "time" || dtStr == "timestamp" || dtStr == "timestamp without time zone" || dtStr == "timestamp with time zone" {
@@ -729,6 +1582,9 @@ func normalizeDBType(dt schema.DataType) string {
 	if dtStr == "json" || dtStr == "jsonb" {
 		return "jsonb"
 	}
+	if dtStr == "bytes" || dtStr == "bytea" || dtStr == "blob" {
+		return "bytes"
+	}
 	return dtStr
 }
 
@@ -738,13 +1594,13 @@ func normalizeDefaultValue(dv string) string {
 		return ""
 	}
 
+	if sequence := SequenceDefault(&schema.Field{DefaultValue: dv}); sequence != "" {
+		return "nextval(" + strings.ToLower(sequence) + ")"
+	}
+
 	dv = strings.Trim(dv, "'\"")
 	dv = strings.ToLower(dv)
 
-	if strings.HasPrefix(dv, "nextval") {
-		return "auto_increment"
-	}
-
 	switch dv {
 	case "null", "default null":
 		return ""
@@ -759,6 +1615,39 @@ func normalizeDefaultValue(dv string) string {
 	return dv
 }
 
+// MigrationIgnored reports whether a model field opts out of migrations with
+// gorm's `gorm:"-:migration"` tag or a `migrate:"-"` tag. Such columns are
+// read by gorm but managed elsewhere, e.g. by triggers.
+func MigrationIgnored(field *schema.Field) bool {
+	if val, ok := field.TagSettings["-"]; ok {
+		if val = strings.ToLower(strings.TrimSpace(val)); val == "migration" || val == "all" {
+			return true
+		}
+	}
+	return field.StructField.Tag.Get("migrate") == "-"
+}
+
+// binarySerializer reports whether a field is stored through a serializer
+// that writes binary data, such as gob, and has no explicit column type
+func binarySerializer(field *schema.Field) bool {
+	if field.Serializer == nil {
+		return false
+	}
+	if _, ok := field.TagSettings["TYPE"]; ok {
+		return false
+	}
+	name := field.TagSettings["SERIALIZER"]
+	if name == "" {
+		// Set through the json tag
+		return false
+	}
+	switch strings.ToLower(name) {
+	case "json", "unixtime":
+		return false
+	}
+	return true
+}
+
 // isRelationshipField checks if a field is a relationship field (not a DB column)
 func isRelationshipField(field *schema.Field) bool {
 	// Skip if FieldType is nil (database-extracted fields may not have this)
@@ -770,6 +1659,17 @@ func isRelationshipField(field *schema.Field) bool {
 		return true
 	}
 
+	// Serialized values and byte slices are stored in a column
+	if field.Serializer != nil || field.DataType == schema.Bytes {
+		return false
+	}
+
+	// So are structs gorm knows a column type for, such as time.Time and
+	// driver.Valuer types like decimal.Decimal
+	if field.DataType != "" && indirectType(field.FieldType).Kind() == reflect.Struct {
+		return false
+	}
+
 	// Check if it's a struct pointer (relationship field)
 	if field.FieldType.Kind() == reflect.Ptr && field.FieldType.Elem().Kind() == reflect.Struct {
 		return true
@@ -792,19 +1692,245 @@ func isRelationshipField(field *schema.Field) bool {
 	return false
 }
 
+// parseCheckConstraints collects the check constraints declared through
+// `check` tags, in field order. It mirrors GORM's naming: `check:name,expr`
+// uses the given name, otherwise the constraint is named chk_<table>_<column>.
+func parseCheckConstraints(s *schema.Schema) []*schema.CheckConstraint {
+	var checks []*schema.CheckConstraint
+	if s == nil {
+		return checks
+	}
+	for _, field := range s.Fields {
+		if field == nil || field.TagSettings == nil {
+			continue
+		}
+		chk := field.TagSettings["CHECK"]
+		if chk == "" {
+			continue
+		}
+		names := strings.Split(chk, ",")
+		if len(names) > 1 && checkNamePattern.MatchString(names[0]) {
+			checks = append(checks, &schema.CheckConstraint{Name: names[0], Constraint: strings.Join(names[1:], ","), Field: field})
+			continue
+		}
+		if names[0] == "" {
+			chk = strings.Join(names[1:], ",")
+		}
+		name := strings.ReplaceAll(fmt.Sprintf("chk_%s_%s", s.Table, field.DBName), ".", "_")
+		checks = append(checks, &schema.CheckConstraint{Name: name, Constraint: chk, Field: field})
+	}
+	return checks
+}
+
+// fieldOrder returns the distinct column names of fields in declared order
+func fieldOrder(fields []*schema.Field) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if field == nil || seen[field.DBName] {
+			continue
+		}
+		seen[field.DBName] = true
+		names = append(names, field.DBName)
+	}
+	return names
+}
+
+// parseUniqueConstraints collects the unique constraints declared through
+// `uniqueConstraint` tags. A bare tag is named uq_<table>_<column>; columns
+// sharing a name form one constraint, in field order.
+func parseUniqueConstraints(s *schema.Schema) []UniqueConstraint {
+	var uniques []UniqueConstraint
+	if s == nil {
+		return uniques
+	}
+	position := make(map[string]int)
+	for _, field := range s.Fields {
+		if field == nil || field.TagSettings == nil {
+			continue
+		}
+		name, ok := field.TagSettings["UNIQUECONSTRAINT"]
+		if !ok {
+			continue
+		}
+		if name == "" || name == "UNIQUECONSTRAINT" {
+			name = strings.ReplaceAll(fmt.Sprintf("uq_%s_%s", s.Table, field.DBName), ".", "_")
+		}
+		if i, seen := position[name]; seen {
+			uniques[i].Columns = append(uniques[i].Columns, field.DBName)
+			continue
+		}
+		position[name] = len(uniques)
+		uniques = append(uniques, UniqueConstraint{Name: name, Columns: []string{field.DBName}})
+	}
+	return uniques
+}
+
 // indexesEqual compares two schema.Index for relevant diff purposes
 func indexesEqual(a, b *schema.Index) bool {
-	if a.Name != b.Name || a.Option != b.Option || len(a.Fields) != len(b.Fields) {
+	if a.Name != b.Name || indexClass(a) != indexClass(b) || len(a.Fields) != len(b.Fields) {
 		return false
 	}
-	for i := range a.Fields {
-		if a.Fields[i].DBName != b.Fields[i].DBName {
+	if normalizePredicate(IndexPredicate(a)) != normalizePredicate(IndexPredicate(b)) {
+		return false
+	}
+	aColumns := indexColumns(a)
+	bColumns := indexColumns(b)
+	for i := range aColumns {
+		if aColumns[i] != bColumns[i] {
 			return false
 		}
 	}
 	return true
 }
 
+// IndexPredicate returns the WHERE predicate of a partial index, declared with
+// a where: tag or an option:WHERE ... tag
+func IndexPredicate(idx *schema.Index) string {
+	if idx.Where != "" {
+		return strings.TrimSpace(idx.Where)
+	}
+	option := strings.TrimSpace(idx.Option)
+	if len(option) > len("WHERE ") && strings.EqualFold(option[:len("WHERE ")], "WHERE ") {
+		return strings.TrimSpace(option[len("WHERE "):])
+	}
+	return ""
+}
+
+// normalizePredicate rewrites an index predicate so that introspected and
+// declared forms compare equal: whitespace is collapsed, keywords and
+// unquoted identifiers are upper-cased and redundant parentheses, such as the
+// ones Postgres wraps around the whole predicate and each AND operand, are
+// removed
+func normalizePredicate(predicate string) string {
+	p := strings.Join(strings.Fields(predicate), " ")
+	p = strings.ReplaceAll(strings.ReplaceAll(p, "( ", "("), " )", ")")
+	for strings.HasPrefix(p, "(") && strings.HasSuffix(p, ")") && balancedParens(p[1:len(p)-1]) {
+		p = strings.TrimSpace(p[1 : len(p)-1])
+	}
+
+	if operands := splitTopLevel(p, " OR "); len(operands) > 1 {
+		for i, operand := range operands {
+			operands[i] = normalizePredicate(operand)
+		}
+		return strings.Join(operands, " OR ")
+	}
+	if operands := splitTopLevel(p, " AND "); len(operands) > 1 {
+		for i, operand := range operands {
+			operands[i] = normalizePredicate(operand)
+			// OR binds looser than AND, so its parentheses must stay
+			if len(splitTopLevel(operands[i], " OR ")) > 1 {
+				operands[i] = "(" + operands[i] + ")"
+			}
+		}
+		return strings.Join(operands, " AND ")
+	}
+
+	return upperOutsideQuotes(p)
+}
+
+// splitTopLevel splits s on sep, case-insensitively, outside parentheses and
+// quotes
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && i+len(sep) <= len(s) && strings.EqualFold(s[i:i+len(sep)], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// upperOutsideQuotes upper-cases s except for quoted literals and identifiers
+func upperOutsideQuotes(s string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		default:
+			c = toUpper(c)
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// indexClass returns the kind of an index: UNIQUE, FULLTEXT, SPATIAL or empty.
+// GORM's uniqueIndex tag sets the class while introspection reports
+// uniqueness through the option, so both are taken into account.
+func indexClass(idx *schema.Index) string {
+	class := strings.ToUpper(idx.Class)
+	if class == "" && strings.ToUpper(idx.Option) == "UNIQUE" {
+		return "UNIQUE"
+	}
+	return class
+}
+
+// indexDefinition identifies an index by what it covers rather than by its
+// name: its class, type, predicate and set of columns
+func indexDefinition(idx *schema.Index) string {
+	columns := indexColumns(idx)
+	sort.Strings(columns)
+	indexType := strings.ToUpper(idx.Type)
+	if indexType == "" {
+		indexType = "BTREE"
+	}
+	return strings.Join([]string{indexClass(idx), indexType, normalizePredicate(IndexPredicate(idx)), strings.Join(columns, ",")}, "|")
+}
+
+// sortedIndexNames returns the names of indexes in order
+func sortedIndexNames(indexes map[string]*schema.Index) []string {
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// indexColumns returns the lowercased column names of an index in key order.
+// Model indexes carry GORM's tag priority while introspected indexes carry
+// their ordinal position, so a stable sort on priority aligns both sides.
+func indexColumns(idx *schema.Index) []string {
+	fields := make([]schema.IndexOption, len(idx.Fields))
+	copy(fields, idx.Fields)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Priority < fields[j].Priority
+	})
+
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f.Field == nil {
+			columns = append(columns, "")
+			continue
+		}
+		columns = append(columns, strings.ToLower(f.DBName))
+	}
+	return columns
+}
+
 // toExportedFieldName converts snake_case or lower to ExportedCamelCase
 func toExportedFieldName(name string) string {
 	if name == "" {
@@ -878,6 +2004,85 @@ func normalizeFieldName(name string) string {
 	return string(result)
 }
 
+// relationshipKey identifies a belongs-to relationship by its foreign key column
+func relationshipKey(rel *schema.Relationship) string {
+	if rel == nil {
+		return ""
+	}
+	if len(rel.References) > 0 && rel.References[0] != nil && rel.References[0].ForeignKey != nil {
+		return strings.ToLower(rel.References[0].ForeignKey.DBName)
+	}
+	if rel.Field != nil {
+		return strings.ToLower(rel.Field.DBName)
+	}
+	return ""
+}
+
+// primaryKeyField returns the primary key column of a schema, if any
+func primaryKeyField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.PrimaryKey {
+			return field
+		}
+	}
+	return nil
+}
+
+// withConstraintTag returns a copy of a foreign key column carrying the
+// `constraint` tag of the relationship field, overridden by the actions the
+// model declares, so the ON DELETE and ON UPDATE actions travel with the
+// relationship
+func withConstraintTag(fkField, relField *schema.Field, action FKAction) *schema.Field {
+	var constraint string
+	if relField != nil {
+		constraint = relField.TagSettings["CONSTRAINT"]
+	}
+	if action.OnDelete != "" || action.OnUpdate != "" {
+		settings := schema.ParseTagSetting(constraint, ",")
+		onDelete, onUpdate := settings["ONDELETE"], settings["ONUPDATE"]
+		if action.OnDelete != "" {
+			onDelete = action.OnDelete
+		}
+		if action.OnUpdate != "" {
+			onUpdate = action.OnUpdate
+		}
+		constraint = fmt.Sprintf("OnDelete:%s,OnUpdate:%s", onDelete, onUpdate)
+	}
+	if constraint == "" {
+		return fkField
+	}
+	field := *fkField
+	field.TagSettings = make(map[string]string, len(fkField.TagSettings)+1)
+	for key, value := range fkField.TagSettings {
+		field.TagSettings[key] = value
+	}
+	field.TagSettings["CONSTRAINT"] = constraint
+	return &field
+}
+
+// ForeignKeyActions returns the ON DELETE and ON UPDATE actions of a foreign
+// key, as declared with `constraint:OnDelete:...,OnUpdate:...` or read from
+// the database. An action that is not set is empty.
+func ForeignKeyActions(rel *schema.Relationship) (onDelete, onUpdate string) {
+	if rel == nil || rel.Field == nil || rel.Field.TagSettings["CONSTRAINT"] == "" {
+		return "", ""
+	}
+	settings := schema.ParseTagSetting(rel.Field.TagSettings["CONSTRAINT"], ",")
+	action := func(value string) string {
+		return strings.ToUpper(strings.Join(strings.Fields(value), " "))
+	}
+	return action(settings["ONDELETE"]), action(settings["ONUPDATE"])
+}
+
+// actionsChanged reports whether the target declares an ON DELETE or ON
+// UPDATE action other than the current one
+func actionsChanged(current, target *schema.Relationship) bool {
+	currentDelete, currentUpdate := ForeignKeyActions(current)
+	targetDelete, targetUpdate := ForeignKeyActions(target)
+	return (targetDelete != "" && targetDelete != currentDelete) ||
+		(targetUpdate != "" && targetUpdate != currentUpdate)
+}
+
 func relationshipsEqual(source, target *schema.Relationship) bool {
 	if source == nil || target == nil {
 		return false
@@ -0,0 +1,476 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func partialIndex(where string) *schema.Index {
+	return &schema.Index{
+		Name:   "idx_users_email",
+		Fields: []schema.IndexOption{{Field: &schema.Field{DBName: "email"}}},
+		Where:  where,
+	}
+}
+
+func TestIndexesEqual_PartialIndexPredicates(t *testing.T) {
+	equal := []struct{ introspected, declared string }{
+		{"(deleted_at IS NULL)", "deleted_at IS NULL"},
+		{"((deleted_at IS NULL) AND (active = true))", "deleted_at IS NULL AND active = true"},
+		{"(deleted_at IS NULL)", "deleted_at  is   null"},
+		{"((status)::text = 'active'::text)", "(status)::text = 'active'::text"},
+		{"((a = 1) OR (b = 2))", "a = 1 OR b = 2"},
+		{"((a = 1) AND ((b = 2) OR (c = 3)))", "a = 1 AND (b = 2 OR c = 3)"},
+	}
+	for _, tc := range equal {
+		assert.True(t, indexesEqual(partialIndex(tc.introspected), partialIndex(tc.declared)),
+			"%q should equal %q", tc.introspected, tc.declared)
+	}
+
+	different := []struct{ introspected, declared string }{
+		{"(deleted_at IS NULL)", "deleted_at IS NOT NULL"},
+		{"((a = 1) AND ((b = 2) OR (c = 3)))", "a = 1 AND b = 2 OR c = 3"},
+		{"(status = 'Active')", "status = 'active'"},
+		{"", "deleted_at IS NULL"},
+	}
+	for _, tc := range different {
+		assert.False(t, indexesEqual(partialIndex(tc.introspected), partialIndex(tc.declared)),
+			"%q should differ from %q", tc.introspected, tc.declared)
+	}
+}
+
+func TestIndexPredicate_FromOption(t *testing.T) {
+	idx := &schema.Index{Option: "WHERE deleted_at IS NULL"}
+	assert.Equal(t, "deleted_at IS NULL", IndexPredicate(idx))
+	assert.True(t, indexesEqual(
+		&schema.Index{Name: "idx", Option: "WHERE deleted_at IS NULL"},
+		&schema.Index{Name: "idx", Where: "(deleted_at IS NULL)"},
+	))
+	assert.Empty(t, IndexPredicate(&schema.Index{Option: "UNIQUE"}))
+}
+
+func TestCompareSchemas_MissingReferenceRows(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE currencies (code text PRIMARY KEY, name text)").Error)
+	require.NoError(t, db.Exec("INSERT INTO currencies (code, name) VALUES ('USD', 'US Dollar'), ('GBP', 'Pound')").Error)
+
+	comparer := NewSchemaComparer(db)
+	comparer.SetReferenceData([]ReferenceData{
+		{Table: "currencies", Rows: []map[string]any{
+			{"code": "USD", "name": "US Dollar"},
+			{"code": "EUR", "name": "Euro"},
+			{"code": "GBP", "name": "Pound Sterling"},
+		}},
+		{Table: "countries", Rows: []map[string]any{{"code": "FR"}}},
+	})
+
+	schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.NoError(t, err)
+	assert.Equal(t, []ReferenceData{
+		{Table: "currencies", Rows: []map[string]any{{"code": "EUR", "name": "Euro"}}},
+		{Table: "countries", Rows: []map[string]any{{"code": "FR"}}},
+	}, schemaDiff.RowsToInsert)
+
+	// A row whose key exists with other values is updated, not inserted again
+	assert.Equal(t, []ReferenceRowUpdate{{
+		Table:    "currencies",
+		Key:      map[string]any{"code": "GBP"},
+		Values:   map[string]any{"name": "Pound Sterling"},
+		Previous: map[string]any{"name": "Pound"},
+	}}, schemaDiff.RowsToUpdate)
+}
+
+func TestCompareSchemas_MaterializedViewsOnlyOnPostgres(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	comparer := NewSchemaComparer(db)
+	comparer.SetMaterializedViews([]MaterializedView{
+		{Name: "order_totals", SQL: "SELECT user_id, SUM(amount) AS total FROM view_orders GROUP BY user_id"},
+	})
+	_, err = comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.ErrorContains(t, err, "materialized views are only supported on Postgres")
+}
+
+func TestCompareSchemas_EnumsOnlyOnPostgres(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	comparer := NewSchemaComparer(db)
+	comparer.SetEnums([]Enum{{Name: "mood", Values: []string{"happy", "sad"}}})
+	schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.NoError(t, err)
+	assert.Empty(t, schemaDiff.EnumsToCreate)
+	assert.Empty(t, schemaDiff.EnumsToAlter)
+}
+
+// decimalPrice declares a wider amount than the numeric(10,2) column
+type decimalPrice struct {
+	ID     uint    `gorm:"primaryKey"`
+	Amount float64 `gorm:"precision:12;scale:4"`
+}
+
+func TestCompareSchemas_DecimalPrecisionAndScale(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	comparer := NewSchemaComparer(db)
+	target, err := comparer.GetModelSchemas(&decimalPrice{})
+	require.NoError(t, err)
+	// decimal_prices as introspected from Postgres
+	amount := &schema.Field{DBName: "amount", DataType: "numeric", Precision: 10, Scale: 2}
+	current := map[string]*schema.Schema{
+		"decimalPrice": {Table: "decimal_prices", Fields: []*schema.Field{
+			{DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true, NotNull: true},
+			amount,
+		}},
+	}
+
+	schemaDiff, err := comparer.CompareSchemas(current, target)
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	modified := schemaDiff.TablesToModify[0]
+	require.Len(t, modified.FieldsToModify, 1)
+	assert.Equal(t, "amount", modified.FieldsToModify[0].DBName)
+	assert.True(t, DecimalSizeOnlyChange(amount, modified.FieldsToModify[0]))
+
+	// A float without a precision tag matches any numeric column
+	assert.True(t, fieldsEqual(amount, &schema.Field{DBName: "amount", DataType: schema.Float}))
+}
+
+// typedPrice declares its precision and scale through the type tag
+type typedPrice struct {
+	ID    uint    `gorm:"primaryKey"`
+	Price float64 `gorm:"type:decimal(12,2)"`
+	Tax   float64 `gorm:"type:numeric(8, 3)"`
+}
+
+func TestCompareSchemas_DecimalTypeTag(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	comparer := NewSchemaComparer(db)
+	target, err := comparer.GetModelSchemas(&typedPrice{})
+	require.NoError(t, err)
+	// typed_prices as introspected from Postgres, with price's precision
+	// given by the test case
+	compare := func(pricePrecision int) []TableDiff {
+		current := map[string]*schema.Schema{
+			"typedPrice": {Table: "typed_prices", Fields: []*schema.Field{
+				{DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true, NotNull: true},
+				{DBName: "price", DataType: "numeric", Precision: pricePrecision, Scale: 2},
+				{DBName: "tax", DataType: "numeric", Precision: 8, Scale: 3},
+			}},
+		}
+		schemaDiff, err := comparer.CompareSchemas(current, target)
+		require.NoError(t, err)
+		return schemaDiff.TablesToModify
+	}
+
+	// Re-running against the migrated table finds nothing to change
+	assert.Empty(t, compare(12))
+
+	modified := compare(10)
+	require.Len(t, modified, 1)
+	require.Len(t, modified[0].FieldsToModify, 1)
+	price := modified[0].FieldsToModify[0]
+	assert.Equal(t, "price", price.DBName)
+	assert.True(t, DecimalSizeOnlyChange(modified[0].PreviousFields["price"], price))
+	precision, scale := DecimalSize(price)
+	assert.Equal(t, []int{12, 2}, []int{precision, scale})
+}
+
+type sequencedOrder struct {
+	ID     uint  `gorm:"primaryKey"`
+	Number int64 `gorm:"default:nextval('order_number_seq')"`
+}
+
+func TestCompareSchemas_SequenceDefault(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	comparer := NewSchemaComparer(db)
+	target, err := comparer.GetModelSchemas(&sequencedOrder{})
+	require.NoError(t, err)
+	// sequenced_orders as introspected from Postgres, with number's default
+	// given by the test case
+	compare := func(numberDefault string) []TableDiff {
+		current := map[string]*schema.Schema{
+			"sequencedOrder": {Table: "sequenced_orders", Fields: []*schema.Field{
+				{DBName: "id", DataType: "int8", PrimaryKey: true, NotNull: true, DefaultValue: "nextval('sequenced_orders_id_seq'::regclass)"},
+				{DBName: "number", DataType: "int8", DefaultValue: numberDefault},
+			}},
+		}
+		schemaDiff, err := comparer.CompareSchemas(current, target)
+		require.NoError(t, err)
+		return schemaDiff.TablesToModify
+	}
+
+	assert.Empty(t, compare("nextval('order_number_seq'::regclass)"))
+
+	// A column gaining a sequence default, or drawing from another sequence,
+	// is modified rather than taken for an auto-increment key
+	for _, numberDefault := range []string{"", "0", "nextval('invoice_number_seq'::regclass)"} {
+		modified := compare(numberDefault)
+		require.Len(t, modified, 1, numberDefault)
+		require.Len(t, modified[0].FieldsToModify, 1, numberDefault)
+		number := modified[0].FieldsToModify[0]
+		assert.Equal(t, "number", number.DBName)
+		assert.True(t, AttributesOnlyChange(modified[0].PreviousFields["number"], number), numberDefault)
+		assert.Equal(t, "order_number_seq", SequenceDefault(number))
+	}
+}
+
+func TestCompareSchemas_DetectsTableRenames(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	table := func(name string, columns ...string) *schema.Schema {
+		fields := []*schema.Field{{DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true, NotNull: true}}
+		for _, column := range columns {
+			fields = append(fields, &schema.Field{DBName: column, DataType: "varchar"})
+		}
+		return &schema.Schema{Table: name, Fields: fields}
+	}
+	compare := func(comparer *SchemaComparer, current, target []*schema.Schema) *SchemaDiff {
+		currentSchemas, targetSchemas := make(map[string]*schema.Schema), make(map[string]*schema.Schema)
+		for _, s := range current {
+			currentSchemas[s.Table] = s
+		}
+		for _, s := range target {
+			targetSchemas[s.Table] = s
+		}
+		schemaDiff, err := comparer.CompareSchemas(currentSchemas, targetSchemas)
+		require.NoError(t, err)
+		return schemaDiff
+	}
+
+	// Detection is off by default, and a table that is not pruned is never
+	// taken over by a new model
+	comparer := NewSchemaComparer(db)
+	schemaDiff := compare(comparer,
+		[]*schema.Schema{table("people", "name", "email")},
+		[]*schema.Schema{table("users", "name", "email")})
+	assert.Empty(t, schemaDiff.TablesToRename)
+	require.Len(t, schemaDiff.TablesToCreate, 1)
+	comparer.SetRenameThreshold(1)
+	schemaDiff = compare(comparer,
+		[]*schema.Schema{table("people", "name", "email")},
+		[]*schema.Schema{table("users", "name", "email")})
+	assert.Empty(t, schemaDiff.TablesToRename)
+
+	comparer.SetPruneUnmanaged(true)
+	schemaDiff = compare(comparer,
+		[]*schema.Schema{table("people", "name", "email")},
+		[]*schema.Schema{table("users", "name", "email")})
+	assert.Equal(t, []TableRename{{OldName: "people", NewName: "users"}}, schemaDiff.TablesToRename)
+	assert.Empty(t, schemaDiff.TablesToCreate)
+	assert.Empty(t, schemaDiff.TablesToModify)
+	assert.Empty(t, schemaDiff.TablesToDrop)
+
+	// A column more or less is below a threshold of 1
+	changed := []*schema.Schema{table("people", "name", "email")}
+	renamedAndChanged := []*schema.Schema{table("users", "name", "email", "phone")}
+	schemaDiff = compare(comparer, changed, renamedAndChanged)
+	assert.Empty(t, schemaDiff.TablesToRename)
+	require.Len(t, schemaDiff.TablesToCreate, 1)
+
+	comparer.SetRenameThreshold(0.75)
+	schemaDiff = compare(comparer, changed, renamedAndChanged)
+	assert.Equal(t, []TableRename{{OldName: "people", NewName: "users"}}, schemaDiff.TablesToRename)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	require.Len(t, schemaDiff.TablesToModify[0].FieldsToAdd, 1)
+	assert.Equal(t, "phone", schemaDiff.TablesToModify[0].FieldsToAdd[0].DBName)
+
+	// Two old tables matching the same new table are ambiguous
+	comparer = NewSchemaComparer(db)
+	comparer.SetRenameThreshold(1)
+	comparer.SetPruneUnmanaged(true)
+	schemaDiff = compare(comparer,
+		[]*schema.Schema{table("people", "name"), table("members", "name")},
+		[]*schema.Schema{table("users", "name")})
+	assert.Empty(t, schemaDiff.TablesToRename)
+
+	comparer = NewSchemaComparer(db)
+	comparer.SetPruneUnmanaged(true)
+	comparer.SetRenameThreshold(0)
+	schemaDiff = compare(comparer,
+		[]*schema.Schema{table("people", "name", "email")},
+		[]*schema.Schema{table("users", "name", "email")})
+	assert.Empty(t, schemaDiff.TablesToRename)
+}
+
+func TestCompareTable_DetectsColumnRenames(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	table := func(fields ...*schema.Field) *schema.Schema {
+		id := &schema.Field{DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true, NotNull: true}
+		return &schema.Schema{Table: "people", Fields: append([]*schema.Field{id}, fields...)}
+	}
+	current := table(&schema.Field{DBName: "name", DataType: "varchar"}, &schema.Field{DBName: "age", DataType: "int8"})
+	target := table(&schema.Field{DBName: "full_name", DataType: "varchar", NotNull: true}, &schema.Field{DBName: "age", DataType: "int8"})
+
+	// Off by default
+	comparer := NewSchemaComparer(db)
+	tableDiff := comparer.CompareTable(current, target)
+	assert.Empty(t, tableDiff.FieldsToRename)
+	require.Len(t, tableDiff.FieldsToDrop, 1)
+	require.Len(t, tableDiff.FieldsToAdd, 1)
+
+	comparer.SetDetectColumnRenames(true)
+	tableDiff = comparer.CompareTable(current, target)
+	assert.Equal(t, []ColumnRename{{OldName: "name", NewName: "full_name"}}, tableDiff.FieldsToRename)
+	assert.Empty(t, tableDiff.FieldsToDrop)
+	assert.Empty(t, tableDiff.FieldsToAdd)
+	// The column also became NOT NULL
+	require.Len(t, tableDiff.FieldsToModify, 1)
+	assert.Equal(t, "full_name", tableDiff.FieldsToModify[0].DBName)
+	assert.Equal(t, "full_name", tableDiff.PreviousFields["full_name"].DBName)
+	assert.False(t, tableDiff.PreviousFields["full_name"].NotNull)
+
+	// Columns of different types are not renamed
+	tableDiff = comparer.CompareTable(current, table(&schema.Field{DBName: "full_name", DataType: "bool"}, &schema.Field{DBName: "age", DataType: "int8"}))
+	assert.Empty(t, tableDiff.FieldsToRename)
+
+	// Nor is any column when more than one was dropped or added
+	tableDiff = comparer.CompareTable(current, table(&schema.Field{DBName: "full_name", DataType: "varchar"}, &schema.Field{DBName: "years", DataType: "int8"}))
+	assert.Empty(t, tableDiff.FieldsToRename)
+	assert.Len(t, tableDiff.FieldsToDrop, 2)
+	assert.Len(t, tableDiff.FieldsToAdd, 2)
+	tableDiff = comparer.CompareTable(current, table(&schema.Field{DBName: "full_name", DataType: "varchar"}, &schema.Field{DBName: "age", DataType: "int8"}, &schema.Field{DBName: "nickname", DataType: "varchar"}))
+	assert.Empty(t, tableDiff.FieldsToRename)
+	assert.Len(t, tableDiff.FieldsToAdd, 2)
+}
+
+type indexedContact struct {
+	ID    uint   `gorm:"primaryKey"`
+	Email string `gorm:"uniqueIndex"`
+	Phone string `gorm:"index"`
+}
+
+func TestCompareSchemas_IndexNamedByDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&indexedContact{}))
+	// The same indexes as the model declares, under the database's names
+	require.NoError(t, db.Exec(`DROP INDEX idx_indexed_contacts_email`).Error)
+	require.NoError(t, db.Exec(`DROP INDEX idx_indexed_contacts_phone`).Error)
+	require.NoError(t, db.Exec(`CREATE UNIQUE INDEX indexed_contacts_email_key ON indexed_contacts (email)`).Error)
+	require.NoError(t, db.Exec(`CREATE INDEX indexed_contacts_phone_idx ON indexed_contacts (phone)`).Error)
+
+	comparer := NewSchemaComparer(db)
+	models, err := comparer.GetModelSchemas(&indexedContact{})
+	require.NoError(t, err)
+	target := make(map[string]*schema.Schema)
+	for _, s := range models {
+		target[s.Table] = s
+	}
+	compare := func() TableDiff {
+		current, err := comparer.GetCurrentSchema()
+		require.NoError(t, err)
+		return comparer.CompareTable(current["indexed_contacts"], target["indexed_contacts"])
+	}
+
+	unchanged := compare()
+	assert.Empty(t, unchanged.IndexesToAdd)
+	assert.Empty(t, unchanged.IndexesToDrop)
+	assert.Empty(t, unchanged.IndexesToModify)
+
+	// An index on other columns is still replaced
+	require.NoError(t, db.Exec(`DROP INDEX indexed_contacts_phone_idx`).Error)
+	require.NoError(t, db.Exec(`CREATE INDEX indexed_contacts_phone_idx ON indexed_contacts (email, phone)`).Error)
+	modified := compare()
+	require.Len(t, modified.IndexesToAdd, 1)
+	assert.Equal(t, "idx_indexed_contacts_phone", modified.IndexesToAdd[0].Name)
+	require.Len(t, modified.IndexesToDrop, 1)
+	assert.Equal(t, "indexed_contacts_phone_idx", modified.IndexesToDrop[0].Name)
+}
+
+func TestCompareTable_ModifiedIndex(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&indexedContact{}))
+	// The phone index keeps its name but becomes unique in the database
+	require.NoError(t, db.Exec(`DROP INDEX idx_indexed_contacts_phone`).Error)
+	require.NoError(t, db.Exec(`CREATE UNIQUE INDEX idx_indexed_contacts_phone ON indexed_contacts (phone)`).Error)
+
+	comparer := NewSchemaComparer(db)
+	models, err := comparer.GetModelSchemas(&indexedContact{})
+	require.NoError(t, err)
+	current, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	var target *schema.Schema
+	for _, s := range models {
+		target = s
+	}
+
+	tableDiff := comparer.CompareTable(current["indexed_contacts"], target)
+	assert.Empty(t, tableDiff.IndexesToAdd)
+	assert.Empty(t, tableDiff.IndexesToDrop)
+	require.Len(t, tableDiff.IndexesToModify, 1)
+	assert.Equal(t, "idx_indexed_contacts_phone", tableDiff.IndexesToModify[0].Name)
+	require.Contains(t, tableDiff.PreviousIndexes, "idx_indexed_contacts_phone")
+	assert.False(t, (&TableDiff{IndexesToModify: tableDiff.IndexesToModify}).IsEmpty())
+}
+
+// uniqueBooking declares a unique constraint, which only Postgres introspects
+type uniqueBooking struct {
+	ID     uint   `gorm:"primaryKey"`
+	RoomID uint   `gorm:"uniqueConstraint:uq_unique_bookings_room_night"`
+	Night  string `gorm:"uniqueConstraint:uq_unique_bookings_room_night"`
+}
+
+func TestCompareTable_UniqueConstraintsOnlyOnPostgres(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE unique_bookings (id integer PRIMARY KEY, room_id integer, night text, CONSTRAINT uq_unique_bookings_room_night UNIQUE (room_id, night))`).Error)
+
+	comparer := NewSchemaComparer(db)
+	models, err := comparer.GetModelSchemas(&uniqueBooking{})
+	require.NoError(t, err)
+	current, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	var target *schema.Schema
+	for _, s := range models {
+		target = s
+	}
+
+	tableDiff := comparer.CompareTable(current["unique_bookings"], target)
+	assert.Empty(t, tableDiff.UniquesToAdd, "an existing table's constraints are not compared off Postgres")
+	assert.Empty(t, tableDiff.UniquesToDrop)
+}
+
+// commentedNote declares a table comment, which only Postgres introspects
+type commentedNote struct {
+	ID uint `gorm:"primaryKey"`
+}
+
+func (commentedNote) TableComment() string { return "Notes" }
+
+func TestCompareTable_CommentsOnlyOnPostgres(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&commentedNote{}))
+
+	comparer := NewSchemaComparer(db)
+	models, err := comparer.GetModelSchemas(&commentedNote{})
+	require.NoError(t, err)
+	current, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	var target *schema.Schema
+	for _, s := range models {
+		target = s
+	}
+
+	tableDiff := comparer.CompareTable(current["commented_notes"], target)
+	assert.Empty(t, tableDiff.Comment, "an existing table's comment is not compared off Postgres")
+	assert.Empty(t, tableDiff.PreviousComment)
+	assert.Equal(t, "Notes", comparer.CompareTable(&schema.Schema{Table: "commented_notes"}, target).Comment)
+}
@@ -29,7 +29,7 @@ func (m *Migrator) Register(migration *migration.Migration) {
 
 // ensureVersionTable creates the version tracking table if it doesn't exist
 func (m *Migrator) ensureVersionTable() error {
-	return m.db.AutoMigrate(&migration.MigrationRecord{})
+	return migration.EnsureVersionTable(m.db)
 }
 
 // GetAppliedVersions returns a map of applied migration versions
@@ -80,7 +80,7 @@ func (m *Migrator) Up() error {
 // Down rolls back the last applied migration
 func (m *Migrator) Down() error {
 	var lastRecord migration.MigrationRecord
-	if err := m.db.Order("applied_at DESC").First(&lastRecord).Error; err != nil {
+	if err := m.db.Order(migration.LatestFirst).First(&lastRecord).Error; err != nil {
 		return err
 	}
 
@@ -0,0 +1,37 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the migration commands, for callers to check with
+// errors.Is
+var (
+	// ErrNoRegistry means no model registry was set, see GlobalModelRegistry
+	ErrNoRegistry = errors.New("no model registry provided")
+	// ErrNoChanges means the models match the database and there is nothing
+	// to generate
+	ErrNoChanges = errors.New("no schema changes detected")
+	// ErrNoDatabaseURL means no database connection string was configured
+	ErrNoDatabaseURL = errors.New("DATABASE_URL not set in environment, .env file or config file")
+	// ErrDirtyState means the applied migrations no longer match the
+	// migration files, e.g. an applied migration's file is missing
+	ErrDirtyState = errors.New("migration state is dirty")
+)
+
+// MigrationApplyError is returned when a migration's Up fails or it cannot
+// be recorded as applied
+type MigrationApplyError struct {
+	Version string
+	Name    string
+	Err     error
+}
+
+func (e *MigrationApplyError) Error() string {
+	return fmt.Sprintf("failed to apply migration %s: %v", e.Name, e.Err)
+}
+
+func (e *MigrationApplyError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,110 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// dropObjectPattern matches a DROP of a table, index, view, type or sequence
+	dropObjectPattern = regexp.MustCompile(`(?is)^\s*DROP\s+(TABLE|INDEX|MATERIALIZED\s+VIEW|VIEW|TYPE|SEQUENCE)\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?([^\s;,(]+)`)
+	// createObjectPattern matches the CREATE that recreates a dropped object
+	createObjectPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:UNIQUE\s+)?(TABLE|INDEX|MATERIALIZED\s+VIEW|VIEW|TYPE|SEQUENCE)\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([^\s;,(]+)`)
+	// alterColumnPattern matches ALTER TABLE ... ADD COLUMN and DROP COLUMN
+	alterColumnPattern = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?([^\s]+)\s+(ADD|DROP)\s+COLUMN\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?([^\s;,]+)`)
+)
+
+// LintWarning is a statement in a migration's Up that its Down does not undo
+type LintWarning struct {
+	Version   string
+	Name      string
+	Statement string
+	Reason    string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("%s_%s: %s\n    %s", w.Version, w.Name, w.Reason, w.Statement)
+}
+
+// LintReversibility checks each migration file for statements in Up that
+// drop or add an object or column without the inverse statement in Down
+func (l *MigrationLoader) LintReversibility() ([]LintWarning, error) {
+	files, err := ListMigrationFiles(l.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []LintWarning
+	for _, f := range files {
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		up, err := l.extractSQLFromFunction(string(content), "Up")
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract SQL from %s: %w", f.Path, err)
+		}
+		down, err := l.extractSQLFromFunction(string(content), "Down")
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract SQL from %s: %w", f.Path, err)
+		}
+
+		for _, statement := range up {
+			if reason := irreversible(statement, down); reason != "" {
+				warnings = append(warnings, LintWarning{
+					Version:   f.Version,
+					Name:      f.Name,
+					Statement: strings.Join(strings.Fields(statement), " "),
+					Reason:    reason,
+				})
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// irreversible returns why an Up statement is not undone by any of the Down
+// statements, or "" when it is undone or is not a statement the lint checks
+func irreversible(statement string, down []string) string {
+	if m := dropObjectPattern.FindStringSubmatch(statement); m != nil {
+		kind, name := objectKind(m[1]), lintIdentifier(m[2])
+		for _, inverse := range down {
+			if c := createObjectPattern.FindStringSubmatch(inverse); c != nil && objectKind(c[1]) == kind && lintIdentifier(c[2]) == name {
+				return ""
+			}
+		}
+		return fmt.Sprintf("drops %s %s but Down does not recreate it", kind, name)
+	}
+
+	if m := alterColumnPattern.FindStringSubmatch(statement); m != nil {
+		table, action, column := lintIdentifier(m[1]), strings.ToUpper(m[2]), lintIdentifier(m[3])
+		inverseAction := "DROP"
+		if action == "DROP" {
+			inverseAction = "ADD"
+		}
+		for _, inverse := range down {
+			if c := alterColumnPattern.FindStringSubmatch(inverse); c != nil &&
+				lintIdentifier(c[1]) == table && strings.ToUpper(c[2]) == inverseAction && lintIdentifier(c[3]) == column {
+				return ""
+			}
+		}
+		if action == "DROP" {
+			return fmt.Sprintf("drops column %s.%s but Down does not add it back", table, column)
+		}
+		return fmt.Sprintf("adds column %s.%s but Down does not drop it", table, column)
+	}
+
+	return ""
+}
+
+// objectKind normalizes the object keyword of a DROP or CREATE
+func objectKind(kind string) string {
+	return strings.ToLower(strings.Join(strings.Fields(kind), " "))
+}
+
+// lintIdentifier normalizes a possibly quoted identifier for comparison
+func lintIdentifier(name string) string {
+	return strings.ToLower(strings.Trim(name, "\"`"))
+}
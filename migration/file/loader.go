@@ -2,9 +2,14 @@ package file
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +18,37 @@ import (
 	"gorm.io/gorm"
 )
 
+// migrationFilePattern matches migration file names, which start with a
+// 14-digit version
+var migrationFilePattern = regexp.MustCompile(`^\d{14}_.+\.go$`)
+
+// nonTransactionalPattern matches the option generated for migrations that
+// must run outside a transaction
+var nonTransactionalPattern = regexp.MustCompile(`(?m)^\s*NonTransactional:\s*true,`)
+
+// tagsPattern matches the `// migrate:tags a,b` directive of a migration file
+var tagsPattern = regexp.MustCompile(`(?m)^\s*//\s*migrate:tags\s+(.+)$`)
+
+// parseTags returns the tags declared in a migration file
+func parseTags(content []byte) []string {
+	var tags []string
+	for _, m := range tagsPattern.FindAllSubmatch(content, -1) {
+		for _, tag := range strings.Split(string(m[1]), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// isMigrationFile reports whether a directory entry is a migration file
+// rather than a helper or test file living alongside the migrations
+func isMigrationFile(file os.DirEntry) bool {
+	name := file.Name()
+	return !file.IsDir() && migrationFilePattern.MatchString(name) && !strings.HasSuffix(name, "_test.go")
+}
+
 // MigrationFile represents a single migration file
 type MigrationFile struct {
 	Path      string    // Full path to the migration file
@@ -39,9 +75,10 @@ type MigrationRecord struct {
 
 // MigrationLoader handles loading and managing migration files
 type MigrationLoader struct {
-	directory string
-	template  *MigrationTemplate
-	debug     bool
+	directory  string
+	template   *MigrationTemplate
+	debug      bool
+	savepoints bool
 }
 
 // NewMigrationLoader creates a new migration loader
@@ -64,6 +101,46 @@ func (l *MigrationLoader) SetDebug(debug bool) {
 	l.debug = debug
 }
 
+// SetSavepoints runs each statement of a migration under its own savepoint
+// when the migration runs in a transaction. A failing statement is rolled
+// back to its savepoint, leaving the transaction usable, and its error is
+// returned naming the statement.
+func (l *MigrationLoader) SetSavepoints(enabled bool) {
+	l.savepoints = enabled
+}
+
+// ListMigrationFiles returns the migration files in a directory, sorted by
+// version. A missing directory has no migration files.
+func ListMigrationFiles(directory string) ([]*MigrationFile, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	var files []*MigrationFile
+	for _, entry := range entries {
+		if !isMigrationFile(entry) {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ".go")
+		version, name, _ := strings.Cut(base, "_")
+		files = append(files, &MigrationFile{
+			Path:    filepath.Join(directory, entry.Name()),
+			Version: version,
+			Name:    name,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Version < files[j].Version
+	})
+
+	return files, nil
+}
+
 // FormatName formats a migration name according to the template
 func (t *MigrationTemplate) FormatName(name string) string {
 	if t.Name == "" {
@@ -95,10 +172,10 @@ func (l *MigrationLoader) LoadMigrations() ([]*migration.Migration, error) {
 		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
-	// Filter for .go files
+	// Filter for migration files
 	var goFiles []os.DirEntry
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".go") {
+		if isMigrationFile(file) {
 			goFiles = append(goFiles, file)
 		}
 	}
@@ -121,9 +198,9 @@ func (l *MigrationLoader) LoadMigrations() ([]*migration.Migration, error) {
 	return migrations, nil
 }
 
-// importMigrationFiles imports all Go files in the migrations directory
+// importMigrationFiles imports all migration files in the migrations directory
 func (l *MigrationLoader) importMigrationFiles() error {
-	// Read all .go files in the migrations directory
+	// Read all migration files in the migrations directory
 	files, err := os.ReadDir(l.directory)
 	if err != nil {
 		return fmt.Errorf("failed to read migrations directory: %w", err)
@@ -131,7 +208,7 @@ func (l *MigrationLoader) importMigrationFiles() error {
 
 	// Parse each migration file to extract migration information
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".go") {
+		if isMigrationFile(file) {
 			filePath := filepath.Join(l.directory, file.Name())
 			if err := l.parseMigrationFile(filePath); err != nil {
 				return fmt.Errorf("failed to parse migration file %s: %w", file.Name(), err)
@@ -159,17 +236,30 @@ func (l *MigrationLoader) parseMigrationFile(filePath string) error {
 	version := parts[0]
 	name := strings.Join(parts[1:], "_")
 
+	// The version is the creation timestamp; fall back to the file's
+	// modification time for versions in another format
+	createdAt, err := time.ParseInLocation(l.template.Version, version, time.Local)
+	if err != nil {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		createdAt = info.ModTime()
+	}
+
 	// Create migration object
 	migrationObj := &migration.Migration{
 		Version:   version,
 		Name:      name,
-		CreatedAt: time.Now(), // We don't have the exact creation time from the file
+		CreatedAt: createdAt,
 		Up: func(db *gorm.DB) error {
 			return l.executeMigrationSQL(db, string(content), "Up")
 		},
 		Down: func(db *gorm.DB) error {
 			return l.executeMigrationSQL(db, string(content), "Down")
 		},
+		NonTransactional: nonTransactionalPattern.Match(content),
+		Tags:             parseTags(content),
 	}
 
 	// Register the migration
@@ -186,66 +276,83 @@ func (l *MigrationLoader) executeMigrationSQL(db *gorm.DB, content, function str
 		return fmt.Errorf("failed to extract SQL from %s function: %w", function, err)
 	}
 
+	// Savepoints only exist inside a transaction
+	_, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter)
+	savepoints := l.savepoints && inTransaction
+
 	// Execute each SQL statement
-	for _, statement := range statements {
+	for i, statement := range statements {
+		savepoint := fmt.Sprintf("migration_statement_%d", i+1)
+		if savepoints {
+			if err := db.Exec("SAVEPOINT " + savepoint).Error; err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+		}
 		if err := db.Exec(statement).Error; err != nil {
-			return fmt.Errorf("failed to execute SQL: %w", err)
+			if !savepoints {
+				return fmt.Errorf("failed to execute SQL: %w", err)
+			}
+			if rollbackErr := db.Exec("ROLLBACK TO SAVEPOINT " + savepoint).Error; rollbackErr != nil {
+				return fmt.Errorf("failed to execute SQL: %w (rollback to savepoint failed: %v)", err, rollbackErr)
+			}
+			return fmt.Errorf("failed to execute statement %d of %s, rolled back to its savepoint: %w", i+1, function, err)
+		}
+		if savepoints {
+			if err := db.Exec("RELEASE SAVEPOINT " + savepoint).Error; err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
 		}
 	}
 
 	return nil
 }
 
-// extractSQLFromFunction extracts SQL statements from a specific function in the migration file
+// extractSQLFromFunction extracts SQL statements from a specific function in
+// the migration file: the string argument of each Exec call in the function
+// literal given for the Up or Down field, including nested closures
 func (l *MigrationLoader) extractSQLFromFunction(content, function string) ([]string, error) {
-	var statements []string
-
-	lines := strings.Split(content, "\n")
-	inFunction := false
+	f, err := parser.ParseFile(token.NewFileSet(), "", content, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse migration file: %w", err)
+	}
 
 	if l.debug {
 		fmt.Printf("[DEBUG] Looking for %s function\n", function)
 	}
 
-	for i, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-
-		// Check if we're entering the target function (anonymous function pattern)
-		if strings.Contains(line, fmt.Sprintf("%s: func(db *gorm.DB)", function)) {
-			inFunction = true
-			if l.debug {
-				fmt.Printf("[DEBUG] Entering %s function at line %d\n", function, i+1)
-			}
-			continue
+	var statements []string
+	var inspectErr error
+	ast.Inspect(f, func(node ast.Node) bool {
+		field, ok := node.(*ast.KeyValueExpr)
+		if !ok {
+			return inspectErr == nil
 		}
-
-		// Check if we're exiting the function
-		if inFunction && (strings.Contains(line, "return nil") || strings.Contains(line, "},")) {
-			if l.debug {
-				fmt.Printf("[DEBUG] Exiting %s function at line %d\n", function, i+1)
-			}
-			break
+		key, ok := field.Key.(*ast.Ident)
+		if !ok || key.Name != function {
+			return true
 		}
-
-		// Look for db.Exec calls
-		if inFunction && strings.Contains(line, "db.Exec") {
-			if l.debug {
-				fmt.Printf("[DEBUG] Found db.Exec at line %d: %s\n", i+1, trimmedLine)
-			}
-
-			// Extract SQL from this line and continue to next lines if needed
-			sql, _, err := l.extractMultiLineSQL(lines, i)
+		body, ok := field.Value.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		ast.Inspect(body, func(node ast.Node) bool {
+			sql, ok, err := execSQL(node)
 			if err != nil {
-				return nil, fmt.Errorf("failed to extract SQL at line %d: %w", i+1, err)
+				inspectErr = err
+				return false
 			}
-
-			if sql != "" {
+			if ok {
 				if l.debug {
 					fmt.Printf("[DEBUG] Extracted SQL: %s\n", sql)
 				}
 				statements = append(statements, sql)
 			}
-		}
+			return true
+		})
+		return false
+	})
+	if inspectErr != nil {
+		return nil, inspectErr
 	}
 
 	if l.debug {
@@ -254,48 +361,27 @@ func (l *MigrationLoader) extractSQLFromFunction(content, function string) ([]st
 	return statements, nil
 }
 
-// extractMultiLineSQL extracts SQL from a db.Exec call that may span multiple lines
-func (l *MigrationLoader) extractMultiLineSQL(lines []string, startLine int) (string, bool, error) {
-	var sql strings.Builder
-	backtickCount := 0
-	inSQL := false
-
-	for i := startLine; i < len(lines); i++ {
-		line := lines[i]
-
-		for j, char := range line {
-			if char == '`' {
-				backtickCount++
-
-				if backtickCount == 1 {
-					// First backtick - start collecting SQL
-					inSQL = true
-					continue
-				} else if backtickCount == 2 {
-					// Second backtick - end of SQL
-					inSQL = false
-					if l.debug {
-						fmt.Printf("[DEBUG] SQL extraction complete at line %d, char %d\n", i+1, j+1)
-					}
-					return sql.String(), true, nil
-				}
-			}
-
-			if inSQL {
-				sql.WriteRune(char)
-			}
-		}
-
-		// If we're in SQL and the line doesn't end with a backtick, add a newline
-		if inSQL && backtickCount == 1 {
-			sql.WriteString("\n")
-		}
+// execSQL returns the SQL of an Exec call whose first argument is a string
+// literal, such as db.Exec(`CREATE TABLE ...`)
+func execSQL(node ast.Node) (string, bool, error) {
+	call, ok := node.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false, nil
 	}
-
-	// If we get here, we didn't find a closing backtick
-	if inSQL {
-		return "", false, fmt.Errorf("unclosed backtick in SQL at line %d", startLine+1)
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Exec" {
+		return "", false, nil
 	}
-
-	return "", false, nil
+	if _, ok := selector.X.(*ast.Ident); !ok {
+		return "", false, nil
+	}
+	literal, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false, nil
+	}
+	sql, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid SQL string %s: %w", literal.Value, err)
+	}
+	return sql, true, nil
 }
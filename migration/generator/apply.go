@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
+)
+
+// Apply runs the Up SQL of a schema diff against db in one transaction,
+// without writing a migration file or recording a version. It lets an
+// application bring its schema in line with its models at startup:
+//
+//	changes, err := diff.NewSchemaComparer(db).Compare(&User{}, &Order{})
+//	...
+//	err = generator.Apply(db, changes)
+//
+// The SQL is generated for the dialect of db, and a database of another
+// dialect is refused. Changes that must run outside a transaction, such as
+// values added to an enum type, are applied statement by statement instead.
+func Apply(db *gorm.DB, schemaDiff *diff.SchemaDiff) error {
+	dialect := DialectOf(db)
+	if dialect == "" {
+		return fmt.Errorf("unsupported dialect %s", db.Name())
+	}
+	g := &Generator{SchemaDiff: schemaDiff, Dialect: dialect}
+
+	statements, err := g.UpStatements()
+	if err != nil {
+		return fmt.Errorf("failed to generate SQL: %w", err)
+	}
+
+	if g.NonTransactional() {
+		return execStatements(db, statements)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return execStatements(tx, statements)
+	})
+}
+
+// execStatements runs statements in order, stopping at the first failure
+func execStatements(db *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("statement %s failed: %w", strings.Join(strings.Fields(statement), " "), err)
+		}
+	}
+	return nil
+}
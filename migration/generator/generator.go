@@ -4,23 +4,79 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/beesaferoot/gorm-migrate/migration"
 	"github.com/beesaferoot/gorm-migrate/migration/diff"
 )
 
+// ErrNoChanges is returned by CreateMigration when the schema diff is empty.
+// It is migration.ErrNoChanges.
+var ErrNoChanges = migration.ErrNoChanges
+
+// Supported SQL dialects
+const (
+	DialectPostgres  = "postgres"
+	DialectMySQL     = "mysql"
+	DialectCockroach = "cockroach" // Postgres DDL with CockroachDB's integer types
+	DialectSQLite    = "sqlite"
+)
+
+// TableOptioner is implemented by models that need table-level options in
+// their CREATE TABLE statement, such as ENGINE=InnoDB on MySQL or TABLESPACE
+// on Postgres. The dialect is passed so a model can serve several databases.
+type TableOptioner interface {
+	TableOptions(dialect string) string
+}
+
+// TableCharsetter is implemented by models whose MySQL table needs a
+// character set or collation other than the generator's default. Either
+// value may be empty.
+type TableCharsetter interface {
+	TableCharset() (charset, collation string)
+}
+
+// TypeMapper maps model fields to SQL column types, for custom types the
+// built-in mapping does not know, such as a Money struct or decimal.Decimal.
+// Map returns ok false to leave a field to the next mapper.
+type TypeMapper interface {
+	Map(field *schema.Field) (sqlType string, ok bool)
+}
+
 // Generator helps create new migration files
 type Generator struct {
 	MigrationsDir string
 	SchemaDiff    *diff.SchemaDiff
+	Dialect       string
+	Charset       string // default MySQL table character set, e.g. utf8mb4
+	Collation     string // default MySQL table collation, e.g. utf8mb4_unicode_ci
+	IfNotExists   bool   // guard added and dropped columns so re-runs are safe
+	Cascade       bool   // drop tables along with the objects that depend on them
+	NotValidFKs   bool   // add foreign keys to existing tables NOT VALID and validate them afterwards
+	Unquoted      bool   // emit plain lowercase identifiers, see SetQuoteIdentifiers
+	Comment       string // written above the migration's registration
+	TypeMappers   []TypeMapper
+	TypeOverrides map[string]string // SQL type by field data type, e.g. uint: integer
+	Preamble      []string          // SQL run before the generated Up statements
+	Postamble     []string          // SQL run after the generated Up statements
+
+	// ON DELETE action of foreign keys that declare none, CASCADE when empty
+	DefaultOnDelete string
 }
 
 // NewGenerator creates a new migration generator
 func NewGenerator(migrationsDir string) *Generator {
 	return &Generator{
 		MigrationsDir: migrationsDir,
+		Dialect:       DialectPostgres,
 	}
 }
 
@@ -29,14 +85,295 @@ func (g *Generator) SetSchemaDiff(diff *diff.SchemaDiff) {
 	g.SchemaDiff = diff
 }
 
+// SetDialect sets the SQL dialect the generator targets
+func (g *Generator) SetDialect(dialect string) {
+	g.Dialect = dialect
+}
+
+// SetCharset sets the character set and collation of tables created on MySQL
+func (g *Generator) SetCharset(charset, collation string) {
+	g.Charset = charset
+	g.Collation = collation
+}
+
+// SetIfNotExists makes modify migrations use ADD COLUMN IF NOT EXISTS and
+// DROP COLUMN IF EXISTS, supported by Postgres 9.6 and later
+func (g *Generator) SetIfNotExists(enabled bool) {
+	g.IfNotExists = enabled
+}
+
+// addColumn returns the ALTER TABLE clause that adds a column
+func (g *Generator) addColumn() string {
+	if g.IfNotExists {
+		return "ADD COLUMN IF NOT EXISTS"
+	}
+	return "ADD COLUMN"
+}
+
+// dropColumn returns the ALTER TABLE clause that drops a column
+func (g *Generator) dropColumn() string {
+	if g.IfNotExists {
+		return "DROP COLUMN IF EXISTS"
+	}
+	return "DROP COLUMN"
+}
+
+// SetCascade makes dropped tables take their dependent objects, such as
+// foreign keys from other tables, with them
+func (g *Generator) SetCascade(enabled bool) {
+	g.Cascade = enabled
+}
+
+// SetNotValidFKs makes foreign keys added to existing tables skip the check
+// of existing rows, which is run by a separate VALIDATE CONSTRAINT later in
+// the migration. On Postgres this avoids holding a long exclusive lock.
+func (g *Generator) SetNotValidFKs(enabled bool) {
+	g.NotValidFKs = enabled
+}
+
+// SetDefaultOnDelete sets the ON DELETE action, such as RESTRICT or NO
+// ACTION, of foreign keys whose relationship declares none. An empty action
+// restores the default, CASCADE.
+func (g *Generator) SetDefaultOnDelete(action string) {
+	g.DefaultOnDelete = strings.ToUpper(strings.Join(strings.Fields(action), " "))
+}
+
+// defaultOnDelete returns the ON DELETE action of foreign keys declaring none
+func (g *Generator) defaultOnDelete() string {
+	if g.DefaultOnDelete == "" {
+		return "CASCADE"
+	}
+	return g.DefaultOnDelete
+}
+
+// notValidFKs reports whether foreign keys are added in two steps
+func (g *Generator) notValidFKs() bool {
+	return g.NotValidFKs && g.dialect() != DialectMySQL
+}
+
+// validateForeignKeysSQL returns the VALIDATE CONSTRAINT statements for the
+// foreign keys added NOT VALID to existing tables
+func (g *Generator) validateForeignKeysSQL() []string {
+	if !g.notValidFKs() {
+		return nil
+	}
+	var statements []string
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, fk := range table.ForeignKeysToAdd {
+			column := foreignKeyColumn(fk)
+			if column == "" || referencedTable(fk) == "" {
+				continue
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;",
+				g.quoteQualifiedIdentifier(table.Schema.Table),
+				g.quoteConstraintName(fmt.Sprintf("fk_%s_%s_fkey", table.Schema.Table, column))))
+		}
+	}
+	return statements
+}
+
+// dropTable returns the statement that drops a table
+func (g *Generator) dropTable(table string) string {
+	if g.Cascade {
+		return fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;", g.quoteQualifiedIdentifier(table))
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;", g.quoteQualifiedIdentifier(table))
+}
+
+// RegisterTypeMapper adds a mapper consulted, in registration order, before
+// the built-in type mapping
+func (g *Generator) RegisterTypeMapper(mapper TypeMapper) {
+	g.TypeMappers = append(g.TypeMappers, mapper)
+}
+
+// SetTypeOverrides replaces the built-in SQL type of field data types, such
+// as uint, without a custom TypeMapper
+func (g *Generator) SetTypeOverrides(overrides map[string]string) {
+	g.TypeOverrides = overrides
+}
+
+// mappedType returns the SQL type a registered mapper gives a field
+func (g *Generator) mappedType(col *schema.Field) (string, bool) {
+	for _, mapper := range g.TypeMappers {
+		if sqlType, ok := mapper.Map(col); ok {
+			return sqlType, true
+		}
+	}
+	return "", false
+}
+
+// AddPreamble adds SQL, such as CREATE EXTENSION or SET search_path, that Up
+// runs before the generated statements
+func (g *Generator) AddPreamble(sql string) {
+	g.Preamble = append(g.Preamble, sql)
+}
+
+// AddPostamble adds SQL that Up runs after the generated statements
+func (g *Generator) AddPostamble(sql string) {
+	g.Postamble = append(g.Postamble, sql)
+}
+
+// customStatements terminates each hand-written statement, so it is split
+// from the generated ones
+func customStatements(sqls []string) []string {
+	var statements []string
+	for _, sql := range sqls {
+		sql = strings.TrimSpace(sql)
+		if sql == "" {
+			continue
+		}
+		if !strings.HasSuffix(sql, ";") {
+			sql += ";"
+		}
+		statements = append(statements, sql)
+	}
+	return statements
+}
+
+// SetComment sets a comment for the migration file, such as where it came from
+func (g *Generator) SetComment(comment string) {
+	g.Comment = comment
+}
+
 // CreateMigration generates a new migration file
 func (g *Generator) CreateMigration(name string) error {
+	return g.WriteMigration(NewVersion(), name)
+}
+
+// NewVersion returns the version for a migration created now
+func NewVersion() string {
+	return time.Now().Format("20060102150405")
+}
+
+// GenerateSQL returns the Up and Down SQL for the current schema diff
+func (g *Generator) GenerateSQL() (string, string, error) {
+	if g.SchemaDiff == nil {
+		return "", "", fmt.Errorf("schema diff not set")
+	}
+	upSQL, err := g.generateUpSQL()
+	if err != nil {
+		return "", "", err
+	}
+	return upSQL, g.generateDownSQL(), nil
+}
+
+// UpStatements returns the Up SQL for the current schema diff, one statement
+// per entry
+func (g *Generator) UpStatements() ([]string, error) {
+	if g.SchemaDiff == nil {
+		return nil, fmt.Errorf("schema diff not set")
+	}
+	return g.upStatements()
+}
+
+// MatchesMigration reports whether the migration file for the given version
+// and name already runs the statements the current schema diff would generate.
+// Statement order and preserved blocks are ignored.
+func (g *Generator) MatchesMigration(version, name string) (bool, error) {
+	content, err := g.renderMigration(version, name)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := os.ReadFile(g.migrationPath(version, name))
+	if err != nil {
+		return false, fmt.Errorf("failed to read migration file: %w", err)
+	}
+
+	return sameStatements(content, string(existing)), nil
+}
+
+// migrationPath returns the path of the migration file for a version and name
+func (g *Generator) migrationPath(version, name string) string {
+	return filepath.Join(g.MigrationsDir, fmt.Sprintf("%s_%s.go", version, name))
+}
+
+// WriteMigration writes the migration file for the given version and name
+// from the current schema diff, rewriting it in place if it already exists
+func (g *Generator) WriteMigration(version, name string) error {
+	content, err := g.renderMigration(version, name)
+	if err != nil {
+		return err
+	}
+
+	// Create migrations directory if it doesn't exist
+	if err := os.MkdirAll(g.MigrationsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	path := g.migrationPath(version, name)
+
+	// Carry over hand-written marker blocks when rewriting an existing file
+	if existing, err := os.ReadFile(path); err == nil {
+		content = insertPreservedBlocks(content, extractPreservedBlocks(string(existing)))
+	}
+
+	// Write the file
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+
+	return nil
+}
+
+// dataMigrationTemplate scaffolds a migration for hand-written data changes
+const dataMigrationTemplate = `package migrations
+
+import (
+	"github.com/beesaferoot/gorm-migrate/migration"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Data migration: no schema changes are generated for it.
+func init() {
+	migration.RegisterMigration(&migration.Migration{
+		Version:   "%s",
+		Name:      "%s",
+		CreatedAt: time.Now(),
+		Up: func(db *gorm.DB) error {
+			// DATA MIGRATION (Up): change existing rows here, for example
+			// with db.Model(...).Where(...).Update(...).
+			return nil
+		},
+		Down: func(db *gorm.DB) error {
+			// DATA MIGRATION (Down): undo the changes made by Up here.
+			return nil
+		},
+	})
+}
+`
+
+// WriteDataMigration writes a migration with empty Up and Down bodies marked
+// for hand-written data changes. An existing file is never overwritten.
+func (g *Generator) WriteDataMigration(version, name string) error {
+	if err := os.MkdirAll(g.MigrationsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	path := g.migrationPath(version, name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("migration file %s already exists", path)
+	}
+
+	content := fmt.Sprintf(dataMigrationTemplate, version, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+	return nil
+}
+
+// renderMigration generates the migration file content for the given version
+// and name from the schema diff
+func (g *Generator) renderMigration(version, name string) (string, error) {
 	if g.SchemaDiff == nil {
-		return fmt.Errorf("schema diff not set")
+		return "", fmt.Errorf("schema diff not set")
 	}
 
 	// Guard: do not create a migration if there are no changes
-	hasChanges := len(g.SchemaDiff.TablesToCreate) > 0 || len(g.SchemaDiff.TablesToDrop) > 0 || len(g.SchemaDiff.TablesToRename) > 0
+	hasChanges := len(g.SchemaDiff.TablesToCreate) > 0 || len(g.SchemaDiff.TablesToDrop) > 0 || len(g.SchemaDiff.TablesToRename) > 0 ||
+		len(g.SchemaDiff.ViewsToCreate) > 0 || len(g.SchemaDiff.EnumsToCreate) > 0 || g.SchemaDiff.AddsEnumValues() ||
+		len(g.SchemaDiff.RowsToInsert) > 0 || len(g.SchemaDiff.RowsToUpdate) > 0
 	for _, tableMod := range g.SchemaDiff.TablesToModify {
 		if !tableMod.IsEmpty() {
 			hasChanges = true
@@ -44,30 +381,30 @@ func (g *Generator) CreateMigration(name string) error {
 		}
 	}
 	if !hasChanges {
-		return fmt.Errorf("no schema changes detected")
+		return "", ErrNoChanges
 	}
 
 	// Validate schema diff
 	if err := g.validateSchemaDiff(g.SchemaDiff); err != nil {
-		return fmt.Errorf("invalid schema diff: %w", err)
+		return "", fmt.Errorf("invalid schema diff: %w", err)
 	}
 
-	// Create migrations directory if it doesn't exist
-	if err := os.MkdirAll(g.MigrationsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create migrations directory: %w", err)
+	// Generate Up and Down SQL statements
+	upStatements, err := g.upStatements()
+	if err != nil {
+		return "", err
 	}
+	downStatements := g.downStatements()
 
-	// Generate version using timestamp
-	version := time.Now().Format("20060102150405")
-	filename := fmt.Sprintf("%s_%s.go", version, name)
-	filepath := filepath.Join(g.MigrationsDir, filename)
+	comment := ""
+	if g.Comment != "" {
+		comment = "// " + strings.ReplaceAll(g.Comment, "\n", "\n// ") + "\n"
+	}
 
-	// Generate Up and Down SQL statements
-	upSQL, err := g.generateUpSQL()
-	if err != nil {
-		return err
+	options := ""
+	if g.nonTransactional() {
+		options = "\n\t\tNonTransactional: true,"
 	}
-	downSQL := g.generateDownSQL()
 
 	// Create migration file content
 	content := fmt.Sprintf(`package migrations
@@ -78,11 +415,11 @@ import (
 	"time"
 )
 
-func init() {
+%sfunc init() {
 	migration.RegisterMigration(&migration.Migration{
 		Version:   "%s",
 		Name:      "%s",
-		CreatedAt: time.Now(),
+		CreatedAt: time.Now(),%s
 		Up: func(db *gorm.DB) error {
 			%s
 			return nil
@@ -93,22 +430,44 @@ func init() {
 		},
 	})
 }
-`, version, name, formatSQLAsExec(upSQL), formatSQLAsExec(downSQL))
+`, comment, version, name, options, formatSQLAsExec(upStatements), formatSQLAsExec(downStatements))
 
-	// Write the file
-	if err := os.WriteFile(filepath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to create migration file: %w", err)
-	}
+	return content, nil
+}
 
-	return nil
+// NonTransactional reports whether the Up SQL of the current schema diff must
+// run outside a transaction, see nonTransactional
+func (g *Generator) NonTransactional() bool {
+	return g.SchemaDiff != nil && g.nonTransactional()
+}
+
+// nonTransactional reports whether the migration has statements Postgres
+// refuses to run inside a transaction block. On CockroachDB, indexes added to
+// existing tables are backfilled online, which cannot be combined with other
+// schema changes in one transaction.
+// Foreign keys added NOT VALID are committed before they are validated.
+func (g *Generator) nonTransactional() bool {
+	if g.SchemaDiff.AddsEnumValues() {
+		return true
+	}
+	if len(g.validateForeignKeysSQL()) > 0 {
+		return true
+	}
+	if g.dialect() == DialectCockroach {
+		for _, table := range g.SchemaDiff.TablesToModify {
+			if len(table.IndexesToAdd) > 0 || len(table.IndexesToModify) > 0 {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // formatSQLAsExec wraps each full SQL statement in db.Exec with error handling and proper formatting
-func formatSQLAsExec(sql string) string {
-	if sql == "" {
+func formatSQLAsExec(statements []string) string {
+	if len(statements) == 0 {
 		return "// No schema changes"
 	}
-	statements := splitSQLStatements(sql)
 	var stmts []string
 	for _, stmt := range statements {
 		trimmed := strings.TrimSpace(stmt)
@@ -116,12 +475,41 @@ func formatSQLAsExec(sql string) string {
 			continue
 		}
 		// Format the SQL statement with proper indentation
-		formattedSQL := formatSQLStatement(trimmed)
-		stmts = append(stmts, fmt.Sprintf("if err := db.Exec(`%s`).Error; err != nil {\n\t\t\treturn err\n\t\t}", formattedSQL))
+		formattedSQL := trimmed
+		if !keepsLayout(trimmed) {
+			formattedSQL = formatSQLStatement(trimmed)
+		}
+		literal := "`" + formattedSQL + "`"
+		if strings.Contains(formattedSQL, "`") {
+			// A raw string cannot hold MySQL's backtick-quoted identifiers
+			literal = strconv.Quote(formattedSQL)
+		}
+		stmts = append(stmts, fmt.Sprintf("if err := db.Exec(%s).Error; err != nil {\n\t\t\treturn err\n\t\t}", literal))
 	}
 	return strings.Join(stmts, "\n\t\t")
 }
 
+// keepsLayout reports whether a statement is written out as generated rather
+// than formatted: DML, and any statement with a string literal, whose text
+// the formatter would break at commas and keywords and re-indent
+func keepsLayout(stmt string) bool {
+	if strings.ContainsRune(stmt, '\'') {
+		return true
+	}
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		switch strings.ToUpper(strings.Fields(line)[0]) {
+		case "INSERT", "UPDATE", "DELETE":
+			return true
+		}
+		return false
+	}
+	return false
+}
+
 // formatSQLStatement formats a SQL statement with proper indentation and line breaks
 func formatSQLStatement(sql string) string {
 	// First, let's properly format the SQL by adding line breaks at key points
@@ -264,27 +652,111 @@ func isColumnDefinition(line string) bool {
 	return false
 }
 
-// splitSQLStatements splits a string into SQL statements by semicolon, preserving multi-line statements
-func splitSQLStatements(sql string) []string {
-	var stmts []string
-	var current strings.Builder
-	for _, line := range strings.Split(sql, "\n") {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
+// statementList applies the identifier quoting setting to each statement and
+// attaches comment lines to the statement that follows them, so that a
+// comment is executed and written out together with its statement
+func (g *Generator) statementList(statements []string) []string {
+	var list []string
+	var comments []string
+	for _, stmt := range statements {
+		if strings.TrimSpace(stmt) == "" {
 			continue
 		}
-		current.WriteString(line)
-		if strings.HasSuffix(trimmed, ";") {
-			stmts = append(stmts, current.String())
-			current.Reset()
-		} else {
-			current.WriteString("\n")
+		stmt = g.identifiers(stmt)
+		if strings.HasPrefix(strings.TrimSpace(stmt), "--") && !strings.Contains(stmt, "\n") {
+			comments = append(comments, stmt)
+			continue
+		}
+		list = append(list, strings.Join(append(comments, stmt), "\n"))
+		comments = nil
+	}
+	if len(comments) > 0 {
+		list = append(list, strings.Join(comments, "\n"))
+	}
+	return list
+}
+
+// maxVarcharSize is the largest string size stored as varchar; longer strings become text
+const maxVarcharSize = 4096
+
+// columnSQLType maps a field to its SQL type, consulting the registered type
+// mappers first and honoring an explicit string size
+func (g *Generator) columnSQLType(col *schema.Field) string {
+	if sqlType, ok := g.mappedType(col); ok {
+		return sqlType
+	}
+	if override, ok := g.TypeOverrides[string(col.DataType)]; ok {
+		if autoIncrementKey(col) {
+			return g.autoIncrementType(col, override)
+		}
+		return override
+	}
+	if g.dialect() == DialectCockroach && (col.DataType == schema.Int || col.DataType == schema.Uint) {
+		return cockroachIntegerType(col)
+	}
+	if col.DataType == schema.String && col.Size > 0 {
+		if col.Size > maxVarcharSize {
+			return "text"
 		}
+		return fmt.Sprintf("varchar(%d)", col.Size)
+	}
+	if precision, _ := diff.DecimalSize(col); precision > 0 && diff.NormalizedType(col) == "decimal" {
+		return decimalSQLType(col)
+	}
+	if autoIncrementKey(col) {
+		return g.autoIncrementType(col, g.sqlType(string(col.DataType)))
+	}
+	return g.sqlType(string(col.DataType))
+}
+
+// autoIncrementKey reports whether col is a primary key the database numbers:
+// an integer key or an identity column
+func autoIncrementKey(col *schema.Field) bool {
+	return col.PrimaryKey && (diff.IsIdentity(col) || col.DataType == schema.Int || col.DataType == schema.Uint)
+}
+
+// autoIncrementType returns the type of an auto-increment key whose plain
+// type is sqlType. On SQLite, AUTOINCREMENT follows PRIMARY KEY instead.
+func (g *Generator) autoIncrementType(col *schema.Field, sqlType string) string {
+	switch {
+	case g.dialect() == DialectMySQL:
+		return sqlType + " AUTO_INCREMENT"
+	case g.dialect() == DialectSQLite:
+		return sqlType
+	case diff.IsIdentity(col):
+		return sqlType + " GENERATED BY DEFAULT AS IDENTITY"
 	}
-	if current.Len() > 0 {
-		stmts = append(stmts, current.String())
+	return serialType(sqlType)
+}
+
+// sqlType maps a Go type to the SQL type of the target dialect
+func (g *Generator) sqlType(goType string) string {
+	switch g.dialect() {
+	case DialectMySQL:
+		return mapGoTypeToMySQLType(goType)
+	case DialectSQLite:
+		return mapGoTypeToSQLiteType(goType)
+	}
+	return mapGoTypeToSQLType(goType)
+}
+
+// cockroachIntegerType returns the CockroachDB type of an integer column.
+// INT is 64-bit there, and SERIAL8 keys default to unique_rowid() rather than
+// a sequence.
+func cockroachIntegerType(col *schema.Field) string {
+	switch {
+	case col.PrimaryKey && diff.IsIdentity(col):
+		return "INT8 GENERATED BY DEFAULT AS IDENTITY"
+	case col.PrimaryKey:
+		return "SERIAL8"
 	}
-	return stmts
+	return "INT8"
+}
+
+// decimalSQLType returns the numeric type with the field's precision and scale
+func decimalSQLType(col *schema.Field) string {
+	precision, scale := diff.DecimalSize(col)
+	return fmt.Sprintf("numeric(%d,%d)", precision, scale)
 }
 
 // mapGoTypeToSQLType maps Go types to SQL types
@@ -304,26 +776,62 @@ func mapGoTypeToSQLType(goType string) string {
 		return "boolean"
 	case "json":
 		return "jsonb"
+	case "bytes":
+		return "bytea"
 	default:
 		return goType
 	}
 }
 
-// mapGoTypeToSQLTypeWithAutoIncrement maps Go types to SQL types, handling auto-increment for primary keys
-func mapGoTypeToSQLTypeWithAutoIncrement(goType string, isPrimaryKey bool) string {
-	baseType := mapGoTypeToSQLType(goType)
+// mapGoTypeToMySQLType maps Go types to MySQL types
+func mapGoTypeToMySQLType(goType string) string {
+	switch goType {
+	case "time":
+		return "datetime"
+	case "int":
+		return "int"
+	case "uint":
+		return "bigint unsigned"
+	case "float":
+		return "double"
+	case "json":
+		return "json"
+	case "bytes":
+		return "BLOB"
+	}
+	return mapGoTypeToSQLType(goType)
+}
 
-	// For PostgreSQL, use SERIAL types for auto-increment primary keys
-	if isPrimaryKey {
-		switch goType {
-		case "uint":
-			return "BIGSERIAL"
-		case "int":
-			return "SERIAL"
-		}
+// mapGoTypeToSQLiteType maps Go types to the SQLite types GORM uses
+func mapGoTypeToSQLiteType(goType string) string {
+	switch goType {
+	case "time":
+		return "datetime"
+	case "string", "json":
+		return "text"
+	case "int", "uint":
+		return "integer"
+	case "float":
+		return "real"
+	case "bool":
+		return "numeric"
+	case "bytes":
+		return "blob"
 	}
+	return goType
+}
 
-	return baseType
+// serialType returns the auto-increment form of an integer SQL type
+func serialType(sqlType string) string {
+	switch strings.ToLower(sqlType) {
+	case "smallint":
+		return "SMALLSERIAL"
+	case "integer", "int":
+		return "SERIAL"
+	case "bigint":
+		return "BIGSERIAL"
+	}
+	return sqlType
 }
 
 // getDefaultValue returns the appropriate default value for a column
@@ -355,6 +863,17 @@ func topoSortTables(tables []diff.TableDiff) ([]diff.TableDiff, error) {
 	for _, t := range tables {
 		tableMap[t.Schema.Table] = t
 	}
+	// A has-many or has-one association puts the foreign key on the child
+	// table, so the parent declaring it goes first
+	parents := make(map[string][]string)
+	for _, t := range tables {
+		associations := append(append([]*schema.Relationship{}, t.Schema.Relationships.HasMany...), t.Schema.Relationships.HasOne...)
+		for _, rel := range associations {
+			if rel.FieldSchema != nil && rel.FieldSchema.Table != t.Schema.Table {
+				parents[rel.FieldSchema.Table] = append(parents[rel.FieldSchema.Table], t.Schema.Table)
+			}
+		}
+	}
 	visited := make(map[string]bool)
 	visiting := make(map[string]bool)
 	var sorted []diff.TableDiff
@@ -372,17 +891,21 @@ func topoSortTables(tables []diff.TableDiff) ([]diff.TableDiff, error) {
 			return fmt.Errorf("table %s not found", name)
 		}
 		for _, fk := range t.ForeignKeysToAdd {
-			if len(fk.References) < 1 {
+			referencedTable := referencedTable(fk)
+			if referencedTable != "" && referencedTable != t.Schema.Table {
+				if err := visit(referencedTable); err != nil {
+					return err
+				}
+			}
+		}
+		// Association parents only order tables that are created together,
+		// and give way to foreign keys that point the other way
+		for _, parent := range parents[name] {
+			if _, ok := tableMap[parent]; !ok || visiting[parent] {
 				continue
 			}
-			r := fk.References[0]
-			if r != nil && r.PrimaryKey != nil && r.PrimaryKey.Schema != nil {
-				referencedTable := r.PrimaryKey.Schema.Table
-				if referencedTable != "" && referencedTable != t.Schema.Table {
-					if err := visit(referencedTable); err != nil {
-						return err
-					}
-				}
+			if err := visit(parent); err != nil {
+				return err
 			}
 		}
 		visiting[name] = false
@@ -400,47 +923,147 @@ func topoSortTables(tables []diff.TableDiff) ([]diff.TableDiff, error) {
 
 // generateUpSQL generates the SQL statements for the Up migration
 func (g *Generator) generateUpSQL() (string, error) {
-	if g.SchemaDiff == nil {
-		return "", nil
-	}
-
-	var statements []string
-
-	// Topologically sort tables to create
-	tablesToCreate, err := topoSortTables(g.SchemaDiff.TablesToCreate)
+	statements, err := g.upStatements()
 	if err != nil {
 		return "", err
 	}
+	return strings.Join(statements, "\n"), nil
+}
 
-	// Create tables
-	for _, table := range tablesToCreate {
-		statements = append(statements, g.generateCreateTableSQL(table))
+// upStatements generates the statements of the Up migration, one per entry
+func (g *Generator) upStatements() ([]string, error) {
+	if g.SchemaDiff == nil {
+		return nil, nil
 	}
 
-	// Modify tables
-	for _, table := range g.SchemaDiff.TablesToModify {
-		statements = append(statements, g.generateModifyTableSQL(table)...)
-	}
+	statements := customStatements(g.Preamble)
 
-	return strings.Join(statements, "\n"), nil
+	// Enum types come before the columns that use them
+	for _, enum := range g.SchemaDiff.EnumsToCreate {
+		values := make([]string, len(enum.Values))
+		for i, value := range enum.Values {
+			values[i] = stringLiteral(value)
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", g.quoteIdentifier(enum.Name), strings.Join(values, ", ")))
+	}
+	for _, enum := range g.SchemaDiff.EnumsToAlter {
+		for _, value := range enum.Added {
+			statements = append(statements, fmt.Sprintf("ALTER TYPE %s ADD VALUE IF NOT EXISTS %s;", g.quoteIdentifier(enum.Name), stringLiteral(value)))
+		}
+		for _, value := range enum.Removed {
+			statements = append(statements, fmt.Sprintf("-- TODO: Postgres cannot remove value %s from enum type %s; recreate the type manually", stringLiteral(value), enum.Name))
+		}
+	}
+
+	statements = append(statements, g.columnSequencesSQL()...)
+
+	// Rename tables first so modifications can use the new names
+	for _, rename := range g.SchemaDiff.TablesToRename {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", g.quoteIdentifier(rename.OldName), g.quoteIdentifier(rename.NewName)))
+	}
+
+	// Topologically sort tables to create
+	tablesToCreate, err := topoSortTables(g.SchemaDiff.TablesToCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create tables, each under a comment naming the model it comes from
+	for _, table := range tablesToCreate {
+		if table.Schema.ModelType != nil {
+			statements = append(statements, "-- "+table.Schema.ModelType.String())
+		}
+		statements = append(statements, g.createTableStatements(table)...)
+	}
+
+	// Comment created tables
+	for _, table := range tablesToCreate {
+		if table.Comment != "" {
+			statements = append(statements, g.tableCommentSQL(table.Schema.Table, table.Comment))
+		}
+	}
+
+	// Set custom start values and increments of created auto-increment columns
+	for _, table := range tablesToCreate {
+		statements = append(statements, g.sequenceOptionsSQL(table)...)
+	}
+
+	// Modify tables
+	for _, table := range g.SchemaDiff.TablesToModify {
+		statements = append(statements, g.generateModifyTableSQL(table)...)
+	}
+
+	// Check existing rows against foreign keys added NOT VALID
+	statements = append(statements, g.validateForeignKeysSQL()...)
+
+	// Create materialized views once the tables they read exist
+	for _, view := range g.SchemaDiff.ViewsToCreate {
+		statements = append(statements, fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s;",
+			g.quoteIdentifier(view.Name), strings.TrimSuffix(strings.TrimSpace(view.SQL), ";")))
+	}
+
+	// Insert missing reference rows once their tables are in shape
+	for _, data := range g.SchemaDiff.RowsToInsert {
+		for _, row := range data.Rows {
+			statements = append(statements, g.insertRowSQL(data.Table, row))
+		}
+	}
+	for _, update := range g.SchemaDiff.RowsToUpdate {
+		statements = append(statements, g.updateRowSQL(update.Table, update.Key, update.Values))
+	}
+
+	// Drop unmanaged tables
+	for _, table := range g.SchemaDiff.TablesToDrop {
+		statements = append(statements, g.dropTable(table))
+	}
+
+	statements = append(statements, customStatements(g.Postamble)...)
+
+	return g.statementList(statements), nil
 }
 
 // generateDownSQL generates the SQL statements for the Down migration
 func (g *Generator) generateDownSQL() string {
+	return strings.Join(g.downStatements(), "\n")
+}
+
+// downStatements generates the statements of the Down migration, one per entry
+func (g *Generator) downStatements() []string {
 	if g.SchemaDiff == nil {
-		return ""
+		return nil
 	}
 
 	var statements []string
 
+	// Restore updated reference rows, then delete inserted ones
+	for i := len(g.SchemaDiff.RowsToUpdate) - 1; i >= 0; i-- {
+		update := g.SchemaDiff.RowsToUpdate[i]
+		statements = append(statements, g.updateRowSQL(update.Table, update.Key, update.Previous))
+	}
+	for i := len(g.SchemaDiff.RowsToInsert) - 1; i >= 0; i-- {
+		data := g.SchemaDiff.RowsToInsert[i]
+		for _, row := range data.Rows {
+			statements = append(statements, g.deleteRowSQL(data.Table, row))
+		}
+	}
+
+	// Drop materialized views before the tables they read
+	for i := len(g.SchemaDiff.ViewsToCreate) - 1; i >= 0; i-- {
+		statements = append(statements, fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s;", g.quoteIdentifier(g.SchemaDiff.ViewsToCreate[i].Name)))
+	}
+
+	// Dropped tables cannot be recreated from the diff
+	for _, table := range g.SchemaDiff.TablesToDrop {
+		statements = append(statements, fmt.Sprintf("-- TODO: Recreate dropped table %s manually", table))
+	}
+
 	// Drop indexes first
 	for _, table := range g.SchemaDiff.TablesToModify {
 		for _, idx := range table.IndexesToAdd {
-			idxName := idx.Name
-			if strings.HasPrefix(idxName, "idx_idx_") {
-				idxName = strings.Replace(idxName, "idx_idx_", "idx_", 1)
-			}
-			statements = append(statements, fmt.Sprintf("DROP INDEX IF EXISTS %s;", idxName))
+			statements = append(statements, g.dropIndexSQL(table.Schema.Table, indexName(idx)))
+		}
+		for _, idx := range table.IndexesToModify {
+			statements = append(statements, g.dropIndexSQL(table.Schema.Table, indexName(idx)))
 		}
 	}
 
@@ -448,76 +1071,222 @@ func (g *Generator) generateDownSQL() string {
 	for _, table := range g.SchemaDiff.TablesToModify {
 		for _, fk := range table.ForeignKeysToAdd {
 			if fk.Field != nil {
-				statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS fk_%s_%s_fkey;",
-					quoteIdentifier(table.Schema.Table),
-					table.Schema.Table,
-					fk.Field.DBName))
+				statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "FOREIGN KEY",
+					g.quoteConstraintName(fmt.Sprintf("fk_%s_%s_fkey", table.Schema.Table, foreignKeyColumn(fk)))))
+			}
+		}
+	}
+
+	// Restore dropped foreign keys
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, fk := range table.ForeignKeysToDrop {
+			column := foreignKeyColumn(fk)
+			refTable := referencedTable(fk)
+			if column == "" || refTable == "" {
+				continue
 			}
+			local, referenced := g.foreignKeyColumns(fk)
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s)%s;",
+				g.quoteIdentifier(table.Schema.Table),
+				g.quoteConstraintName(foreignKeyName(table.Schema.Table, fk)),
+				strings.Join(local, ", "),
+				g.quoteQualifiedIdentifier(refTable),
+				strings.Join(referenced, ", "),
+				foreignKeyActionsSQL(fk, "")))
+		}
+	}
+
+	// Restore renamed indexes
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, rename := range table.IndexesToRename {
+			statements = append(statements, g.renameIndexSQL(table.Schema.Table, rename.NewName, rename.OldName))
+		}
+	}
+
+	// Reverse unique constraint changes
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, uq := range table.UniquesToAdd {
+			statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "UNIQUE", g.quoteIdentifier(uq.Name)))
+		}
+	}
+
+	// Reverse check constraint changes
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, chk := range table.ChecksToAdd {
+			statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "CHECK", g.quoteIdentifier(chk.Name)))
+		}
+		for _, chk := range table.ChecksToDrop {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s);", g.quoteIdentifier(table.Schema.Table), g.quoteIdentifier(chk.Name), chk.Constraint))
+		}
+	}
+
+	// Restore previous table comments
+	for _, table := range g.SchemaDiff.TablesToModify {
+		if table.Comment != table.PreviousComment {
+			statements = append(statements, g.tableCommentSQL(table.Schema.Table, table.PreviousComment))
 		}
 	}
 
 	// Reverse column changes for modified tables
 	for _, table := range g.SchemaDiff.TablesToModify {
-		tableName := quoteIdentifier(table.Schema.Table)
+		tableName := g.quoteIdentifier(table.Schema.Table)
 		// Reverse added columns: drop them
 		for _, col := range table.FieldsToAdd {
-			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", tableName, quoteIdentifier(col.DBName)))
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s %s %s;", tableName, g.dropColumn(), g.quoteIdentifier(col.DBName)))
 		}
 		// Reverse dropped columns: add them back (best guess type)
 		for _, col := range table.FieldsToDrop {
-			// Try to guess the SQL type, fallback to comment if unknown
-			sqlType := mapGoTypeToSQLType(string(col.DataType))
+			// Try to guess the SQL type, with its length, fallback to comment if unknown
+			sqlType := g.modifiedColumnType(col)
 			if sqlType == "" {
 				statements = append(statements, fmt.Sprintf("-- TODO: Could not determine type for column %s, please edit manually", col.DBName))
 				continue
 			}
-			colDef := fmt.Sprintf("%s %s", quoteIdentifier(col.DBName), sqlType)
+			colDef := fmt.Sprintf("%s %s", g.quoteIdentifier(col.DBName), sqlType)
 			if col.NotNull {
 				colDef += " NOT NULL"
 			}
 			if col.DefaultValue != "" {
 				colDef += fmt.Sprintf(" DEFAULT %v", col.DefaultValue)
 			}
-			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", tableName, colDef))
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s %s %s;", tableName, g.addColumn(), colDef))
+		}
+		// Reverse modified columns back to their previous definition
+		oldKey, newKey := g.primaryKeyColumns(table, true), g.primaryKeyColumns(table, false)
+		keyChanged := strings.Join(oldKey, ",") != strings.Join(newKey, ",")
+		if keyChanged && len(newKey) > 0 {
+			statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "PRIMARY KEY", table.Schema.Table+"_pkey"))
 		}
-		// Reverse modified columns: add a comment (manual intervention needed)
 		for _, col := range table.FieldsToModify {
-			statements = append(statements, fmt.Sprintf("-- TODO: Reverse modification for column %s in table %s manually", col.DBName, table.Schema.Table))
+			prev := table.PreviousFields[col.DBName]
+			if g.dialect() == DialectPostgres && diff.PrimaryKeyStrategyChanged(prev, col) {
+				statements = append(statements, g.keyStrategySQL(table.Schema.Table, col, prev)...)
+				continue
+			}
+			if diff.PrimaryKeyChanged(prev, col) {
+				prev, col = withoutPrimaryKey(prev), withoutPrimaryKey(col)
+				if diff.FieldsEqual(prev, col) {
+					continue
+				}
+			}
+			if diff.AttributesOnlyChange(prev, col) {
+				statements = append(statements, g.alterColumnAttributes(table.Schema.Table, col, prev)...)
+				continue
+			}
+			if diff.DecimalSizeOnlyChange(prev, col) {
+				statements = append(statements, g.alterDecimalSize(table.Schema.Table, prev))
+				continue
+			}
+			statements = append(statements, g.alterColumnType(table.Schema.Table, col, prev)...)
+		}
+		if keyChanged && len(oldKey) > 0 {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s);", tableName, strings.Join(oldKey, ", ")))
+		}
+		// Restore renamed columns once their modifications are reversed
+		for i := len(table.FieldsToRename) - 1; i >= 0; i-- {
+			rename := table.FieldsToRename[i]
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", tableName, g.quoteIdentifier(rename.NewName), g.quoteIdentifier(rename.OldName)))
+		}
+	}
+
+	// Restore dropped unique constraints once their columns are back
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, uq := range table.UniquesToDrop {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", g.quoteIdentifier(table.Schema.Table), g.uniqueConstraintSQL(uq)))
 		}
 	}
 
+	// Restore dropped indexes and the previous definition of modified ones
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, idx := range table.IndexesToDrop {
+			statements = append(statements, g.createIndexSQL(table.Schema.Table, idx))
+		}
+		for _, idx := range table.IndexesToModify {
+			if prev := table.PreviousIndexes[idx.Name]; prev != nil {
+				statements = append(statements, g.createIndexSQL(table.Schema.Table, prev))
+			}
+		}
+	}
+
+	// Restore renamed tables once their modifications are reversed
+	for i := len(g.SchemaDiff.TablesToRename) - 1; i >= 0; i-- {
+		rename := g.SchemaDiff.TablesToRename[i]
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", g.quoteIdentifier(rename.NewName), g.quoteIdentifier(rename.OldName)))
+	}
+
 	// Drop tables created in Up
 	tablesToDrop, err := topoSortTables(g.SchemaDiff.TablesToCreate)
 	if err != nil {
 		for i := len(g.SchemaDiff.TablesToCreate) - 1; i >= 0; i-- {
 			table := g.SchemaDiff.TablesToCreate[i]
-			statements = append(statements, fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoteIdentifier(table.Schema.Table)))
+			statements = append(statements, g.dropTable(table.Schema.Table))
 		}
 	} else {
 		for i := len(tablesToDrop) - 1; i >= 0; i-- {
 			table := tablesToDrop[i]
-			statements = append(statements, fmt.Sprintf("DROP TABLE IF EXISTS %s;", quoteIdentifier(table.Schema.Table)))
+			statements = append(statements, g.dropTable(table.Schema.Table))
+		}
+	}
+
+	// Drop the sequences created in Up once no column draws from them
+	for _, sequence := range g.createdSequences() {
+		statements = append(statements, fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", g.quoteQualifiedIdentifier(sequence)))
+	}
+
+	// Drop enum types once no table uses them; added values cannot be removed
+	for _, enum := range g.SchemaDiff.EnumsToAlter {
+		for _, value := range enum.Added {
+			statements = append(statements, fmt.Sprintf("-- TODO: Postgres cannot remove value %s from enum type %s; recreate the type manually", stringLiteral(value), enum.Name))
 		}
 	}
+	for i := len(g.SchemaDiff.EnumsToCreate) - 1; i >= 0; i-- {
+		statements = append(statements, fmt.Sprintf("DROP TYPE IF EXISTS %s;", g.quoteIdentifier(g.SchemaDiff.EnumsToCreate[i].Name)))
+	}
 
-	return strings.Join(statements, "\n")
+	return g.statementList(statements)
 }
 
 // generateCreateTableSQL generates the SQL for creating a table with proper formatting
 func (g *Generator) generateCreateTableSQL(table diff.TableDiff) string {
+	return strings.Join(g.createTableStatements(table), "\n")
+}
+
+// createTableStatements returns the CREATE TABLE statement of a table
+// followed by the statements creating its indexes
+func (g *Generator) createTableStatements(table diff.TableDiff) []string {
 	var columns []string
 	var tableConstraints []string
 	var indexSQLs []string
 
+	// A composite primary key is a table constraint, and its columns are
+	// only serial when declared auto-increment
+	var keyColumns []string
+	for _, col := range table.FieldsToAdd {
+		if col.PrimaryKey {
+			keyColumns = append(keyColumns, g.quoteIdentifier(col.DBName))
+		}
+	}
+	compositeKey := len(keyColumns) > 1
+	if compositeKey {
+		tableConstraints = append(tableConstraints, fmt.Sprintf("    PRIMARY KEY (%s)", strings.Join(keyColumns, ", ")))
+	}
+
 	// Add columns with proper formatting
 	for _, col := range table.FieldsToAdd {
-		sqlType := mapGoTypeToSQLTypeWithAutoIncrement(string(col.DataType), col.PrimaryKey)
-		columnDef := fmt.Sprintf("%s %s", col.DBName, sqlType)
+		sqlType := g.columnSQLType(col)
+		if compositeKey && col.PrimaryKey && !col.AutoIncrement {
+			sqlType = g.columnSQLType(withoutPrimaryKey(col))
+		}
+		columnDef := fmt.Sprintf("%s %s", g.columnName(col.DBName), sqlType)
 		if col.NotNull {
 			columnDef += " NOT NULL"
 		}
-		if col.PrimaryKey {
+		if col.PrimaryKey && !compositeKey {
 			columnDef += " PRIMARY KEY"
+			// Only an INTEGER PRIMARY KEY can be AUTOINCREMENT on SQLite
+			if g.dialect() == DialectSQLite && sqlType == "integer" && autoIncrementKey(col) {
+				columnDef += " AUTOINCREMENT"
+			}
 		}
 		// Add default value if not primary key and not already set
 		if !col.PrimaryKey && col.DefaultValue != "" {
@@ -528,40 +1297,55 @@ func (g *Generator) generateCreateTableSQL(table diff.TableDiff) string {
 
 	// Add foreign keys as table constraints
 	for _, fk := range table.ForeignKeysToAdd {
-		if len(fk.References) < 1 {
-			continue
-		}
-		r := fk.References[0]
-		if fk.Field != nil && fk.Schema != nil && r != nil {
-			fkDef := fmt.Sprintf("CONSTRAINT fk_%s_%s_fkey FOREIGN KEY (%s) REFERENCES %s(id) ON DELETE CASCADE",
-				table.Schema.Table,
-				r.ForeignKey.DBName,
-				quoteIdentifier(fk.References[0].ForeignKey.DBName),
-				quoteIdentifier(r.PrimaryKey.Schema.Table))
+		column := foreignKeyColumn(fk)
+		refTable := referencedTable(fk)
+		if column != "" && refTable != "" {
+			local, referenced := g.foreignKeyColumns(fk)
+			fkDef := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s)%s",
+				g.quoteConstraintName(fmt.Sprintf("fk_%s_%s_fkey", table.Schema.Table, column)),
+				strings.Join(local, ", "),
+				g.quoteQualifiedIdentifier(refTable),
+				strings.Join(referenced, ", "),
+				foreignKeyActionsSQL(fk, g.defaultOnDelete()))
 			tableConstraints = append(tableConstraints, "    "+fkDef)
 		}
 	}
 
 	// Add unique indexes as table constraints, non-unique as separate statements
 	for _, idx := range table.IndexesToAdd {
-		idxName := idx.Name
-		if strings.HasPrefix(idxName, "idx_idx_") {
-			idxName = strings.Replace(idxName, "idx_idx_", "idx_", 1)
-		}
+		idxName := g.quoteIdentifier(indexName(idx))
 		fieldNames := make([]string, len(idx.Fields))
 		for i, f := range idx.Fields {
-			fieldNames[i] = quoteIdentifier(f.DBName)
+			fieldNames[i] = g.quoteIdentifier(f.DBName)
 		}
-		if strings.ToUpper(idx.Option) == "UNIQUE" {
+		if predicate := diff.IndexPredicate(idx); predicate != "" {
+			// Partial indexes cannot be table constraints
+			createIndex := "CREATE INDEX"
+			if isUniqueIndex(idx) {
+				createIndex = "CREATE UNIQUE INDEX"
+			}
+			indexSQLs = append(indexSQLs, fmt.Sprintf("%s %s ON %s (%s) WHERE %s;", createIndex, idxName, g.quoteQualifiedIdentifier(table.Schema.Table), strings.Join(fieldNames, ", "), predicate))
+		} else if isUniqueIndex(idx) {
 			idxDef := fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)",
 				idxName,
 				strings.Join(fieldNames, ", "))
 			tableConstraints = append(tableConstraints, "    "+idxDef)
+		} else if g.Dialect == DialectMySQL && isFullTextIndex(idx) {
+			tableConstraints = append(tableConstraints, fmt.Sprintf("    FULLTEXT INDEX %s (%s)", idxName, strings.Join(fieldNames, ", ")))
 		} else {
-			indexSQLs = append(indexSQLs, fmt.Sprintf("CREATE INDEX %s ON %s (%s);", idxName, quoteIdentifier(table.Schema.Table), strings.Join(fieldNames, ", ")))
+			indexSQLs = append(indexSQLs, fmt.Sprintf("CREATE INDEX %s ON %s (%s);", idxName, g.quoteQualifiedIdentifier(table.Schema.Table), strings.Join(fieldNames, ", ")))
 		}
 	}
 
+	for _, uq := range table.UniquesToAdd {
+		tableConstraints = append(tableConstraints, "    "+g.uniqueConstraintSQL(uq))
+	}
+
+	// Add check constraints as table constraints
+	for _, chk := range table.ChecksToAdd {
+		tableConstraints = append(tableConstraints, fmt.Sprintf("    CONSTRAINT %s CHECK (%s)", g.quoteIdentifier(chk.Name), chk.Constraint))
+	}
+
 	// Combine columns and constraints, filter out empty lines
 	allLines := append(columns, tableConstraints...)
 	var nonEmptyLines []string
@@ -571,95 +1355,387 @@ func (g *Generator) generateCreateTableSQL(table diff.TableDiff) string {
 		}
 	}
 
-	// Create table SQL
-	createTableSQL := fmt.Sprintf("CREATE TABLE %s (\n%s\n);", quoteIdentifier(table.Schema.Table), strings.Join(nonEmptyLines, ",\n"))
+	// Create table SQL, with any table options trailing the column list
+	createTableSQL := fmt.Sprintf("CREATE TABLE %s (\n%s\n)", g.quoteQualifiedIdentifier(table.Schema.Table), strings.Join(nonEmptyLines, ",\n"))
+	if options := g.tableOptions(table.Schema); options != "" {
+		createTableSQL += " " + options
+	}
+	createTableSQL += ";"
 
 	// Combine table and index creation
-	var stmts []string
-	stmts = append(stmts, createTableSQL)
-	stmts = append(stmts, indexSQLs...)
+	return append([]string{createTableSQL}, indexSQLs...)
+}
+
+// tableOptions returns the table options declared by the model behind a
+// schema through TableOptioner, followed on MySQL by the table's character
+// set and collation unless the options already set one
+func (g *Generator) tableOptions(s *schema.Schema) string {
+	if s == nil {
+		return ""
+	}
+	var model any
+	if s.ModelType != nil {
+		model = reflect.New(s.ModelType).Interface()
+	}
 
-	return strings.Join(stmts, "\n")
+	options := ""
+	if optioner, ok := model.(TableOptioner); ok {
+		options = strings.TrimSpace(optioner.TableOptions(g.dialect()))
+	}
+	if g.dialect() != DialectMySQL {
+		return options
+	}
+
+	charset, collation := g.Charset, g.Collation
+	if charsetter, ok := model.(TableCharsetter); ok {
+		if modelCharset, modelCollation := charsetter.TableCharset(); modelCharset != "" || modelCollation != "" {
+			charset, collation = modelCharset, modelCollation
+		}
+	}
+	upper := strings.ToUpper(options)
+	if charset != "" && !strings.Contains(upper, "CHARSET") && !strings.Contains(upper, "CHARACTER SET") {
+		options = strings.TrimSpace(options + " DEFAULT CHARSET=" + charset)
+	}
+	if collation != "" && !strings.Contains(upper, "COLLATE") {
+		options = strings.TrimSpace(options + " COLLATE=" + collation)
+	}
+	return options
+}
+
+// dialect returns the target dialect, defaulting to Postgres
+func (g *Generator) dialect() string {
+	if g.Dialect == "" {
+		return DialectPostgres
+	}
+	return g.Dialect
+}
+
+// DialectOf returns the dialect of the database db is connected to, or ""
+// when the generator has no dialect of that name
+func DialectOf(db *gorm.DB) string {
+	switch name := db.Name(); name {
+	case DialectPostgres, DialectMySQL, DialectSQLite:
+		return name
+	}
+	return ""
 }
 
 // generateModifyTableSQL generates the SQL for modifying a table with proper formatting
 func (g *Generator) generateModifyTableSQL(table diff.TableDiff) []string {
 	var statements []string
 
+	// Renamed columns take their new name before anything refers to it
+	for _, rename := range table.FieldsToRename {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", g.quoteIdentifier(table.Schema.Table), g.quoteIdentifier(rename.OldName), g.quoteIdentifier(rename.NewName)))
+	}
+
 	// Add columns with proper formatting
 	for _, col := range table.FieldsToAdd {
-		sqlType := mapGoTypeToSQLTypeWithAutoIncrement(string(col.DataType), col.PrimaryKey)
-		columnDef := fmt.Sprintf("%s %s", quoteIdentifier(col.DBName), sqlType)
+		sqlType := g.columnSQLType(col)
+		columnDef := fmt.Sprintf("%s %s", g.quoteIdentifier(col.DBName), sqlType)
 		if col.NotNull {
 			columnDef += " NOT NULL"
 		}
 		if col.DefaultValue != "" {
 			columnDef += fmt.Sprintf(" DEFAULT %v", col.DefaultValue)
 		}
-		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", quoteIdentifier(table.Schema.Table), columnDef))
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s %s %s;", g.quoteIdentifier(table.Schema.Table), g.addColumn(), columnDef))
+	}
+
+	// Drop foreign keys before the columns they constrain
+	for _, fk := range table.ForeignKeysToDrop {
+		statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "FOREIGN KEY", g.quoteConstraintName(foreignKeyName(table.Schema.Table, fk))))
+	}
+
+	// Drop unique constraints before the columns they cover
+	for _, uq := range table.UniquesToDrop {
+		statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "UNIQUE", g.quoteIdentifier(uq.Name)))
+	}
+
+	// Drop removed indexes, and modified ones to recreate them below
+	for _, idx := range table.IndexesToDrop {
+		statements = append(statements, g.dropIndexSQL(table.Schema.Table, indexName(idx)))
+	}
+	for _, idx := range table.IndexesToModify {
+		statements = append(statements, g.dropIndexSQL(table.Schema.Table, indexName(idx)))
 	}
 
 	// Drop columns with proper formatting
 	for _, col := range table.FieldsToDrop {
-		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", quoteIdentifier(table.Schema.Table), quoteIdentifier(col.DBName)))
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s %s %s;", g.quoteIdentifier(table.Schema.Table), g.dropColumn(), g.quoteIdentifier(col.DBName)))
+	}
+
+	// A changed primary key is dropped before its old columns are altered
+	// and added back over the new columns afterwards
+	oldKey, newKey := g.primaryKeyColumns(table, true), g.primaryKeyColumns(table, false)
+	keyChanged := strings.Join(oldKey, ",") != strings.Join(newKey, ",")
+	if keyChanged && len(oldKey) > 0 {
+		statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "PRIMARY KEY", table.Schema.Table+"_pkey"))
 	}
 
 	// Modify columns with proper formatting
 	for _, col := range table.FieldsToModify {
-		sqlType := mapGoTypeToSQLTypeWithAutoIncrement(string(col.DataType), col.PrimaryKey)
-		columnDef := fmt.Sprintf("%s %s", quoteIdentifier(col.DBName), sqlType)
-		if col.NotNull {
-			columnDef += " NOT NULL"
+		prev := table.PreviousFields[col.DBName]
+		if g.dialect() == DialectPostgres && diff.PrimaryKeyStrategyChanged(prev, col) {
+			statements = append(statements, g.keyStrategySQL(table.Schema.Table, prev, col)...)
+			continue
 		}
-		if col.DefaultValue != "" {
-			columnDef += fmt.Sprintf(" DEFAULT %v", col.DefaultValue)
+		if diff.PrimaryKeyChanged(prev, col) {
+			// Any other change to the column is handled like for other columns
+			prev, col = withoutPrimaryKey(prev), withoutPrimaryKey(col)
+			if diff.FieldsEqual(prev, col) {
+				continue
+			}
+		}
+		if diff.AttributesOnlyChange(prev, col) {
+			statements = append(statements, g.alterColumnAttributes(table.Schema.Table, prev, col)...)
+			continue
 		}
-		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s;", quoteIdentifier(table.Schema.Table), columnDef))
+		if diff.DecimalSizeOnlyChange(prev, col) {
+			statements = append(statements, g.alterDecimalSize(table.Schema.Table, col))
+			continue
+		}
+		statements = append(statements, g.alterColumnType(table.Schema.Table, prev, col)...)
+	}
+
+	if keyChanged && len(newKey) > 0 {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s);", g.quoteIdentifier(table.Schema.Table), strings.Join(newKey, ", ")))
 	}
 
 	// Add foreign keys with proper formatting
 	for _, fk := range table.ForeignKeysToAdd {
-		if fk.Field != nil && fk.Schema != nil && len(fk.References) > 0 {
-			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT fk_%s_%s_fkey FOREIGN KEY (%s) REFERENCES %s(id) ON DELETE CASCADE;",
-				quoteIdentifier(table.Schema.Table),
-				table.Schema.Table,
-				fk.References[0].ForeignKey.DBName,
-				quoteIdentifier(fk.References[0].ForeignKey.DBName),
-				quoteIdentifier(fk.References[0].PrimaryKey.Schema.Table)),
+		column := foreignKeyColumn(fk)
+		refTable := referencedTable(fk)
+		if column != "" && refTable != "" {
+			local, referenced := g.foreignKeyColumns(fk)
+			notValid := ""
+			if g.notValidFKs() {
+				notValid = " NOT VALID"
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(%s)%s%s;",
+				g.quoteQualifiedIdentifier(table.Schema.Table),
+				g.quoteConstraintName(fmt.Sprintf("fk_%s_%s_fkey", table.Schema.Table, column)),
+				strings.Join(local, ", "),
+				g.quoteQualifiedIdentifier(refTable),
+				strings.Join(referenced, ", "),
+				foreignKeyActionsSQL(fk, g.defaultOnDelete()),
+				notValid),
 			)
 		}
 	}
 
+	for _, rename := range table.IndexesToRename {
+		statements = append(statements, g.renameIndexSQL(table.Schema.Table, rename.OldName, rename.NewName))
+	}
+
 	// Add indexes with proper formatting
+	for _, idx := range table.IndexesToModify {
+		statements = append(statements, g.createIndexSQL(table.Schema.Table, idx))
+	}
 	for _, idx := range table.IndexesToAdd {
-		idxName := idx.Name
-		if strings.HasPrefix(idxName, "idx_idx_") {
-			idxName = strings.Replace(idxName, "idx_idx_", "idx_", 1)
+		statements = append(statements, g.createIndexSQL(table.Schema.Table, idx))
+	}
+
+	for _, uq := range table.UniquesToAdd {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", g.quoteQualifiedIdentifier(table.Schema.Table), g.uniqueConstraintSQL(uq)))
+	}
+
+	// Drop and add check constraints
+	for _, chk := range table.ChecksToDrop {
+		statements = append(statements, g.dropConstraintSQL(table.Schema.Table, "CHECK", g.quoteIdentifier(chk.Name)))
+	}
+	for _, chk := range table.ChecksToAdd {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s);", g.quoteIdentifier(table.Schema.Table), g.quoteIdentifier(chk.Name), chk.Constraint))
+	}
+
+	if table.Comment != table.PreviousComment {
+		statements = append(statements, g.tableCommentSQL(table.Schema.Table, table.Comment))
+	}
+
+	return statements
+}
+
+// columnSequencesSQL creates the sequences the defaults of new columns draw
+// from, such as nextval('shared_seq'). A sequence may be shared between
+// tables, so it is only created if missing.
+func (g *Generator) columnSequencesSQL() []string {
+	var statements []string
+	seen := make(map[string]bool)
+	tables := append(append([]diff.TableDiff{}, g.SchemaDiff.TablesToCreate...), g.SchemaDiff.TablesToModify...)
+	for _, table := range tables {
+		for _, col := range table.FieldsToAdd {
+			sequence := diff.SequenceDefault(col)
+			if col.PrimaryKey || sequence == "" || seen[sequence] {
+				continue
+			}
+			seen[sequence] = true
+			statements = append(statements, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s;", g.quoteQualifiedIdentifier(sequence)))
 		}
-		fieldNames := make([]string, len(idx.Fields))
-		for i, f := range idx.Fields {
-			fieldNames[i] = quoteIdentifier(f.DBName)
+	}
+	return statements
+}
+
+// createdSequences returns the sequences Up creates for the nextval()
+// defaults of new columns and of columns whose default changes to one. Down
+// drops them after the columns and defaults that use them. Postgres refuses
+// to drop a sequence another column's default still draws from.
+func (g *Generator) createdSequences() []string {
+	var sequences []string
+	seen := make(map[string]bool)
+	add := func(sequence string) {
+		if sequence != "" && !seen[sequence] {
+			seen[sequence] = true
+			sequences = append(sequences, sequence)
 		}
-		if strings.ToUpper(idx.Option) == "UNIQUE" {
-			statements = append(statements, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);",
-				idxName,
-				quoteIdentifier(table.Schema.Table),
-				strings.Join(fieldNames, ", ")))
-		} else {
-			statements = append(statements, fmt.Sprintf("CREATE INDEX %s ON %s (%s);",
-				idxName,
-				quoteIdentifier(table.Schema.Table),
-				strings.Join(fieldNames, ", ")))
+	}
+	tables := append(append([]diff.TableDiff{}, g.SchemaDiff.TablesToCreate...), g.SchemaDiff.TablesToModify...)
+	for _, table := range tables {
+		for _, col := range table.FieldsToAdd {
+			if !col.PrimaryKey {
+				add(diff.SequenceDefault(col))
+			}
 		}
 	}
+	for _, table := range g.SchemaDiff.TablesToModify {
+		for _, col := range table.FieldsToModify {
+			prev := table.PreviousFields[col.DBName]
+			if prev == nil || diff.PrimaryKeyStrategyChanged(prev, col) || !diff.DefaultChanged(prev, col) {
+				continue
+			}
+			if sequence := diff.SequenceDefault(col); sequence != diff.SequenceDefault(prev) {
+				add(sequence)
+			}
+		}
+	}
+	return sequences
+}
 
+// indexName returns the name an index is created under
+func indexName(idx *schema.Index) string {
+	if strings.HasPrefix(idx.Name, "idx_idx_") {
+		return strings.Replace(idx.Name, "idx_idx_", "idx_", 1)
+	}
+	return idx.Name
+}
+
+// createIndexSQL creates an index on an existing table
+func (g *Generator) createIndexSQL(table string, idx *schema.Index) string {
+	fieldNames := make([]string, len(idx.Fields))
+	for i, f := range idx.Fields {
+		fieldNames[i] = g.quoteIdentifier(f.DBName)
+	}
+	switch {
+	case g.Dialect == DialectMySQL && isFullTextIndex(idx):
+		return fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (%s);",
+			g.quoteQualifiedIdentifier(table),
+			g.quoteIdentifier(indexName(idx)),
+			strings.Join(fieldNames, ", "))
+	case isUniqueIndex(idx):
+		return fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)%s;",
+			g.quoteIdentifier(indexName(idx)),
+			g.quoteQualifiedIdentifier(table),
+			strings.Join(fieldNames, ", "),
+			indexWhereClause(idx))
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)%s;",
+		g.quoteIdentifier(indexName(idx)),
+		g.quoteQualifiedIdentifier(table),
+		strings.Join(fieldNames, ", "),
+		indexWhereClause(idx))
+}
+
+// dropIndexSQL drops an index of table
+func (g *Generator) dropIndexSQL(table, name string) string {
+	if g.dialect() == DialectMySQL {
+		return fmt.Sprintf("DROP INDEX %s ON %s;", g.quoteIdentifier(name), g.quoteIdentifier(table))
+	}
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", g.quoteIdentifier(name))
+}
+
+// renameIndexSQL renames an index of table
+func (g *Generator) renameIndexSQL(table, oldName, newName string) string {
+	if g.dialect() == DialectMySQL {
+		return fmt.Sprintf("ALTER TABLE %s RENAME INDEX %s TO %s;", g.quoteIdentifier(table), g.quoteIdentifier(oldName), g.quoteIdentifier(newName))
+	}
+	return fmt.Sprintf("ALTER INDEX %s RENAME TO %s;", g.quoteIdentifier(oldName), g.quoteIdentifier(newName))
+}
+
+// sequenceOptionsSQL sets the start value and increment that the
+// auto-increment columns of a created table declare with the
+// autoIncrementStart and autoIncrementIncrement tags. MySQL only has a
+// per-table start value, and SQLite has neither.
+func (g *Generator) sequenceOptionsSQL(table diff.TableDiff) []string {
+	if g.dialect() == DialectSQLite {
+		return nil
+	}
+	var statements []string
+	for _, col := range table.FieldsToAdd {
+		if !col.PrimaryKey && !col.AutoIncrement {
+			continue
+		}
+		start, _ := strconv.ParseInt(col.TagSettings["AUTOINCREMENTSTART"], 10, 64)
+		increment := col.AutoIncrementIncrement
+		if increment <= 1 {
+			increment = 0
+		}
+		if start == 0 && increment == 0 {
+			continue
+		}
+
+		if g.dialect() == DialectMySQL {
+			if start > 0 {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d;", g.quoteIdentifier(table.Schema.Table), start))
+			}
+			continue
+		}
+		if g.dialect() == DialectCockroach && !diff.IsIdentity(col) {
+			// SERIAL8 keys have no sequence to alter
+			continue
+		}
+
+		var options []string
+		if increment > 0 {
+			options = append(options, fmt.Sprintf("INCREMENT BY %d", increment))
+		}
+		if start > 0 {
+			options = append(options, fmt.Sprintf("RESTART WITH %d", start))
+		}
+		if diff.IsIdentity(col) {
+			// An identity column changes its sequence options with SET
+			if increment > 0 {
+				options[0] = "SET " + options[0]
+			}
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;",
+				g.quoteIdentifier(table.Schema.Table), g.quoteIdentifier(col.DBName), strings.Join(options, " ")))
+		} else {
+			statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s %s;", g.quoteQualifiedIdentifier(table.Schema.Table+"_"+col.DBName+"_seq"), strings.Join(options, " ")))
+		}
+	}
 	return statements
 }
 
+// tableCommentSQL sets a table comment, clearing it when comment is empty
+func (g *Generator) tableCommentSQL(table, comment string) string {
+	literal := "NULL"
+	if comment != "" {
+		literal = "'" + strings.ReplaceAll(comment, "'", "''") + "'"
+	}
+	if g.dialect() == DialectMySQL {
+		if comment == "" {
+			literal = "''"
+		}
+		return fmt.Sprintf("ALTER TABLE %s COMMENT = %s;", g.quoteIdentifier(table), literal)
+	}
+	return fmt.Sprintf("COMMENT ON TABLE %s IS %s;", g.quoteIdentifier(table), literal)
+}
+
 func (g *Generator) validateSchemaDiff(diff *diff.SchemaDiff) error {
 	if diff == nil {
 		return fmt.Errorf("schema diff cannot be nil")
 	}
+	if !foreignKeyActions[g.defaultOnDelete()] {
+		return fmt.Errorf("unsupported default ON DELETE action %s", g.DefaultOnDelete)
+	}
 
 	// Collect all table names first
 	tableNames := make(map[string]bool)
@@ -669,6 +1745,15 @@ func (g *Generator) validateSchemaDiff(diff *diff.SchemaDiff) error {
 
 	columnNames := make(map[string]map[string]bool)
 
+	// Enum types in the diff are valid column types
+	enumTypes := make(map[string]bool)
+	for _, enum := range diff.EnumsToCreate {
+		enumTypes[strings.ToLower(enum.Name)] = true
+	}
+	for _, enum := range diff.EnumsToAlter {
+		enumTypes[strings.ToLower(enum.Name)] = true
+	}
+
 	// Validate tables to create
 	for _, table := range diff.TablesToCreate {
 		// Validate table name
@@ -693,22 +1778,23 @@ func (g *Generator) validateSchemaDiff(diff *diff.SchemaDiff) error {
 			columnNames[table.Schema.Table][col.DBName] = true
 
 			// Validate column type
-			if !isValidColumnType(string(col.DataType)) {
+			_, mapped := g.mappedType(col)
+			if !mapped && !isValidColumnType(string(col.DataType)) && !enumTypes[strings.ToLower(string(col.DataType))] {
 				return fmt.Errorf("unsupported column type %s for column %s in table %s", col.DataType, col.DBName, table.Schema.Table)
 			}
 		}
 
 		// Validate foreign keys
 		for _, fk := range table.ForeignKeysToAdd {
-			if fk.Field != nil {
-				if !columnNames[table.Schema.Table][fk.References[0].ForeignKey.DBName] {
-					return fmt.Errorf("foreign key column %s does not exist in table %s", fk.References[0].ForeignKey.DBName, table.Schema.Table)
+			if column := foreignKeyColumn(fk); column != "" {
+				if !columnNames[table.Schema.Table][column] {
+					return fmt.Errorf("foreign key column %s does not exist in table %s", column, table.Schema.Table)
 				}
 			}
 		}
 
 		// Validate indexes
-		for _, idx := range table.IndexesToAdd {
+		for _, idx := range append(append([]*schema.Index{}, table.IndexesToAdd...), table.IndexesToModify...) {
 			// Check if indexed columns exist
 			for _, col := range idx.Fields {
 				if !columnNames[table.Schema.Table][col.DBName] {
@@ -718,6 +1804,43 @@ func (g *Generator) validateSchemaDiff(diff *diff.SchemaDiff) error {
 		}
 	}
 
+	// Validate referenced columns and actions of foreign keys
+	for _, table := range diff.TablesToCreate {
+		if err := validateReferencedColumns(table.Schema.Table, table.ForeignKeysToAdd, columnNames); err != nil {
+			return err
+		}
+		if err := validateForeignKeyActions(table.Schema.Table, table.ForeignKeysToAdd, g.defaultOnDelete()); err != nil {
+			return err
+		}
+	}
+	for _, table := range diff.TablesToModify {
+		if err := validateReferencedColumns(table.Schema.Table, table.ForeignKeysToAdd, columnNames); err != nil {
+			return err
+		}
+		if err := validateForeignKeyActions(table.Schema.Table, table.ForeignKeysToAdd, g.defaultOnDelete()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateReferencedColumns checks that foreign keys pointing at tables created
+// in the diff reference a column those tables define
+func validateReferencedColumns(table string, fks []*schema.Relationship, columnNames map[string]map[string]bool) error {
+	for _, fk := range fks {
+		refTable := referencedTable(fk)
+		refColumns, ok := columnNames[refTable]
+		if !ok {
+			continue
+		}
+		_, referenced := foreignKeyColumnNames(fk)
+		for _, refColumn := range referenced {
+			if !refColumns[refColumn] {
+				return fmt.Errorf("foreign key %s in table %s references non-existent column %s in table %s", foreignKeyColumn(fk), table, refColumn, refTable)
+			}
+		}
+	}
 	return nil
 }
 
@@ -740,6 +1863,9 @@ func isValidColumnType(columnType string) bool {
 		"json":      true,
 		"jsonb":     true,
 		"uuid":      true,
+		"bytes":     true,
+		"bytea":     true,
+		"blob":      true,
 	}
 
 	// Allow parameterized types like decimal(10,2), varchar(255), etc.
@@ -754,7 +1880,470 @@ func isValidColumnType(columnType string) bool {
 	return false
 }
 
-// quoteIdentifier wraps a SQL identifier (table or column name) in double quotes
-func quoteIdentifier(name string) string {
-	return "\"" + name + "\""
+// foreignKeyColumn returns the local column of a foreign key relationship,
+// preferring the introspected reference over the relationship field
+func foreignKeyColumn(fk *schema.Relationship) string {
+	if len(fk.References) > 0 && fk.References[0] != nil && fk.References[0].ForeignKey != nil {
+		return fk.References[0].ForeignKey.DBName
+	}
+	if fk.Field != nil {
+		return fk.Field.DBName
+	}
+	return ""
+}
+
+// referencedTable returns the table a foreign key relationship points at
+func referencedTable(fk *schema.Relationship) string {
+	if len(fk.References) > 0 && fk.References[0] != nil && fk.References[0].PrimaryKey != nil && fk.References[0].PrimaryKey.Schema != nil {
+		return fk.References[0].PrimaryKey.Schema.Table
+	}
+	if fk.Schema != nil {
+		return fk.Schema.Table
+	}
+	return ""
+}
+
+// alterColumnAttributes changes a column's nullability and default from one
+// definition to another without touching its type
+func (g *Generator) alterColumnAttributes(table string, from, to *schema.Field) []string {
+	var statements []string
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", g.quoteIdentifier(table), g.quoteIdentifier(to.DBName))
+	// nextval() needs its sequence, both for the default and the backfill
+	if sequence := diff.SequenceDefault(to); sequence != "" && diff.DefaultChanged(from, to) {
+		statements = append(statements, fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s;", g.quoteQualifiedIdentifier(sequence)))
+	}
+	if from.NotNull != to.NotNull {
+		if to.NotNull {
+			// Existing NULLs would fail the constraint, so they take the
+			// column default first
+			backfill := to
+			if backfill.DefaultValue == "" {
+				backfill = from
+			}
+			if backfill.DefaultValue != "" {
+				statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL;",
+					g.quoteIdentifier(table), g.quoteIdentifier(to.DBName), defaultLiteral(backfill), g.quoteIdentifier(to.DBName)))
+			}
+			statements = append(statements, prefix+" SET NOT NULL;")
+		} else {
+			statements = append(statements, prefix+" DROP NOT NULL;")
+		}
+	}
+	if diff.DefaultChanged(from, to) {
+		if to.DefaultValue != "" {
+			statements = append(statements, fmt.Sprintf("%s SET DEFAULT %s;", prefix, defaultLiteral(to)))
+		} else {
+			statements = append(statements, prefix+" DROP DEFAULT;")
+		}
+	}
+	return statements
+}
+
+// alterDecimalSize changes a numeric column to the precision and scale of to
+func (g *Generator) alterDecimalSize(table string, to *schema.Field) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", g.quoteIdentifier(table), g.quoteIdentifier(to.DBName), decimalSQLType(to))
+}
+
+// alterColumnType changes a column to the type of to, followed by any change
+// to its nullability or default. The conversion uses the cast expression of
+// the column's using tag, e.g. `gorm:"using:code::integer"`, or a plain cast
+// when Postgres has no implicit one. MySQL restates the whole column with
+// MODIFY COLUMN instead.
+func (g *Generator) alterColumnType(table string, from, to *schema.Field) []string {
+	sqlType := g.modifiedColumnType(to)
+	if g.dialect() == DialectMySQL {
+		columnDef := fmt.Sprintf("%s %s", g.quoteIdentifier(to.DBName), sqlType)
+		if to.NotNull {
+			columnDef += " NOT NULL"
+		}
+		if to.DefaultValue != "" {
+			columnDef += " DEFAULT " + defaultLiteral(to)
+		}
+		return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", g.quoteIdentifier(table), columnDef)}
+	}
+
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", g.quoteIdentifier(table), g.quoteIdentifier(to.DBName), sqlType)
+	if using := to.TagSettings["USING"]; using != "" {
+		alter += fmt.Sprintf(" USING (%s)", using)
+	} else if needsUsingCast(from, to) {
+		alter += fmt.Sprintf(" USING (%s::%s)", g.quoteIdentifier(to.DBName), sqlType)
+	}
+	// The old default may not cast to the new type, so it goes first
+	var statements []string
+	if from.DefaultValue != "" && diff.DefaultChanged(from, to) {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;", g.quoteIdentifier(table), g.quoteIdentifier(to.DBName)))
+		from = withoutDefault(from)
+	}
+	statements = append(statements, alter+";")
+	return append(statements, g.alterColumnAttributes(table, from, to)...)
+}
+
+// numericTypes are the normalized types Postgres converts between without
+// an explicit cast
+var numericTypes = map[string]bool{"smallint": true, "integer": true, "bigint": true, "decimal": true}
+
+// needsUsingCast reports whether changing a column's type needs a USING
+// clause. Numbers convert among themselves and anything converts to a
+// string; other changes, such as from a string, need an explicit cast.
+func needsUsingCast(from, to *schema.Field) bool {
+	fromType, toType := diff.NormalizedType(from), diff.NormalizedType(to)
+	if fromType == toType || toType == "varchar" {
+		return false
+	}
+	return !numericTypes[fromType] || !numericTypes[toType]
+}
+
+// withoutDefault returns a copy of a field without its default
+func withoutDefault(field *schema.Field) *schema.Field {
+	copied := *field
+	copied.DefaultValue = ""
+	return &copied
+}
+
+// modifiedColumnType returns the SQL type of a column being altered.
+// Introspected columns carry the database type name, which only needs its
+// length or precision added back; serial types are not valid in ALTER COLUMN.
+func (g *Generator) modifiedColumnType(col *schema.Field) string {
+	switch dataType := strings.ToLower(string(col.DataType)); dataType {
+	case "varchar", "character varying", "bpchar", "char", "character":
+		if col.Size > 0 {
+			return fmt.Sprintf("%s(%d)", dataType, col.Size)
+		}
+	case "numeric", "decimal":
+		if col.Precision > 0 {
+			return decimalSQLType(col)
+		}
+	}
+	return g.columnSQLType(withoutPrimaryKey(col))
+}
+
+// primaryKeyColumns returns the quoted primary key columns of a modified
+// table, as they were before the change when previous is set. Columns are in
+// model order, followed by modified columns the model schema does not list.
+func (g *Generator) primaryKeyColumns(table diff.TableDiff, previous bool) []string {
+	fields := append([]*schema.Field{}, table.Schema.Fields...)
+	listed := make(map[string]bool)
+	for _, field := range fields {
+		listed[field.DBName] = true
+	}
+	for _, field := range table.FieldsToModify {
+		if !listed[field.DBName] {
+			fields = append(fields, field)
+		}
+	}
+
+	var columns []string
+	for _, field := range fields {
+		isKey := field.PrimaryKey
+		if prev, ok := table.PreviousFields[field.DBName]; ok && previous {
+			isKey = prev.PrimaryKey
+		}
+		if isKey {
+			columns = append(columns, g.quoteIdentifier(field.DBName))
+		}
+	}
+	return columns
+}
+
+// withoutPrimaryKey returns a copy of a field that is not part of the key
+func withoutPrimaryKey(field *schema.Field) *schema.Field {
+	copied := *field
+	copied.PrimaryKey = false
+	return &copied
+}
+
+// keyStrategySQL switches a Postgres primary key between a serial column and
+// an identity column. The old default or identity goes first, then the type
+// is altered, and the new sequence continues after the existing rows.
+func (g *Generator) keyStrategySQL(table string, from, to *schema.Field) []string {
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", g.quoteIdentifier(table), g.quoteIdentifier(to.DBName))
+	sequence := fmt.Sprintf("%s_%s_seq", table, to.DBName)
+	restart := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s;",
+		g.quoteIdentifier(table), to.DBName, g.quoteIdentifier(to.DBName), g.quoteIdentifier(table))
+
+	var statements []string
+	if diff.IsIdentity(from) {
+		statements = append(statements, prefix+" DROP IDENTITY IF EXISTS;")
+	} else {
+		statements = append(statements, prefix+" DROP DEFAULT;", fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", g.quoteQualifiedIdentifier(sequence)))
+	}
+
+	statements = append(statements, fmt.Sprintf("%s TYPE %s;", prefix, mapGoTypeToSQLType(string(to.DataType))))
+
+	if diff.IsIdentity(to) {
+		statements = append(statements, prefix+" ADD GENERATED BY DEFAULT AS IDENTITY;")
+	} else {
+		statements = append(statements,
+			fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s OWNED BY %s.%s;", g.quoteQualifiedIdentifier(sequence), g.quoteIdentifier(table), g.quoteIdentifier(to.DBName)),
+			fmt.Sprintf("%s SET DEFAULT nextval('%s');", prefix, sequence))
+	}
+	return append(statements, restart)
+}
+
+// insertRowSQL inserts a reference row unless it conflicts with an existing
+// one, so re-running the statement is safe
+func (g *Generator) insertRowSQL(table string, row map[string]any) string {
+	columns := rowColumns(row)
+	names := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = g.quoteIdentifier(column)
+		values[i] = sqlLiteral(row[column])
+	}
+	if g.dialect() == DialectMySQL {
+		return fmt.Sprintf("INSERT IGNORE INTO %s (%s) VALUES (%s);", g.quoteIdentifier(table), strings.Join(names, ", "), strings.Join(values, ", "))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING;", g.quoteIdentifier(table), strings.Join(names, ", "), strings.Join(values, ", "))
+}
+
+// deleteRowSQL deletes a reference row matching all of its values
+func (g *Generator) deleteRowSQL(table string, row map[string]any) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s;", g.quoteIdentifier(table), g.rowConditions(row))
+}
+
+// updateRowSQL sets the values of the reference row identified by key
+func (g *Generator) updateRowSQL(table string, key, values map[string]any) string {
+	var assignments []string
+	for _, column := range rowColumns(values) {
+		assignments = append(assignments, fmt.Sprintf("%s = %s", g.quoteIdentifier(column), sqlLiteral(values[column])))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", g.quoteIdentifier(table), strings.Join(assignments, ", "), g.rowConditions(key))
+}
+
+// rowConditions matches a row on all of the given values
+func (g *Generator) rowConditions(row map[string]any) string {
+	var conditions []string
+	for _, column := range rowColumns(row) {
+		if row[column] == nil {
+			conditions = append(conditions, fmt.Sprintf("%s IS NULL", g.quoteIdentifier(column)))
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = %s", g.quoteIdentifier(column), sqlLiteral(row[column])))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// rowColumns returns the columns of a row in name order
+func rowColumns(row map[string]any) []string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// sqlLiteral renders a Go value as a SQL literal
+func sqlLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		return stringLiteral(v.Format("2006-01-02 15:04:05.999999-07:00"))
+	case []byte:
+		return stringLiteral(string(v))
+	default:
+		return stringLiteral(fmt.Sprint(v))
+	}
+}
+
+// stringLiteral quotes a value as a SQL string
+func stringLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// defaultLiteral returns a column default as SQL. Introspected string
+// defaults come back unquoted, so plain words on text columns are quoted.
+func defaultLiteral(col *schema.Field) string {
+	value := col.DefaultValue
+	switch strings.ToLower(string(col.DataType)) {
+	case "string", "varchar", "text", "character varying":
+		if !strings.HasPrefix(value, "'") && !strings.Contains(value, "(") && !strings.EqualFold(value, "null") {
+			return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+		}
+	}
+	return value
+}
+
+// foreignKeyColumns returns the quoted local columns of a foreign key and the
+// quoted columns they reference, one pair per reference
+func (g *Generator) foreignKeyColumns(fk *schema.Relationship) (local, referenced []string) {
+	localNames, referencedNames := foreignKeyColumnNames(fk)
+	for i := range localNames {
+		local = append(local, g.quoteIdentifier(localNames[i]))
+		referenced = append(referenced, g.quoteIdentifier(referencedNames[i]))
+	}
+	return local, referenced
+}
+
+// foreignKeyColumnNames returns the local columns of a foreign key and the
+// columns they reference, one pair per reference. A referenced column that
+// is not known defaults to id.
+func foreignKeyColumnNames(fk *schema.Relationship) (local, referenced []string) {
+	for _, ref := range fk.References {
+		if ref == nil || ref.ForeignKey == nil {
+			continue
+		}
+		refColumn := "id"
+		if ref.PrimaryKey != nil && ref.PrimaryKey.DBName != "" {
+			refColumn = ref.PrimaryKey.DBName
+		}
+		local = append(local, ref.ForeignKey.DBName)
+		referenced = append(referenced, refColumn)
+	}
+	if len(local) == 0 {
+		return []string{foreignKeyColumn(fk)}, []string{"id"}
+	}
+	return local, referenced
+}
+
+// foreignKeyActions are the referential actions a foreign key may declare
+var foreignKeyActions = map[string]bool{
+	"CASCADE":     true,
+	"SET NULL":    true,
+	"SET DEFAULT": true,
+	"RESTRICT":    true,
+	"NO ACTION":   true,
+}
+
+// foreignKeyActionsSQL returns the ON DELETE and ON UPDATE clauses of a
+// foreign key. ON DELETE falls back to defaultOnDelete when not declared;
+// ON UPDATE is left to the database.
+func foreignKeyActionsSQL(fk *schema.Relationship, defaultOnDelete string) string {
+	onDelete, onUpdate := diff.ForeignKeyActions(fk)
+	if onDelete == "" {
+		onDelete = defaultOnDelete
+	}
+	var sql string
+	if onDelete != "" {
+		sql += " ON DELETE " + onDelete
+	}
+	if onUpdate != "" {
+		sql += " ON UPDATE " + onUpdate
+	}
+	return sql
+}
+
+// validateForeignKeyActions rejects unknown actions, and SET NULL on a
+// foreign key column that cannot be null. A foreign key without an ON DELETE
+// action is checked against defaultOnDelete, which it is created with.
+func validateForeignKeyActions(table string, fks []*schema.Relationship, defaultOnDelete string) error {
+	for _, fk := range fks {
+		column := fk.Field
+		if len(fk.References) > 0 && fk.References[0] != nil && fk.References[0].ForeignKey != nil {
+			column = fk.References[0].ForeignKey
+		}
+		onDelete, onUpdate := diff.ForeignKeyActions(fk)
+		if onDelete == "" {
+			onDelete = defaultOnDelete
+		}
+		for _, action := range []string{onDelete, onUpdate} {
+			if action == "" {
+				continue
+			}
+			if !foreignKeyActions[action] {
+				return fmt.Errorf("unsupported foreign key action %s on %s.%s", action, table, foreignKeyColumn(fk))
+			}
+			if action == "SET NULL" && column != nil && column.NotNull {
+				return fmt.Errorf("foreign key %s.%s cannot use SET NULL on a NOT NULL column", table, foreignKeyColumn(fk))
+			}
+		}
+	}
+	return nil
+}
+
+// foreignKeyName returns the constraint name of a foreign key: the introspected
+// name when known, otherwise the name this generator gives new constraints
+func foreignKeyName(table string, fk *schema.Relationship) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+	return fmt.Sprintf("fk_%s_%s_fkey", table, foreignKeyColumn(fk))
+}
+
+// dropConstraintSQL drops a constraint of a kind (CHECK, FOREIGN KEY, UNIQUE
+// or PRIMARY KEY) by its already quoted name. MySQL before 8.0.19 has no DROP
+// CONSTRAINT, so there each kind is dropped with its own clause.
+func (g *Generator) dropConstraintSQL(table, kind, name string) string {
+	if g.dialect() == DialectMySQL {
+		switch kind {
+		case "CHECK":
+			return fmt.Sprintf("ALTER TABLE %s DROP CHECK %s;", g.quoteIdentifier(table), name)
+		case "FOREIGN KEY":
+			return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", g.quoteIdentifier(table), name)
+		case "UNIQUE":
+			return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s;", g.quoteIdentifier(table), name)
+		case "PRIMARY KEY":
+			return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY;", g.quoteIdentifier(table))
+		}
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", g.quoteIdentifier(table), name)
+}
+
+// uniqueConstraintSQL is the table constraint clause of a unique constraint
+func (g *Generator) uniqueConstraintSQL(uq diff.UniqueConstraint) string {
+	columns := make([]string, len(uq.Columns))
+	for i, column := range uq.Columns {
+		columns[i] = g.quoteIdentifier(column)
+	}
+	return fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", g.quoteIdentifier(uq.Name), strings.Join(columns, ", "))
+}
+
+// isUniqueIndex reports whether an index is unique, either from GORM's
+// uniqueIndex tag (Class) or from introspection (Option)
+func isUniqueIndex(idx *schema.Index) bool {
+	return strings.ToUpper(idx.Class) == "UNIQUE" || strings.ToUpper(idx.Option) == "UNIQUE"
+}
+
+// indexWhereClause returns the WHERE clause of a partial index, if any
+func indexWhereClause(idx *schema.Index) string {
+	if predicate := diff.IndexPredicate(idx); predicate != "" {
+		return " WHERE " + predicate
+	}
+	return ""
+}
+
+// isFullTextIndex reports whether an index was declared with class:FULLTEXT
+func isFullTextIndex(idx *schema.Index) bool {
+	return strings.ToUpper(idx.Class) == "FULLTEXT"
+}
+
+// quoteQualifiedIdentifier quotes a possibly schema-qualified name part by
+// part, so auxstream.tracks becomes "auxstream"."tracks"
+func (g *Generator) quoteQualifiedIdentifier(name string) string {
+	if schemaName, table, ok := strings.Cut(name, "."); ok {
+		return g.quoteIdentifier(schemaName) + "." + g.quoteIdentifier(table)
+	}
+	return g.quoteIdentifier(name)
+}
+
+// quoteConstraintName quotes a constraint name built from a schema-qualified
+// table, which would otherwise not parse
+func (g *Generator) quoteConstraintName(name string) string {
+	if strings.Contains(name, ".") {
+		return g.quoteIdentifier(name)
+	}
+	return name
+}
+
+// quoteIdentifier wraps a SQL identifier (table or column name) in the
+// dialect's quotes: backticks on MySQL, double quotes elsewhere
+func (g *Generator) quoteIdentifier(name string) string {
+	quote := g.identifierQuote()
+	return quote + name + quote
+}
+
+// identifierQuote is the character the dialect quotes identifiers with
+func (g *Generator) identifierQuote() string {
+	if g.dialect() == DialectMySQL {
+		return "`"
+	}
+	return "\""
 }
@@ -1,9 +1,17 @@
 package generator
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"go/parser"
+	"go/token"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/beesaferoot/gorm-migrate/migration/diff"
 	"github.com/stretchr/testify/require"
@@ -29,11 +37,435 @@ func TestGenerateCreateTableSQL_ForeignKey(t *testing.T) {
 	}
 
 	sql := gen.generateCreateTableSQL(table)
-	require.Contains(t, sql, "CONSTRAINT fk_orders_user_id_fkey FOREIGN KEY (\"user_id\") REFERENCES \"users\"(id) ON DELETE CASCADE")
+	require.Contains(t, sql, "CONSTRAINT fk_orders_user_id_fkey FOREIGN KEY (\"user_id\") REFERENCES \"users\"(\"id\") ON DELETE CASCADE")
 	require.Contains(t, sql, "CREATE TABLE \"orders\" (")
 	require.NotContains(t, sql, "DEFAULT NULL\n\tDEFAULT NULL")
 }
 
+func TestGenerateSQL_ForeignKeyReferencedColumns(t *testing.T) {
+	countryCode := &schema.Field{DBName: "country_code"}
+	fk := &schema.Relationship{
+		Field:      countryCode,
+		Schema:     &schema.Schema{Table: "countries"},
+		References: []*schema.Reference{{ForeignKey: countryCode, PrimaryKey: &schema.Field{DBName: "code"}}},
+	}
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "cities"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: true},
+			{DBName: "country_code", DataType: "string"},
+		},
+		ForeignKeysToAdd: []*schema.Relationship{fk},
+	}
+
+	g := &Generator{}
+	require.Contains(t, g.generateCreateTableSQL(table), `FOREIGN KEY ("country_code") REFERENCES "countries"("code") ON DELETE CASCADE`)
+	modified := diff.TableDiff{Schema: table.Schema, ForeignKeysToAdd: table.ForeignKeysToAdd}
+	require.Contains(t, g.generateModifyTableSQL(modified)[0], `FOREIGN KEY ("country_code") REFERENCES "countries"("code") ON DELETE CASCADE;`)
+
+	// A composite reference lists every column pair
+	regionCode := &schema.Field{DBName: "region_code"}
+	composite := &schema.Relationship{
+		Field:  countryCode,
+		Schema: &schema.Schema{Table: "regions"},
+		References: []*schema.Reference{
+			{ForeignKey: countryCode, PrimaryKey: &schema.Field{DBName: "country_code"}},
+			{ForeignKey: regionCode, PrimaryKey: &schema.Field{DBName: "code"}},
+		},
+	}
+	modified.ForeignKeysToAdd = []*schema.Relationship{composite}
+	require.Contains(t, g.generateModifyTableSQL(modified)[0], `FOREIGN KEY ("country_code", "region_code") REFERENCES "regions"("country_code", "code")`)
+
+	// The referenced column must exist on a table created alongside
+	countries := diff.TableDiff{
+		Schema:      &schema.Schema{Table: "countries"},
+		FieldsToAdd: []*schema.Field{{DBName: "code", DataType: "string", PrimaryKey: true}},
+	}
+	require.NoError(t, g.validateSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{countries, table}}))
+	countries.FieldsToAdd[0].DBName = "iso"
+	require.ErrorContains(t, g.validateSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{countries, table}}), "non-existent column code")
+}
+
+func TestUpStatements_DefaultWithSemicolon(t *testing.T) {
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+		Schema:      &schema.Schema{Table: "notes"},
+		FieldsToAdd: []*schema.Field{{DBName: "body", DataType: schema.String, DefaultValue: "'first;\nsecond'"}},
+	}}})
+
+	statements, err := g.UpStatements()
+	require.NoError(t, err)
+	require.Equal(t, []string{`ALTER TABLE "notes" ADD COLUMN "body" varchar(255) DEFAULT 'first;
+second';`}, statements)
+
+	content, err := g.renderMigration("20240101000000", "add_note_body")
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(content, "db.Exec("), "the statement is executed whole, then undone in Down")
+}
+
+func TestGenerateSQL_NotValidForeignKeys(t *testing.T) {
+	fk := &schema.Relationship{
+		Field:  &schema.Field{DBName: "user_id"},
+		Schema: &schema.Schema{Table: "users"},
+	}
+	g := NewGenerator(t.TempDir())
+	g.SetNotValidFKs(true)
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+		Schema:           &schema.Schema{Table: "orders"},
+		ForeignKeysToAdd: []*schema.Relationship{fk},
+	}}})
+
+	statements, err := g.UpStatements()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`ALTER TABLE "orders" ADD CONSTRAINT fk_orders_user_id_fkey FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE NOT VALID;`,
+		`ALTER TABLE "orders" VALIDATE CONSTRAINT fk_orders_user_id_fkey;`,
+	}, statements)
+	require.True(t, g.nonTransactional(), "the constraint must be committed before it is validated")
+
+	// Tables created in the migration have no rows to validate
+	created := diff.TableDiff{
+		Schema:           &schema.Schema{Table: "orders"},
+		FieldsToAdd:      []*schema.Field{{DBName: "user_id", DataType: schema.Int}},
+		ForeignKeysToAdd: []*schema.Relationship{fk},
+	}
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{created}})
+	require.NotContains(t, g.generateCreateTableSQL(created), "NOT VALID")
+	require.Empty(t, g.validateForeignKeysSQL())
+	require.False(t, g.nonTransactional())
+}
+
+func TestGenerateSQL_ForeignKeyActions(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"", `REFERENCES "users"("id") ON DELETE CASCADE`},
+		{"OnDelete:CASCADE", `REFERENCES "users"("id") ON DELETE CASCADE`},
+		{"OnDelete:SET NULL", `REFERENCES "users"("id") ON DELETE SET NULL`},
+		{"OnDelete:RESTRICT,OnUpdate:CASCADE", `REFERENCES "users"("id") ON DELETE RESTRICT ON UPDATE CASCADE`},
+		{"OnDelete:NO ACTION,OnUpdate:SET NULL", `REFERENCES "users"("id") ON DELETE NO ACTION ON UPDATE SET NULL`},
+		{"fk_orders_user,OnDelete:set default", `REFERENCES "users"("id") ON DELETE SET DEFAULT`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			fk := &schema.Relationship{
+				Field:  &schema.Field{DBName: "user_id", TagSettings: map[string]string{"CONSTRAINT": tt.constraint}},
+				Schema: &schema.Schema{Table: "users"},
+			}
+			table := diff.TableDiff{
+				Schema: &schema.Schema{Table: "orders"},
+				FieldsToAdd: []*schema.Field{
+					{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: true},
+					{DBName: "user_id", DataType: "int"},
+				},
+				ForeignKeysToAdd: []*schema.Relationship{fk},
+			}
+
+			g := &Generator{}
+			require.Contains(t, g.generateCreateTableSQL(table), tt.want)
+
+			modified := diff.TableDiff{Schema: table.Schema, ForeignKeysToAdd: table.ForeignKeysToAdd}
+			require.Contains(t, strings.Join(g.generateModifyTableSQL(modified), "\n"), tt.want+";")
+		})
+	}
+}
+
+type testAuthor struct {
+	ID uint `gorm:"primaryKey"`
+}
+
+type testAuthoredPost struct {
+	ID           uint `gorm:"primaryKey"`
+	TestAuthorID *uint
+	TestAuthor   testAuthor `gorm:"constraint:OnDelete:SET NULL,OnUpdate:CASCADE"`
+}
+
+type testRequiredPost struct {
+	ID           uint       `gorm:"primaryKey"`
+	TestAuthorID uint       `gorm:"not null"`
+	TestAuthor   testAuthor `gorm:"constraint:OnDelete:SET NULL"`
+}
+
+func TestGenerateSQL_ForeignKeyActionsFromTag(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testAuthor{}, &testAuthoredPost{})
+	require.NoError(t, err)
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `REFERENCES "test_authors"("id") ON DELETE SET NULL ON UPDATE CASCADE`)
+
+	// SET NULL cannot apply to a column that must not be null
+	schemaDiff, err = comparer.Compare(&testAuthor{}, &testRequiredPost{})
+	require.NoError(t, err)
+	require.ErrorContains(t, g.validateSchemaDiff(schemaDiff), "cannot use SET NULL on a NOT NULL column")
+}
+
+// testDraftPost declares no foreign key actions
+type testDraftPost struct {
+	ID           uint `gorm:"primaryKey"`
+	TestAuthorID *uint
+	TestAuthor   testAuthor
+}
+
+// testRequiredDraft declares no foreign key actions on a NOT NULL column
+type testRequiredDraft struct {
+	ID           uint `gorm:"primaryKey"`
+	TestAuthorID uint `gorm:"not null"`
+	TestAuthor   testAuthor
+}
+
+func TestGenerateSQL_DefaultOnDelete(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testAuthor{}, &testDraftPost{}, &testAuthoredPost{})
+	require.NoError(t, err)
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `REFERENCES "test_authors"("id") ON DELETE CASCADE`)
+
+	g.SetDefaultOnDelete("no action")
+	upSQL, _, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `FOREIGN KEY ("test_author_id") REFERENCES "test_authors"("id") ON DELETE NO ACTION`)
+	require.NotContains(t, upSQL, "ON DELETE CASCADE")
+	// Declared actions still win over the default
+	require.Contains(t, upSQL, `REFERENCES "test_authors"("id") ON DELETE SET NULL ON UPDATE CASCADE`)
+
+	g.SetDefaultOnDelete("DELETE")
+	require.ErrorContains(t, g.validateSchemaDiff(schemaDiff), "unsupported default ON DELETE action DELETE")
+
+	// A default of SET NULL cannot apply to a column that must not be null
+	schemaDiff, err = comparer.Compare(&testAuthor{}, &testRequiredDraft{})
+	require.NoError(t, err)
+	g.SetDefaultOnDelete("SET NULL")
+	require.ErrorContains(t, g.validateSchemaDiff(schemaDiff), "cannot use SET NULL on a NOT NULL column")
+	g.SetDefaultOnDelete("")
+	require.NoError(t, g.validateSchemaDiff(schemaDiff))
+}
+
+type testEditor struct {
+	ID uint `gorm:"primaryKey"`
+}
+
+// testReviewedPost declares its foreign key actions through a method rather
+// than tags, overriding the tag on TestEditor
+type testReviewedPost struct {
+	ID           uint `gorm:"primaryKey"`
+	TestAuthorID *uint
+	TestAuthor   testAuthor
+	TestEditorID *uint
+	TestEditor   testEditor `gorm:"constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+}
+
+func (testReviewedPost) ForeignKeyActions() map[string]diff.FKAction {
+	return map[string]diff.FKAction{
+		"TestAuthor": {OnDelete: "SET NULL", OnUpdate: "CASCADE"},
+		"TestEditor": {OnDelete: "RESTRICT"},
+	}
+}
+
+func TestGenerateSQL_ForeignKeyActionsFromMethod(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testAuthor{}, &testEditor{}, &testReviewedPost{})
+	require.NoError(t, err)
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `FOREIGN KEY ("test_author_id") REFERENCES "test_authors"("id") ON DELETE SET NULL ON UPDATE CASCADE`)
+	require.Contains(t, upSQL, `FOREIGN KEY ("test_editor_id") REFERENCES "test_editors"("id") ON DELETE RESTRICT ON UPDATE CASCADE`)
+}
+
+// testMembership joins members and teams, which reach it only through their
+// has-many associations
+type testMembership struct {
+	ID           uint `gorm:"primaryKey"`
+	TestMemberID uint
+	TestTeamID   uint
+}
+
+type testMember struct {
+	ID          uint `gorm:"primaryKey"`
+	Memberships []testMembership
+}
+
+type testTeam struct {
+	ID          uint `gorm:"primaryKey"`
+	Memberships []testMembership
+}
+
+func TestTopoSortTables_HasManyJoinModel(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testMembership{}, &testMember{}, &testTeam{})
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToCreate, 3)
+
+	// Whatever order the tables come in, the join table is created last
+	tables := schemaDiff.TablesToCreate
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Schema.Table > tables[j].Schema.Table })
+	require.Equal(t, "test_memberships", tables[1].Schema.Table)
+	sorted, err := topoSortTables(tables)
+	require.NoError(t, err)
+	require.Len(t, sorted, 3)
+	require.Equal(t, "test_memberships", sorted[2].Schema.Table)
+}
+
+func TestGenerateSQL_UnquotedIdentifiers(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "orders"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "user_id", DataType: "int"},
+			{DBName: "ShipTo", DataType: "string"},
+		},
+	}
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}})
+
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `ALTER TABLE "orders" ADD COLUMN "user_id" integer;`)
+
+	g.SetQuoteIdentifiers(false)
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, `ALTER TABLE orders ADD COLUMN user_id integer;`)
+	require.Contains(t, upSQL, `ALTER TABLE orders ADD COLUMN "ShipTo" varchar(255);`, "mixed-case names stay quoted")
+	require.Contains(t, downSQL, `ALTER TABLE orders DROP COLUMN user_id;`)
+
+	// Reserved words stay quoted, and literals and comments are left alone
+	require.Equal(t, `ALTER TABLE "user" ALTER COLUMN meta SET DEFAULT '{"note": "x"}'; -- "note"`,
+		unquoteIdentifiers(`ALTER TABLE "user" ALTER COLUMN "meta" SET DEFAULT '{"note": "x"}'; -- "note"`, '"', g.mustQuote))
+}
+
+func TestGenerateSQL_ReservedWordColumn(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "line_items"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: true},
+			{DBName: "order", DataType: "int"},
+			{DBName: "position", DataType: "int"},
+		},
+	}
+
+	for _, quote := range []bool{true, false} {
+		g := NewGenerator(t.TempDir())
+		g.SetQuoteIdentifiers(quote)
+		g.SetSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{table}})
+		upSQL, _, err := g.GenerateSQL()
+		require.NoError(t, err)
+		require.Contains(t, upSQL, `"order" integer`)
+		require.Contains(t, upSQL, "    position integer")
+	}
+
+	// Reserved words differ between dialects
+	g := NewGenerator(t.TempDir())
+	require.True(t, g.mustQuote("user"))
+	require.False(t, g.mustQuote("interval"))
+	g.SetDialect(DialectMySQL)
+	require.False(t, g.mustQuote("user"))
+	require.True(t, g.mustQuote("interval"))
+	require.Equal(t, "`interval`", g.columnName("interval"))
+	g.SetDialect(DialectSQLite)
+	require.False(t, g.mustQuote("user"))
+	require.True(t, g.mustQuote("transaction"))
+	g.SetDialect("oracle")
+	require.True(t, g.mustQuote("user"), "unknown dialects follow Postgres")
+}
+
+func TestGenerateSQL_IndexOnlyChanges(t *testing.T) {
+	email := &schema.Field{DBName: "email"}
+	phone := &schema.Field{DBName: "phone"}
+	uniquePhone := &schema.Index{Name: "idx_contacts_phone", Class: "UNIQUE", Fields: []schema.IndexOption{{Field: phone}}}
+	plainPhone := &schema.Index{Name: "idx_contacts_phone", Fields: []schema.IndexOption{{Field: phone}}}
+	emailIdx := &schema.Index{Name: "idx_contacts_email", Fields: []schema.IndexOption{{Field: email}}}
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+		Schema:          &schema.Schema{Table: "contacts", Fields: []*schema.Field{email, phone}},
+		IndexesToDrop:   []*schema.Index{emailIdx},
+		IndexesToModify: []*schema.Index{uniquePhone},
+		PreviousIndexes: map[string]*schema.Index{"idx_contacts_phone": plainPhone},
+	}}})
+
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Equal(t, "DROP INDEX IF EXISTS \"idx_contacts_email\";\n"+
+		"DROP INDEX IF EXISTS \"idx_contacts_phone\";\n"+
+		`CREATE UNIQUE INDEX "idx_contacts_phone" ON "contacts" ("phone");`, upSQL)
+	require.Equal(t, "DROP INDEX IF EXISTS \"idx_contacts_phone\";\n"+
+		`CREATE INDEX "idx_contacts_email" ON "contacts" ("email");`+"\n"+
+		`CREATE INDEX "idx_contacts_phone" ON "contacts" ("phone");`, downSQL)
+
+	g.SetDialect(DialectMySQL)
+	upSQL, _, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "DROP INDEX `idx_contacts_email` ON `contacts`;")
+}
+
+func TestGenerateSQL_RenamedColumn(t *testing.T) {
+	previous := &schema.Field{DBName: "full_name", DataType: "string"}
+	renamed := &schema.Field{DBName: "full_name", DataType: "string", NotNull: true}
+	table := diff.TableDiff{
+		Schema:         &schema.Schema{Table: "people"},
+		FieldsToRename: []diff.ColumnRename{{OldName: "name", NewName: "full_name"}},
+		FieldsToModify: []*schema.Field{renamed},
+		PreviousFields: map[string]*schema.Field{"full_name": previous},
+	}
+
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+
+	// The column is renamed before it is altered by its new name, and
+	// altered back before it gets its old name again
+	require.Equal(t, []string{
+		`ALTER TABLE "people" RENAME COLUMN "name" TO "full_name";`,
+		`ALTER TABLE "people" ALTER COLUMN "full_name" SET NOT NULL;`,
+	}, strings.Split(upSQL, "\n"))
+	require.Equal(t, []string{
+		`ALTER TABLE "people" ALTER COLUMN "full_name" DROP NOT NULL;`,
+		`ALTER TABLE "people" RENAME COLUMN "full_name" TO "name";`,
+	}, strings.Split(downSQL, "\n"))
+}
+
+func TestGenerateSQL_SchemaQualifiedForeignKey(t *testing.T) {
+	fk := &schema.Relationship{
+		Field:  &schema.Field{DBName: "track_id"},
+		Schema: &schema.Schema{Table: "auxstream.tracks"},
+	}
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "auxstream.plays"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: true},
+			{DBName: "track_id", DataType: "int"},
+		},
+		IndexesToAdd: []*schema.Index{
+			{Name: "idx_plays_track_id", Fields: []schema.IndexOption{{Field: &schema.Field{DBName: "track_id"}}}},
+		},
+		ForeignKeysToAdd: []*schema.Relationship{fk},
+	}
+
+	g := &Generator{}
+	createSQL := g.generateCreateTableSQL(table)
+	require.Contains(t, createSQL, `CREATE TABLE "auxstream"."plays" (`)
+	require.Contains(t, createSQL, `CONSTRAINT "fk_auxstream.plays_track_id_fkey" FOREIGN KEY ("track_id") REFERENCES "auxstream"."tracks"("id")`)
+	require.Contains(t, createSQL, `CREATE INDEX "idx_plays_track_id" ON "auxstream"."plays" ("track_id");`)
+
+	modified := diff.TableDiff{Schema: table.Schema, ForeignKeysToAdd: table.ForeignKeysToAdd, IndexesToAdd: table.IndexesToAdd}
+	modifySQL := strings.Join(g.generateModifyTableSQL(modified), "\n")
+	require.Contains(t, modifySQL, `ALTER TABLE "auxstream"."plays" ADD CONSTRAINT "fk_auxstream.plays_track_id_fkey" FOREIGN KEY ("track_id") REFERENCES "auxstream"."tracks"("id")`)
+	require.Contains(t, modifySQL, `CREATE INDEX "idx_plays_track_id" ON "auxstream"."plays" ("track_id");`)
+
+	require.Equal(t, `"plays"`, g.quoteQualifiedIdentifier("plays"))
+}
+
 func TestGenerateCreateTableSQL_Indexes(t *testing.T) {
 	gen := NewGenerator("migrations")
 	table := diff.TableDiff{
@@ -58,8 +490,8 @@ func TestGenerateCreateTableSQL_Indexes(t *testing.T) {
 	}
 
 	sql := gen.generateCreateTableSQL(table)
-	require.Contains(t, sql, "CONSTRAINT products_sku_unique UNIQUE (\"sku\")")
-	require.Contains(t, sql, "CREATE INDEX products_category_id_idx ON \"products\" (\"category_id\");")
+	require.Contains(t, sql, "CONSTRAINT \"products_sku_unique\" UNIQUE (\"sku\")")
+	require.Contains(t, sql, "CREATE INDEX \"products_category_id_idx\" ON \"products\" (\"category_id\");")
 }
 
 func TestGenerateCreateTableSQL_Formatting(t *testing.T) {
@@ -146,7 +578,7 @@ func TestGenerateMigration_FullProcess(t *testing.T) {
 	require.Contains(t, sql, "CREATE TABLE \"orders\" (")
 	require.Contains(t, sql, "CONSTRAINT fk_orders_user_id_fkey")
 	require.Contains(t, sql, "FOREIGN KEY (\"user_id\")")
-	require.Contains(t, sql, "REFERENCES \"users\"(id)")
+	require.Contains(t, sql, "REFERENCES \"users\"(\"id\")")
 	require.Contains(t, sql, "ON DELETE CASCADE")
 }
 
@@ -242,6 +674,43 @@ func TestGenerateMigration_MissingForeignKeyReference(t *testing.T) {
 	require.Contains(t, err.Error(), "table nonexistent_table not found")
 }
 
+func TestGenerateMigration_MissingForeignKeyReferencedColumn(t *testing.T) {
+	t.Cleanup(func() { cleanupTestMigrations(t, "missing_fk_column_migration") })
+	gen := NewGenerator("migrations")
+	users := &schema.Schema{Table: "users"}
+	schemaDiff := &diff.SchemaDiff{
+		TablesToCreate: []diff.TableDiff{
+			{
+				Schema: users,
+				FieldsToAdd: []*schema.Field{
+					{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: false},
+				},
+			},
+			{
+				Schema: &schema.Schema{Table: "orders"},
+				FieldsToAdd: []*schema.Field{
+					{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: false},
+					{DBName: "user_uuid", DataType: "string", NotNull: false},
+				},
+				ForeignKeysToAdd: []*schema.Relationship{
+					{
+						Name:   "orders_user_uuid_fkey",
+						Field:  &schema.Field{DBName: "user_uuid"},
+						Schema: users,
+						References: []*schema.Reference{
+							{ForeignKey: &schema.Field{DBName: "user_uuid"}, PrimaryKey: &schema.Field{DBName: "uuid"}}, // Column doesn't exist in users
+						},
+					},
+				},
+			},
+		},
+	}
+	gen.SetSchemaDiff(schemaDiff)
+	err := gen.CreateMigration("missing_fk_column_migration")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "references non-existent column uuid in table users")
+}
+
 func TestGenerateMigration_DuplicateColumnNames(t *testing.T) {
 	t.Cleanup(func() { cleanupTestMigrations(t, "duplicate_columns_migration") })
 	gen := NewGenerator("migrations")
@@ -359,10 +828,10 @@ func TestGenerateMigration_ComplexRelationships(t *testing.T) {
 	require.Contains(t, sql, "CREATE TABLE \"order_items\" (")
 	require.Contains(t, sql, "CONSTRAINT fk_orders_user_id_fkey")
 	require.Contains(t, sql, "FOREIGN KEY (\"user_id\")")
-	require.Contains(t, sql, "REFERENCES \"users\"(id)")
+	require.Contains(t, sql, "REFERENCES \"users\"(\"id\")")
 	require.Contains(t, sql, "CONSTRAINT fk_order_items_order_id_fkey")
 	require.Contains(t, sql, "FOREIGN KEY (\"order_id\")")
-	require.Contains(t, sql, "REFERENCES \"orders\"(id)")
+	require.Contains(t, sql, "REFERENCES \"orders\"(\"id\")")
 	require.Contains(t, sql, "ON DELETE CASCADE")
 }
 
@@ -442,7 +911,7 @@ func TestDownMigrationGeneration(t *testing.T) {
 		}
 	})
 
-	t.Run("Modify field generates comment in Down", func(t *testing.T) {
+	t.Run("Modify field restores the previous type in Down", func(t *testing.T) {
 		currentSchema := createTestSchema("users", []*schema.Field{
 			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
 			{Name: "name", DBName: "name", DataType: "string"},
@@ -468,8 +937,1317 @@ func TestDownMigrationGeneration(t *testing.T) {
 		if !strings.Contains(fullUpSQL, "ALTER COLUMN \"age\"") {
 			t.Errorf("Up migration should alter column age")
 		}
-		if !strings.Contains(downSQL, "-- TODO: Reverse modification for column age") {
-			t.Errorf("Down migration should include a comment for manual intervention")
+		if !strings.Contains(downSQL, `ALTER TABLE "users" ALTER COLUMN "age" TYPE integer USING ("age"::integer);`) {
+			t.Errorf("Down migration should restore the integer type of column age")
 		}
 	})
+
+	t.Run("Nullability-only change emits SET NOT NULL without TYPE", func(t *testing.T) {
+		currentSchema := createTestSchema("users", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+			{Name: "name", DBName: "name", DataType: "string"},
+		})
+		targetSchema := createTestSchema("users", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+			{Name: "name", DBName: "name", DataType: "string", NotNull: true},
+		})
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		diffResult := comparer.CompareTable(currentSchema, targetSchema)
+		g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{diffResult}}}
+		upSQL := g.generateModifyTableSQL(diffResult)
+		fullUpSQL := strings.Join(upSQL, " ")
+		downSQL := g.generateDownSQL()
+
+		require.Contains(t, fullUpSQL, "ALTER TABLE \"users\" ALTER COLUMN \"name\" SET NOT NULL;")
+		require.NotContains(t, fullUpSQL, "TYPE")
+		require.NotContains(t, fullUpSQL, "varchar")
+		require.Contains(t, downSQL, "ALTER TABLE \"users\" ALTER COLUMN \"name\" DROP NOT NULL;")
+	})
+}
+
+func TestGenerateCreateTableSQL_UniqueIndexWithCheck(t *testing.T) {
+	gen := NewGenerator("migrations")
+	age := &schema.Field{DBName: "age", DataType: "int", NotNull: true}
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "members"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "int", PrimaryKey: true, NotNull: true},
+			age,
+		},
+		IndexesToAdd: []*schema.Index{
+			{
+				Name:   "idx_members_age",
+				Class:  "UNIQUE",
+				Fields: []schema.IndexOption{{Field: age}},
+			},
+		},
+		ChecksToAdd: []*schema.CheckConstraint{
+			{Name: "chk_members_age", Constraint: "age > 0", Field: age},
+		},
+	}
+
+	sql := gen.generateCreateTableSQL(table)
+	require.Contains(t, sql, "CONSTRAINT \"idx_members_age\" UNIQUE (\"age\")")
+	require.Contains(t, sql, "CONSTRAINT \"chk_members_age\" CHECK (age > 0)")
+	require.NotContains(t, sql, "CREATE INDEX \"idx_members_age\"")
+}
+
+func TestGenerateModifyTableSQL_Checks(t *testing.T) {
+	gen := NewGenerator("migrations")
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "members"},
+		ChecksToAdd: []*schema.CheckConstraint{
+			{Name: "chk_members_age", Constraint: "age > 0"},
+		},
+		ChecksToDrop: []*schema.CheckConstraint{
+			{Name: "chk_members_score", Constraint: "score >= 0"},
+		},
+	}
+
+	statements := gen.generateModifyTableSQL(table)
+	joined := strings.Join(statements, "\n")
+	require.Contains(t, joined, "ALTER TABLE \"members\" ADD CONSTRAINT \"chk_members_age\" CHECK (age > 0);")
+	require.Contains(t, joined, "ALTER TABLE \"members\" DROP CONSTRAINT IF EXISTS \"chk_members_score\";")
+}
+
+func TestGenerateModifyTableSQL_NotNullBackfill(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:         &schema.Schema{Table: "accounts"},
+		FieldsToModify: []*schema.Field{{DBName: "status", DataType: "string", NotNull: true, DefaultValue: "active"}},
+		PreviousFields: map[string]*schema.Field{
+			"status": {DBName: "status", DataType: "string", DefaultValue: "active"},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{
+		"UPDATE \"accounts\" SET \"status\" = 'active' WHERE \"status\" IS NULL;",
+		"ALTER TABLE \"accounts\" ALTER COLUMN \"status\" SET NOT NULL;",
+	}, g.generateModifyTableSQL(table))
+	require.Contains(t, g.generateDownSQL(), "ALTER TABLE \"accounts\" ALTER COLUMN \"status\" DROP NOT NULL;")
+	require.NotContains(t, g.generateDownSQL(), "UPDATE")
+}
+
+func TestGenerateSQL_Cascade(t *testing.T) {
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{
+		TablesToCreate: []diff.TableDiff{{
+			Schema:      &schema.Schema{Table: "tokens"},
+			FieldsToAdd: []*schema.Field{{DBName: "id", DataType: "uint", PrimaryKey: true}},
+		}},
+		TablesToDrop: []string{"legacy_parents"},
+	})
+
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "DROP TABLE IF EXISTS \"legacy_parents\";")
+	require.NotContains(t, upSQL+downSQL, "CASCADE")
+
+	g.SetCascade(true)
+	upSQL, downSQL, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "DROP TABLE IF EXISTS \"legacy_parents\" CASCADE;")
+	require.Contains(t, downSQL, "DROP TABLE IF EXISTS \"tokens\" CASCADE;")
+}
+
+func TestGenerateSQL_Preamble(t *testing.T) {
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{{
+		Schema:      &schema.Schema{Table: "tokens"},
+		FieldsToAdd: []*schema.Field{{DBName: "id", DataType: "uint", PrimaryKey: true}},
+	}}})
+	g.AddPreamble(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
+	g.AddPostamble("ANALYZE tokens;")
+
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+	extension := strings.Index(upSQL, `CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
+	createTable := strings.Index(upSQL, "CREATE TABLE")
+	require.NotEqual(t, -1, extension)
+	require.Less(t, extension, createTable)
+	require.True(t, strings.HasSuffix(upSQL, "ANALYZE tokens;"))
+	require.NotContains(t, downSQL, "EXTENSION")
+}
+
+func TestGenerateModifyTableSQL_ChangedCheck(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:       &schema.Schema{Table: "members"},
+		ChecksToDrop: []*schema.CheckConstraint{{Name: "chk_members_age", Constraint: "age > 0"}},
+		ChecksToAdd:  []*schema.CheckConstraint{{Name: "chk_members_age", Constraint: "age >= 0"}},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{
+		"ALTER TABLE \"members\" DROP CONSTRAINT IF EXISTS \"chk_members_age\";",
+		"ALTER TABLE \"members\" ADD CONSTRAINT \"chk_members_age\" CHECK (age >= 0);",
+	}, g.generateModifyTableSQL(table))
+
+	downSQL := g.generateDownSQL()
+	drop := strings.Index(downSQL, "ALTER TABLE \"members\" DROP CONSTRAINT IF EXISTS \"chk_members_age\";")
+	add := strings.Index(downSQL, "ALTER TABLE \"members\" ADD CONSTRAINT \"chk_members_age\" CHECK (age > 0);")
+	require.NotEqual(t, -1, drop)
+	require.Greater(t, add, drop, "Down should restore the old expression after dropping the new one")
+}
+
+func TestGenerateDownSQL_ModifiedColumnType(t *testing.T) {
+	// The current definition as introspected from the database
+	currentSchema := createTestSchema("scores", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "points", DBName: "points", DataType: "VARCHAR", Size: 20, NotNull: true, DefaultValue: "'0'::character varying"},
+	})
+	targetSchema := createTestSchema("scores", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "points", DBName: "points", DataType: "int"},
+	})
+
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+	require.Len(t, tableDiff.FieldsToModify, 1)
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+
+	require.Equal(t, []string{
+		`ALTER TABLE "scores" ALTER COLUMN "points" DROP DEFAULT;`,
+		`ALTER TABLE "scores" ALTER COLUMN "points" TYPE integer USING ("points"::integer);`,
+		`ALTER TABLE "scores" ALTER COLUMN "points" DROP NOT NULL;`,
+	}, g.generateModifyTableSQL(tableDiff))
+
+	// Down restores the type, nullability and default the column had,
+	// filling in rows left NULL meanwhile
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, `ALTER TABLE "scores" ALTER COLUMN "points" TYPE varchar(20);
+UPDATE "scores" SET "points" = '0'::character varying WHERE "points" IS NULL;
+ALTER TABLE "scores" ALTER COLUMN "points" SET NOT NULL;
+ALTER TABLE "scores" ALTER COLUMN "points" SET DEFAULT '0'::character varying;`)
+	require.NotContains(t, downSQL, "TODO")
+}
+
+func TestGenerateModifyTableSQL_ColumnType(t *testing.T) {
+	// quantity integer NULL as introspected, to bigint NOT NULL DEFAULT 1
+	previous := &schema.Field{Name: "Quantity", DBName: "quantity", DataType: "int4"}
+	quantity := &schema.Field{Name: "Quantity", DBName: "quantity", DataType: "bigint", NotNull: true, DefaultValue: "1"}
+
+	g := &Generator{}
+	require.Equal(t, []string{
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" TYPE bigint;`,
+		`UPDATE "line_items" SET "quantity" = 1 WHERE "quantity" IS NULL;`,
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" SET NOT NULL;`,
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" SET DEFAULT 1;`,
+	}, g.alterColumnType("line_items", previous, quantity))
+	require.Equal(t, []string{
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" DROP DEFAULT;`,
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" TYPE int4;`,
+		`ALTER TABLE "line_items" ALTER COLUMN "quantity" DROP NOT NULL;`,
+	}, g.alterColumnType("line_items", quantity, previous))
+
+	// A string converts to a number only with an explicit cast
+	code := &schema.Field{Name: "Code", DBName: "code", DataType: "int"}
+	require.Equal(t, []string{`ALTER TABLE "line_items" ALTER COLUMN "code" TYPE integer USING ("code"::integer);`},
+		g.alterColumnType("line_items", &schema.Field{DBName: "code", DataType: "text"}, code))
+
+	// MySQL restates the column with MODIFY COLUMN
+	g.Dialect = DialectMySQL
+	require.Equal(t, []string{"ALTER TABLE `line_items` MODIFY COLUMN `quantity` bigint NOT NULL DEFAULT 1;"},
+		g.alterColumnType("line_items", previous, quantity))
+}
+
+// testCoupon stores its code as a number, converting blank codes to NULL
+type testCoupon struct {
+	ID   uint `gorm:"primaryKey"`
+	Code int  `gorm:"using:NULLIF(code, '')::integer"`
+}
+
+func TestGenerateModifyTableSQL_UsingCast(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	targetSchemas, err := comparer.GetModelSchemas(&testCoupon{})
+	require.NoError(t, err)
+	require.Len(t, targetSchemas, 1)
+	var target *schema.Schema
+	for _, s := range targetSchemas {
+		target = s
+	}
+	current := createTestSchema("test_coupons", []*schema.Field{
+		{Name: "ID", DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true},
+		{Name: "Code", DBName: "code", DataType: "text"},
+	})
+
+	tableDiff := comparer.CompareTable(current, target)
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+	require.Equal(t, []string{
+		`ALTER TABLE "test_coupons" ALTER COLUMN "code" TYPE integer USING (NULLIF(code, '')::integer);`,
+	}, g.generateModifyTableSQL(tableDiff))
+
+	// Without a tag the column is cast as is, and back to text in Down
+	code := *tableDiff.FieldsToModify[0]
+	code.TagSettings = nil
+	require.Equal(t, []string{`ALTER TABLE "test_coupons" ALTER COLUMN "code" TYPE integer USING ("code"::integer);`},
+		g.alterColumnType("test_coupons", current.Fields[1], &code))
+	require.Contains(t, g.generateDownSQL(), `ALTER TABLE "test_coupons" ALTER COLUMN "code" TYPE text;`)
+}
+
+func TestGenerateModifyTableSQL_SequenceDefault(t *testing.T) {
+	currentSchema := createTestSchema("orders", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "int8", PrimaryKey: true, DefaultValue: "nextval('orders_id_seq'::regclass)"},
+		{Name: "number", DBName: "number", DataType: "int8", DefaultValue: "0"},
+	})
+	targetSchema := createTestSchema("orders", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "number", DBName: "number", DataType: "int", DefaultValue: "nextval('order_number_seq')"},
+	})
+
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+	require.Equal(t, []string{
+		`CREATE SEQUENCE IF NOT EXISTS "order_number_seq";`,
+		`ALTER TABLE "orders" ALTER COLUMN "number" SET DEFAULT nextval('order_number_seq');`,
+	}, g.generateModifyTableSQL(tableDiff))
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, `ALTER TABLE "orders" ALTER COLUMN "number" SET DEFAULT 0;`)
+	require.Contains(t, downSQL, `DROP SEQUENCE IF EXISTS "order_number_seq";`)
+	require.Less(t, strings.Index(downSQL, "SET DEFAULT 0"), strings.Index(downSQL, "DROP SEQUENCE"))
+}
+
+// testShipment numbers its rows from a sequence shared with other tables
+type testShipment struct {
+	ID     uint  `gorm:"primaryKey"`
+	Number int64 `gorm:"default:nextval('shared_number_seq')"`
 }
+
+func TestGenerateSQL_SharedSequenceDefault(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testShipment{})
+	require.NoError(t, err)
+	// Another table starts drawing from the same sequence
+	schemaDiff.TablesToModify = []diff.TableDiff{comparer.CompareTable(
+		createTestSchema("returns", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true},
+			{Name: "number", DBName: "number", DataType: "int8"},
+		}),
+		createTestSchema("returns", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+			{Name: "number", DBName: "number", DataType: "int", DefaultValue: "nextval('shared_number_seq')"},
+			{Name: "batch", DBName: "batch", DataType: "int", DefaultValue: "nextval('shared_number_seq')"},
+		}),
+	)}
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(schemaDiff)
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+
+	// The sequence is created before any default uses it, and each default is
+	// written as declared
+	require.True(t, strings.HasPrefix(upSQL, `CREATE SEQUENCE IF NOT EXISTS "shared_number_seq";`), upSQL)
+	require.Contains(t, upSQL, "number integer DEFAULT nextval('shared_number_seq')")
+	require.Contains(t, upSQL, `ALTER TABLE "returns" ADD COLUMN "batch" integer DEFAULT nextval('shared_number_seq');`)
+	require.Contains(t, upSQL, `ALTER TABLE "returns" ALTER COLUMN "number" SET DEFAULT nextval('shared_number_seq');`)
+
+	// Down drops the sequence once, after the columns that draw from it
+	require.Contains(t, downSQL, `ALTER TABLE "returns" ALTER COLUMN "number" DROP DEFAULT;`)
+	require.Equal(t, 1, strings.Count(downSQL, `DROP SEQUENCE IF EXISTS "shared_number_seq";`), downSQL)
+	require.Less(t, strings.Index(downSQL, `DROP TABLE IF EXISTS "test_shipments"`), strings.Index(downSQL, "DROP SEQUENCE"))
+	require.Less(t, strings.Index(downSQL, `DROP COLUMN "batch"`), strings.Index(downSQL, "DROP SEQUENCE"))
+}
+
+func TestGenerateModifyTableSQL_MidStructColumn(t *testing.T) {
+	currentSchema := createTestSchema("contacts", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "name", DBName: "name", DataType: "string"},
+		{Name: "email", DBName: "email", DataType: "string"},
+	})
+	targetSchema := createTestSchema("contacts", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "name", DBName: "name", DataType: "string"},
+		{Name: "phone", DBName: "phone", DataType: "string"},
+		{Name: "email", DBName: "email", DataType: "string"},
+	})
+
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+	require.Equal(t, []string{`ALTER TABLE "contacts" ADD COLUMN "phone" varchar(255);`}, g.generateModifyTableSQL(tableDiff))
+
+	// A new table's columns follow the model's order
+	created := comparer.CompareTable(createTestSchema("contacts", nil), targetSchema)
+	createSQL := g.generateCreateTableSQL(created)
+	name, phone, email := strings.Index(createSQL, "name "), strings.Index(createSQL, "phone "), strings.Index(createSQL, "email ")
+	require.True(t, name < phone && phone < email, createSQL)
+}
+
+type testBooking struct {
+	ID     uint   `gorm:"primaryKey"`
+	RoomID uint   `gorm:"uniqueConstraint:uq_bookings_room_night"`
+	Night  string `gorm:"uniqueConstraint:uq_bookings_room_night"`
+	Ref    string `gorm:"uniqueConstraint"`
+}
+
+func TestGenerateSQL_UniqueConstraint(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemaDiff, err := comparer.Compare(&testBooking{})
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToCreate, 1)
+	require.Equal(t, []diff.UniqueConstraint{
+		{Name: "uq_bookings_room_night", Columns: []string{"room_id", "night"}},
+		{Name: "uq_test_bookings_ref", Columns: []string{"ref"}},
+	}, schemaDiff.TablesToCreate[0].UniquesToAdd)
+
+	createSQL := (&Generator{}).generateCreateTableSQL(schemaDiff.TablesToCreate[0])
+	require.Contains(t, createSQL, `CONSTRAINT "uq_bookings_room_night" UNIQUE ("room_id", "night")`)
+	require.Contains(t, createSQL, `CONSTRAINT "uq_test_bookings_ref" UNIQUE ("ref")`)
+	require.NotContains(t, createSQL, "CREATE UNIQUE INDEX")
+
+	table := diff.TableDiff{
+		Schema:        &schema.Schema{Table: "bookings"},
+		UniquesToDrop: []diff.UniqueConstraint{{Name: "uq_bookings_ref", Columns: []string{"ref"}}},
+		UniquesToAdd:  []diff.UniqueConstraint{{Name: "uq_bookings_room_night", Columns: []string{"room_id", "night"}}},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+	require.Equal(t, []string{
+		`ALTER TABLE "bookings" DROP CONSTRAINT IF EXISTS "uq_bookings_ref";`,
+		`ALTER TABLE "bookings" ADD CONSTRAINT "uq_bookings_room_night" UNIQUE ("room_id", "night");`,
+	}, g.generateModifyTableSQL(table))
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, `ALTER TABLE "bookings" DROP CONSTRAINT IF EXISTS "uq_bookings_room_night";`)
+	require.Contains(t, downSQL, `ALTER TABLE "bookings" ADD CONSTRAINT "uq_bookings_ref" UNIQUE ("ref");`)
+}
+
+func TestGenerateSQL_CockroachDialect(t *testing.T) {
+	g := NewGenerator(t.TempDir())
+	g.SetDialect(DialectCockroach)
+
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "plays"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: schema.Uint, PrimaryKey: true, AutoIncrement: true, TagSettings: map[string]string{"AUTOINCREMENTSTART": "1000"}},
+			{DBName: "count", DataType: schema.Int},
+			{DBName: "track_id", DataType: schema.Uint},
+			{DBName: "title", DataType: schema.String},
+		},
+	}
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{table}})
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "id SERIAL8 PRIMARY KEY")
+	require.Contains(t, upSQL, "count INT8")
+	require.Contains(t, upSQL, "track_id INT8")
+	require.Contains(t, upSQL, "title varchar(255)")
+	require.NotContains(t, upSQL, "ALTER SEQUENCE", "SERIAL8 keys have no sequence")
+
+	identity := &schema.Field{DBName: "id", DataType: schema.Int, PrimaryKey: true, TagSettings: map[string]string{"IDENTITY": "IDENTITY"}}
+	require.Equal(t, "INT8 GENERATED BY DEFAULT AS IDENTITY", g.columnSQLType(identity))
+
+	// Indexes on existing tables are built outside a transaction
+	require.False(t, g.nonTransactional())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+		Schema:       &schema.Schema{Table: "plays"},
+		IndexesToAdd: []*schema.Index{{Name: "idx_plays_track_id", Fields: []schema.IndexOption{{Field: &schema.Field{DBName: "track_id"}}}}},
+	}}})
+	require.True(t, g.nonTransactional())
+	g.SetDialect(DialectPostgres)
+	require.False(t, g.nonTransactional())
+}
+
+type testTicket struct {
+	ID   uint `gorm:"primaryKey;autoIncrementIncrement:5;autoIncrementStart:1000"`
+	Code string
+}
+
+type testIdentityTicket struct {
+	ID uint `gorm:"primaryKey;identity;autoIncrementStart:1000"`
+}
+
+func TestGenerateSQL_SequenceOptions(t *testing.T) {
+	createDiff := func(model any) *diff.SchemaDiff {
+		stmt := &gorm.Statement{DB: createTestDB(t)}
+		require.NoError(t, stmt.Parse(model))
+		return &diff.SchemaDiff{TablesToCreate: []diff.TableDiff{{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields}}}
+	}
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(createDiff(&testTicket{}))
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "ALTER SEQUENCE \"test_tickets_id_seq\" INCREMENT BY 5 RESTART WITH 1000;")
+	require.Greater(t, strings.Index(upSQL, "ALTER SEQUENCE"), strings.Index(upSQL, "CREATE TABLE"))
+
+	g.SetSchemaDiff(createDiff(&testIdentityTicket{}))
+	upSQL, _, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "ALTER TABLE \"test_identity_tickets\" ALTER COLUMN \"id\" RESTART WITH 1000;")
+
+	g.SetDialect(DialectMySQL)
+	g.SetSchemaDiff(createDiff(&testTicket{}))
+	upSQL, _, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "ALTER TABLE `test_tickets` AUTO_INCREMENT = 1000;")
+
+	// Tables without the tags get no sequence statements
+	g.SetDialect(DialectPostgres)
+	g.SetSchemaDiff(createDiff(&testArticle{}))
+	upSQL, _, err = g.GenerateSQL()
+	require.NoError(t, err)
+	require.NotContains(t, upSQL, "SEQUENCE")
+}
+
+type testArticle struct {
+	ID    uint `gorm:"primaryKey"`
+	Title string
+	Slug  string `gorm:"size:64"`
+	Body  string `gorm:"type:text"`
+	Notes string `gorm:"size:10000"`
+}
+
+func TestWriteDataMigration(t *testing.T) {
+	g := NewGenerator(t.TempDir())
+	require.NoError(t, g.WriteDataMigration("20240101120000", "backfill_status"))
+
+	path := filepath.Join(g.MigrationsDir, "20240101120000_backfill_status.go")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), path, content, 0)
+	require.NoError(t, err, "the scaffold should be valid Go")
+	require.Contains(t, string(content), `Version:   "20240101120000",`)
+	require.Contains(t, string(content), `Name:      "backfill_status",`)
+	require.Contains(t, string(content), "// DATA MIGRATION (Up):")
+	require.Contains(t, string(content), "// DATA MIGRATION (Down):")
+	require.NotContains(t, string(content), "db.Exec")
+
+	// Hand edits are never overwritten
+	require.Error(t, g.WriteDataMigration("20240101120000", "backfill_status"))
+}
+
+func TestGenerateCreateTableSQL_TypeOverrides(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "counters"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "uint", PrimaryKey: true},
+			{DBName: "owner_id", DataType: "uint"},
+			{DBName: "hits", DataType: "int"},
+		},
+	}
+	g := NewGenerator(t.TempDir())
+	g.SetTypeOverrides(map[string]string{"uint": "integer"})
+
+	createSQL := g.generateCreateTableSQL(table)
+	require.Contains(t, createSQL, "id SERIAL PRIMARY KEY")
+	require.Contains(t, createSQL, "owner_id integer")
+	require.Contains(t, createSQL, "hits integer")
+	require.NotContains(t, createSQL, "bigint")
+}
+
+func TestGenerateSQL_ModelComment(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testArticle{}))
+
+	g := NewGenerator(t.TempDir())
+	g.SetSchemaDiff(&diff.SchemaDiff{TablesToCreate: []diff.TableDiff{{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields}}})
+	upSQL, _, err := g.GenerateSQL()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(upSQL, "-- generator.testArticle\nCREATE TABLE \"test_articles\""), upSQL)
+
+	// The comment stays with the statement in the migration file
+	require.NoError(t, g.CreateMigration("create_articles"))
+	files, err := os.ReadDir(g.MigrationsDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(filepath.Join(g.MigrationsDir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "if err := db.Exec(`-- generator.testArticle\n")
+}
+
+func TestGenerateCreateTableSQL_StringSizes(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testArticle{}))
+
+	gen := NewGenerator("migrations")
+	sql := gen.generateCreateTableSQL(diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields})
+
+	require.Contains(t, sql, "title varchar(255)")
+	require.Contains(t, sql, "slug varchar(64)")
+	require.Contains(t, sql, "body text")
+	require.Contains(t, sql, "notes text")
+	require.NotContains(t, sql, "varchar(10000)")
+}
+
+func TestGenerateSQL_StringSizeTag(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{{
+		Schema: &schema.Schema{Table: "people"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "code", DataType: schema.String, Size: 50},
+			{DBName: "name", DataType: schema.String},
+			{DBName: "bio", DataType: "text"},
+		},
+		// nickname as introspected from the database
+		FieldsToDrop: []*schema.Field{{DBName: "nickname", DataType: "varchar", Size: 30}},
+	}}}}
+	upSQL, downSQL, err := g.GenerateSQL()
+	require.NoError(t, err)
+
+	require.Contains(t, upSQL, `ADD COLUMN "code" varchar(50);`)
+	require.Contains(t, upSQL, `ADD COLUMN "name" varchar(255);`)
+	require.Contains(t, upSQL, `ADD COLUMN "bio" text;`)
+	require.Contains(t, downSQL, `ADD COLUMN "nickname" varchar(30);`)
+}
+
+type testPricedItem struct {
+	ID     uint    `gorm:"primaryKey"`
+	Price  float64 `gorm:"type:decimal(12,2)"`
+	Weight float64 `gorm:"precision:8;scale:3"`
+	Rating float64
+}
+
+func TestGenerateCreateTableSQL_DecimalSizes(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testPricedItem{}))
+
+	gen := NewGenerator("migrations")
+	sql := gen.generateCreateTableSQL(diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields})
+
+	require.Contains(t, sql, "price numeric(12,2)")
+	require.Contains(t, sql, "weight numeric(8,3)")
+	require.Contains(t, sql, "rating double precision")
+}
+
+func TestGenerateModifyTableSQL_DropForeignKey(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "products"},
+		ForeignKeysToDrop: []*schema.Relationship{
+			{
+				Name:   "fk_products_category",
+				Field:  &schema.Field{DBName: "category_id"},
+				Schema: &schema.Schema{Table: "categories"},
+				References: []*schema.Reference{
+					{ForeignKey: &schema.Field{DBName: "category_id"}, PrimaryKey: &schema.Field{DBName: "id"}},
+				},
+			},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	upSQL := strings.Join(g.generateModifyTableSQL(table), "\n")
+	require.Contains(t, upSQL, "ALTER TABLE \"products\" DROP CONSTRAINT IF EXISTS fk_products_category;")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"products\" ADD CONSTRAINT fk_products_category FOREIGN KEY (\"category_id\") REFERENCES \"categories\"(\"id\");")
+}
+
+func TestGenerateModifyTableSQL_MySQLDropConstraints(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "products"},
+		ForeignKeysToDrop: []*schema.Relationship{
+			{
+				Name:   "fk_products_category",
+				Field:  &schema.Field{DBName: "category_id"},
+				Schema: &schema.Schema{Table: "categories"},
+				References: []*schema.Reference{
+					{ForeignKey: &schema.Field{DBName: "category_id"}, PrimaryKey: &schema.Field{DBName: "id"}},
+				},
+			},
+		},
+		UniquesToDrop: []diff.UniqueConstraint{{Name: "uq_products_sku", Columns: []string{"sku"}}},
+		ChecksToDrop:  []*schema.CheckConstraint{{Name: "chk_products_price", Constraint: "price > 0"}},
+	}
+	g := &Generator{Dialect: DialectMySQL, SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	// MySQL before 8.0.19 has no DROP CONSTRAINT
+	upSQL := strings.Join(g.generateModifyTableSQL(table), "\n")
+	require.Contains(t, upSQL, "ALTER TABLE `products` DROP FOREIGN KEY fk_products_category;")
+	require.Contains(t, upSQL, "ALTER TABLE `products` DROP INDEX `uq_products_sku`;")
+	require.Contains(t, upSQL, "ALTER TABLE `products` DROP CHECK `chk_products_price`;")
+	require.NotContains(t, upSQL, "DROP CONSTRAINT")
+
+	// Backtick-quoted statements are written as interpreted Go strings
+	content, err := g.renderMigration("20240101120000", "drop_product_constraints")
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "", content, 0)
+	require.NoError(t, err, "the migration should be valid Go")
+	require.Contains(t, content, `db.Exec("ALTER TABLE `+"`products`"+` DROP CHECK `+"`chk_products_price`"+`;")`)
+}
+
+func TestGenerateSQL_PartialIndex(t *testing.T) {
+	idx := &schema.Index{
+		Name:   "idx_users_email",
+		Class:  "UNIQUE",
+		Fields: []schema.IndexOption{{Field: &schema.Field{DBName: "email"}}},
+		Where:  "deleted_at IS NULL",
+	}
+	created := diff.TableDiff{
+		Schema: &schema.Schema{Table: "users"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: "uint", PrimaryKey: true},
+			{DBName: "email", DataType: "string"},
+		},
+		IndexesToAdd: []*schema.Index{idx},
+	}
+	g := &Generator{}
+
+	createSQL := g.generateCreateTableSQL(created)
+	require.Contains(t, createSQL, "CREATE UNIQUE INDEX \"idx_users_email\" ON \"users\" (\"email\") WHERE deleted_at IS NULL;")
+	require.NotContains(t, createSQL, "CONSTRAINT \"idx_users_email\"")
+
+	modifySQL := strings.Join(g.generateModifyTableSQL(diff.TableDiff{
+		Schema:       &schema.Schema{Table: "users"},
+		IndexesToAdd: []*schema.Index{idx},
+	}), "\n")
+	require.Contains(t, modifySQL, "CREATE UNIQUE INDEX \"idx_users_email\" ON \"users\" (\"email\") WHERE deleted_at IS NULL;")
+}
+
+func TestGenerateModifyTableSQL_AddPrimaryKey(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:         &schema.Schema{Table: "legacy_codes"},
+		FieldsToModify: []*schema.Field{{DBName: "code", DataType: "string", PrimaryKey: true}},
+		PreviousFields: map[string]*schema.Field{
+			"code": {DBName: "code", DataType: "varchar"},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	upSQL := strings.Join(g.generateModifyTableSQL(table), "\n")
+	require.Contains(t, upSQL, "ALTER TABLE \"legacy_codes\" ADD PRIMARY KEY (\"code\");")
+	require.NotContains(t, upSQL, "ALTER COLUMN")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"legacy_codes\" DROP CONSTRAINT IF EXISTS legacy_codes_pkey;")
+	require.NotContains(t, downSQL, "TODO")
+}
+
+func TestGenerateModifyTableSQL_IfNotExists(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:       &schema.Schema{Table: "accounts"},
+		FieldsToAdd:  []*schema.Field{{DBName: "nickname", DataType: "string"}},
+		FieldsToDrop: []*schema.Field{{DBName: "legacy_id", DataType: "int"}},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+	g.SetIfNotExists(true)
+
+	upSQL := strings.Join(g.generateModifyTableSQL(table), "\n")
+	require.Contains(t, upSQL, "ALTER TABLE \"accounts\" ADD COLUMN IF NOT EXISTS \"nickname\" varchar(255);")
+	require.Contains(t, upSQL, "ALTER TABLE \"accounts\" DROP COLUMN IF EXISTS \"legacy_id\";")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"accounts\" DROP COLUMN IF EXISTS \"nickname\";")
+	require.Contains(t, downSQL, "ALTER TABLE \"accounts\" ADD COLUMN IF NOT EXISTS \"legacy_id\"")
+}
+
+func TestGenerateModifyTableSQL_IndexRename(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:          &schema.Schema{Table: "accounts"},
+		IndexesToRename: []diff.IndexRename{{OldName: "idx_people_email", NewName: "idx_accounts_email"}},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{"ALTER INDEX \"idx_people_email\" RENAME TO \"idx_accounts_email\";"}, g.generateModifyTableSQL(table))
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER INDEX \"idx_accounts_email\" RENAME TO \"idx_people_email\";")
+	require.NotContains(t, downSQL, "DROP INDEX")
+
+	g.SetDialect(DialectMySQL)
+	require.Equal(t, []string{"ALTER TABLE `accounts` RENAME INDEX `idx_people_email` TO `idx_accounts_email`;"}, g.generateModifyTableSQL(table))
+}
+
+func TestGenerateModifyTableSQL_DecimalPrecision(t *testing.T) {
+	table := diff.TableDiff{
+		Schema:         &schema.Schema{Table: "prices"},
+		FieldsToModify: []*schema.Field{{DBName: "amount", DataType: schema.Float, Precision: 12, Scale: 4}},
+		PreviousFields: map[string]*schema.Field{
+			"amount": {DBName: "amount", DataType: "numeric", Precision: 10, Scale: 2},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{
+		"ALTER TABLE \"prices\" ALTER COLUMN \"amount\" TYPE numeric(12,4);",
+	}, g.generateModifyTableSQL(table))
+	require.Contains(t, g.generateDownSQL(), "ALTER TABLE \"prices\" ALTER COLUMN \"amount\" TYPE numeric(10,2);")
+}
+
+func TestGenerateModifyTableSQL_MovePrimaryKey(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{
+			Table: "currencies",
+			Fields: []*schema.Field{
+				{DBName: "id", DataType: "uint"},
+				{DBName: "code", DataType: "string", PrimaryKey: true},
+			},
+		},
+		FieldsToModify: []*schema.Field{
+			{DBName: "id", DataType: "uint"},
+			{DBName: "code", DataType: "string", PrimaryKey: true},
+		},
+		PreviousFields: map[string]*schema.Field{
+			"id":   {DBName: "id", DataType: "uint", PrimaryKey: true},
+			"code": {DBName: "code", DataType: "string"},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{
+		"ALTER TABLE \"currencies\" DROP CONSTRAINT IF EXISTS currencies_pkey;",
+		"ALTER TABLE \"currencies\" ADD PRIMARY KEY (\"code\");",
+	}, g.generateModifyTableSQL(table))
+
+	downSQL := g.generateDownSQL()
+	drop := strings.Index(downSQL, "ALTER TABLE \"currencies\" DROP CONSTRAINT IF EXISTS currencies_pkey;")
+	restore := strings.Index(downSQL, "ALTER TABLE \"currencies\" ADD PRIMARY KEY (\"id\");")
+	require.NotEqual(t, -1, drop)
+	require.Greater(t, restore, drop)
+	require.NotContains(t, downSQL, "TODO")
+}
+
+func TestGenerateModifyTableSQL_SerialToIdentity(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "orders"},
+		FieldsToModify: []*schema.Field{{
+			DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true,
+			TagSettings: map[string]string{"IDENTITY": "IDENTITY"},
+		}},
+		PreviousFields: map[string]*schema.Field{
+			"id": {DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true},
+		},
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{table}}}
+
+	require.Equal(t, []string{
+		"ALTER TABLE \"orders\" ALTER COLUMN \"id\" DROP DEFAULT;",
+		`DROP SEQUENCE IF EXISTS "orders_id_seq";`,
+		"ALTER TABLE \"orders\" ALTER COLUMN \"id\" TYPE bigint;",
+		"ALTER TABLE \"orders\" ALTER COLUMN \"id\" ADD GENERATED BY DEFAULT AS IDENTITY;",
+		"SELECT setval(pg_get_serial_sequence('\"orders\"', 'id'), COALESCE(MAX(\"id\"), 0) + 1, false) FROM \"orders\";",
+	}, g.generateModifyTableSQL(table))
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"orders\" ALTER COLUMN \"id\" DROP IDENTITY IF EXISTS;")
+	require.Contains(t, downSQL, `CREATE SEQUENCE IF NOT EXISTS "orders_id_seq" OWNED BY "orders"."id";`)
+	require.Contains(t, downSQL, "ALTER TABLE \"orders\" ALTER COLUMN \"id\" SET DEFAULT nextval('orders_id_seq');")
+	require.NotContains(t, downSQL, "TODO")
+}
+
+func TestGenerateSQL_DropTable(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToDrop: []string{"legacy_events"}}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "DROP TABLE IF EXISTS \"legacy_events\";")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "-- TODO: Recreate dropped table legacy_events manually")
+}
+
+func TestGenerateSQL_TableRename(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		TablesToRename: []diff.TableRename{{OldName: "people", NewName: "users"}},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "ALTER TABLE \"people\" RENAME TO \"users\";")
+	require.NotContains(t, upSQL, "CREATE TABLE")
+	require.NotContains(t, upSQL, "DROP TABLE")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"users\" RENAME TO \"people\";")
+}
+
+func TestGenerateSQL_MaterializedView(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		ViewsToCreate: []diff.MaterializedView{{Name: "order_totals", SQL: "SELECT user_id, SUM(amount) AS total FROM orders GROUP BY user_id;"}},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Equal(t, "CREATE MATERIALIZED VIEW \"order_totals\" AS SELECT user_id, SUM(amount) AS total FROM orders GROUP BY user_id;", upSQL)
+	require.NotContains(t, upSQL, "CREATE TABLE")
+
+	require.Equal(t, "DROP MATERIALIZED VIEW IF EXISTS \"order_totals\";", g.generateDownSQL())
+}
+
+func TestGenerateSQL_Enums(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		EnumsToCreate: []diff.Enum{{Name: "mood", Values: []string{"happy", "sad"}}},
+		EnumsToAlter:  []diff.EnumChange{{Name: "status", Added: []string{"archived"}, Removed: []string{"legacy"}}},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "CREATE TYPE \"mood\" AS ENUM ('happy', 'sad');")
+	require.Contains(t, upSQL, "ALTER TYPE \"status\" ADD VALUE IF NOT EXISTS 'archived';")
+	require.Contains(t, upSQL, "-- TODO: Postgres cannot remove value 'legacy' from enum type status")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "DROP TYPE IF EXISTS \"mood\";")
+	require.Contains(t, downSQL, "-- TODO: Postgres cannot remove value 'archived' from enum type status")
+
+	// ADD VALUE cannot run inside a transaction block
+	content, err := g.renderMigration("20240101000000", "enums")
+	require.NoError(t, err)
+	require.Contains(t, content, "NonTransactional: true,")
+}
+
+func TestGenerateSQL_RemovedEnumValues(t *testing.T) {
+	removed := []diff.EnumChange{{Name: "status", Removed: []string{"legacy"}}}
+
+	// Removed values cannot be migrated, so on their own they make no
+	// migration rather than the same empty one on every run
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{EnumsToAlter: removed}}
+	_, err := g.renderMigration("20240101000000", "enums")
+	require.ErrorIs(t, err, ErrNoChanges)
+
+	// Alongside other changes they are flagged for manual work
+	g = &Generator{SchemaDiff: &diff.SchemaDiff{
+		EnumsToCreate: []diff.Enum{{Name: "mood", Values: []string{"happy", "sad"}}},
+		EnumsToAlter:  removed,
+	}}
+	content, err := g.renderMigration("20240101000000", "enums")
+	require.NoError(t, err)
+	require.Contains(t, content, "-- TODO: Postgres cannot remove value 'legacy' from enum type status")
+	require.NotContains(t, content, "NonTransactional: true,")
+}
+
+func TestGenerateSQL_ReferenceData(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		RowsToInsert: []diff.ReferenceData{{Table: "currencies", Rows: []map[string]any{
+			{"code": "USD", "name": "US Dollar", "decimals": 2},
+			{"code": "JPY", "name": "Japanese Yen", "decimals": 0},
+		}}},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Equal(t, "INSERT INTO \"currencies\" (\"code\", \"decimals\", \"name\") VALUES ('USD', 2, 'US Dollar') ON CONFLICT DO NOTHING;\n"+
+		"INSERT INTO \"currencies\" (\"code\", \"decimals\", \"name\") VALUES ('JPY', 0, 'Japanese Yen') ON CONFLICT DO NOTHING;", upSQL)
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "DELETE FROM \"currencies\" WHERE \"code\" = 'USD' AND \"decimals\" = 2 AND \"name\" = 'US Dollar';")
+
+	mysqlGen := &Generator{Dialect: DialectMySQL, SchemaDiff: g.SchemaDiff}
+	upSQL, err = mysqlGen.generateUpSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "INSERT IGNORE INTO `currencies`")
+}
+
+func TestGenerateSQL_ReferenceDataUpdate(t *testing.T) {
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		RowsToUpdate: []diff.ReferenceRowUpdate{{
+			Table:    "currencies",
+			Key:      map[string]any{"code": "GBP"},
+			Values:   map[string]any{"name": "Pound Sterling", "decimals": 2},
+			Previous: map[string]any{"name": "Pound", "decimals": nil},
+		}},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE \"currencies\" SET \"decimals\" = 2, \"name\" = 'Pound Sterling' WHERE \"code\" = 'GBP';", upSQL)
+	require.Equal(t, "UPDATE \"currencies\" SET \"decimals\" = NULL, \"name\" = 'Pound' WHERE \"code\" = 'GBP';", g.generateDownSQL())
+}
+
+func TestGenerateSQL_TableComment(t *testing.T) {
+	created := diff.TableDiff{
+		Schema:      &schema.Schema{Table: "users"},
+		FieldsToAdd: []*schema.Field{{DBName: "id", DataType: "uint", PrimaryKey: true}},
+		Comment:     "Registered users",
+	}
+	modified := diff.TableDiff{
+		Schema:          &schema.Schema{Table: "orders"},
+		Comment:         "Customer's orders",
+		PreviousComment: "Orders",
+	}
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{
+		TablesToCreate: []diff.TableDiff{created},
+		TablesToModify: []diff.TableDiff{modified},
+	}}
+
+	upSQL, err := g.generateUpSQL()
+	require.NoError(t, err)
+	require.Contains(t, upSQL, "COMMENT ON TABLE \"users\" IS 'Registered users';")
+	require.Contains(t, upSQL, "COMMENT ON TABLE \"orders\" IS 'Customer''s orders';")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "COMMENT ON TABLE \"orders\" IS 'Orders';")
+	require.NotContains(t, downSQL, "COMMENT ON TABLE \"users\"")
+}
+
+type testPost struct {
+	ID    uint   `gorm:"primaryKey"`
+	Title string `gorm:"index:idx_posts_title_body,class:FULLTEXT"`
+	Body  string `gorm:"index:idx_posts_title_body,class:FULLTEXT"`
+}
+
+func TestGenerateSQL_FullTextIndexMySQL(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testPost{}))
+
+	var indexes []*schema.Index
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		indexes = append(indexes, idx)
+	}
+	require.Len(t, indexes, 1)
+	table := diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields, IndexesToAdd: indexes}
+
+	gen := NewGenerator("migrations")
+	gen.SetDialect(DialectMySQL)
+
+	createSQL := gen.generateCreateTableSQL(table)
+	require.Contains(t, createSQL, "FULLTEXT INDEX `idx_posts_title_body` (`title`, `body`)")
+	require.NotContains(t, createSQL, "CREATE INDEX `idx_posts_title_body`")
+
+	modifySQL := strings.Join(gen.generateModifyTableSQL(diff.TableDiff{Schema: stmt.Schema, IndexesToAdd: indexes}), "\n")
+	require.Contains(t, modifySQL, "ALTER TABLE `test_posts` ADD FULLTEXT INDEX `idx_posts_title_body` (`title`, `body`);")
+
+	// Postgres has no FULLTEXT index, so the class falls back to a plain index
+	pgSQL := NewGenerator("migrations").generateCreateTableSQL(table)
+	require.NotContains(t, pgSQL, "FULLTEXT")
+	require.Contains(t, pgSQL, "CREATE INDEX \"idx_posts_title_body\"")
+}
+
+type testEvent struct {
+	ID          uint       `gorm:"primaryKey"`
+	PublishedAt *time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func TestGenerateModifyTableSQL_TimestampDefault(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testEvent{}))
+	publishedAt := stmt.Schema.LookUpField("published_at")
+	require.NotNil(t, publishedAt)
+	require.Equal(t, "CURRENT_TIMESTAMP", publishedAt.DefaultValue)
+
+	// The existing column is a nullable timestamp without a default
+	currentSchema := createTestSchema("test_events", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "published_at", DBName: "published_at", DataType: "timestamp"},
+	})
+	targetSchema := createTestSchema("test_events", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		publishedAt,
+	})
+
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+	require.Len(t, tableDiff.FieldsToModify, 1)
+
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+	upSQL := strings.Join(g.generateModifyTableSQL(tableDiff), "\n")
+	require.Contains(t, upSQL, "ALTER TABLE \"test_events\" ALTER COLUMN \"published_at\" SET DEFAULT CURRENT_TIMESTAMP;")
+	require.NotContains(t, upSQL, "timestamp")
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"test_events\" ALTER COLUMN \"published_at\" DROP DEFAULT;")
+}
+
+func TestGenerateModifyTableSQL_DefaultOnlyChange(t *testing.T) {
+	currentSchema := createTestSchema("accounts", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "status", DBName: "status", DataType: "varchar", NotNull: true, DefaultValue: "pending"},
+		{Name: "plan", DBName: "plan", DataType: "varchar", DefaultValue: "free"},
+	})
+	targetSchema := createTestSchema("accounts", []*schema.Field{
+		{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+		{Name: "status", DBName: "status", DataType: "string", NotNull: true, DefaultValue: "'active'"},
+		{Name: "plan", DBName: "plan", DataType: "string"},
+	})
+
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+	require.Len(t, tableDiff.FieldsToModify, 2)
+
+	g := &Generator{SchemaDiff: &diff.SchemaDiff{TablesToModify: []diff.TableDiff{tableDiff}}}
+	require.ElementsMatch(t, []string{
+		"ALTER TABLE \"accounts\" ALTER COLUMN \"status\" SET DEFAULT 'active';",
+		"ALTER TABLE \"accounts\" ALTER COLUMN \"plan\" DROP DEFAULT;",
+	}, g.generateModifyTableSQL(tableDiff))
+
+	downSQL := g.generateDownSQL()
+	require.Contains(t, downSQL, "ALTER TABLE \"accounts\" ALTER COLUMN \"status\" SET DEFAULT 'pending';")
+	require.Contains(t, downSQL, "ALTER TABLE \"accounts\" ALTER COLUMN \"plan\" SET DEFAULT 'free';")
+}
+
+type testSessionData struct {
+	Values map[string]string
+}
+
+type testSession struct {
+	ID      uint            `gorm:"primaryKey"`
+	Payload testSessionData `gorm:"serializer:gob"`
+	Meta    testSessionData `gorm:"serializer:json"`
+}
+
+func TestGenerateCreateTableSQL_GobSerializer(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemas, err := comparer.GetModelSchemas(&testSession{})
+	require.NoError(t, err)
+	sessions := schemas["testSession"]
+	require.NotNil(t, sessions)
+	table := diff.TableDiff{Schema: sessions, FieldsToAdd: sessions.Fields}
+
+	createSQL := (&Generator{}).generateCreateTableSQL(table)
+	require.Contains(t, createSQL, "payload bytea")
+	require.Contains(t, createSQL, "meta varchar(255)")
+
+	mysqlGen := &Generator{Dialect: DialectMySQL}
+	require.Contains(t, mysqlGen.generateCreateTableSQL(table), "payload BLOB")
+
+	// The parsed model schema gorm caches still reports a string column
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testSession{}))
+	require.Equal(t, schema.String, stmt.Schema.LookUpField("payload").DataType)
+}
+
+// testDecimal stands in for decimal.Decimal, a struct stored through
+// driver.Valuer
+type testDecimal struct {
+	value string
+}
+
+func (d testDecimal) Value() (driver.Value, error) { return d.value, nil }
+
+func (d *testDecimal) Scan(src any) error {
+	d.value = fmt.Sprint(src)
+	return nil
+}
+
+type testInvoice struct {
+	ID    uint `gorm:"primaryKey"`
+	Total testDecimal
+}
+
+// decimalMapper stores testDecimal fields as numeric(19,4)
+type decimalMapper struct{}
+
+func (decimalMapper) Map(field *schema.Field) (string, bool) {
+	if field.FieldType == reflect.TypeOf(testDecimal{}) {
+		return "numeric(19,4)", true
+	}
+	return "", false
+}
+
+func TestGenerateCreateTableSQL_CompositePrimaryKey(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "user_roles"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "user_id", DataType: "uint", PrimaryKey: true},
+			{DBName: "role_id", DataType: "uint", PrimaryKey: true},
+		},
+	}
+
+	createSQL := NewGenerator(t.TempDir()).generateCreateTableSQL(table)
+	require.Equal(t, "CREATE TABLE \"user_roles\" (\n    user_id bigint,\n    role_id bigint,\n    PRIMARY KEY (\"user_id\", \"role_id\")\n);", createSQL)
+}
+
+func TestGenerateCreateTableSQL_TypeMapper(t *testing.T) {
+	comparer := diff.NewSchemaComparer(createTestDB(t))
+	schemas, err := comparer.GetModelSchemas(&testInvoice{})
+	require.NoError(t, err)
+	invoices := schemas["testInvoice"]
+	require.NotNil(t, invoices)
+	table := diff.TableDiff{Schema: invoices, FieldsToAdd: invoices.Fields}
+
+	gen := NewGenerator(t.TempDir())
+	gen.RegisterTypeMapper(decimalMapper{})
+	createSQL := gen.generateCreateTableSQL(table)
+	require.Contains(t, createSQL, "total numeric(19,4)")
+	require.Contains(t, createSQL, "id BIGSERIAL PRIMARY KEY")
+}
+
+func TestGenerateCreateTableSQL_Dialects(t *testing.T) {
+	table := diff.TableDiff{
+		Schema: &schema.Schema{Table: "events"},
+		FieldsToAdd: []*schema.Field{
+			{DBName: "id", DataType: schema.Uint, PrimaryKey: true, AutoIncrement: true},
+			{DBName: "count", DataType: schema.Int},
+			{DBName: "score", DataType: schema.Float},
+			{DBName: "payload", DataType: "json"},
+			{DBName: "at", DataType: schema.Time},
+		},
+	}
+
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{DialectPostgres, "CREATE TABLE \"events\" (\n    id BIGSERIAL PRIMARY KEY,\n    count integer,\n    score double precision,\n    payload jsonb,\n    at timestamp\n);"},
+		{DialectMySQL, "CREATE TABLE `events` (\n    id bigint unsigned AUTO_INCREMENT PRIMARY KEY,\n    count int,\n    score double,\n    payload json,\n    at datetime\n);"},
+		{DialectSQLite, "CREATE TABLE \"events\" (\n    id integer PRIMARY KEY AUTOINCREMENT,\n    count integer,\n    score real,\n    payload text,\n    at datetime\n);"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			gen := NewGenerator(t.TempDir())
+			gen.SetDialect(tt.dialect)
+			require.Equal(t, tt.want, gen.generateCreateTableSQL(table))
+			if tt.dialect == DialectSQLite {
+				require.NoError(t, createTestDB(t).Exec(tt.want).Error)
+			}
+		})
+	}
+}
+
+type testLedger struct {
+	ID     uint `gorm:"primaryKey"`
+	Amount int
+}
+
+func (testLedger) TableOptions(dialect string) string {
+	if dialect == DialectMySQL {
+		return "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	}
+	return "TABLESPACE fastspace"
+}
+
+func TestGenerateCreateTableSQL_TableOptions(t *testing.T) {
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testLedger{}))
+	table := diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields}
+
+	mysqlGen := NewGenerator("migrations")
+	mysqlGen.SetDialect(DialectMySQL)
+	require.Contains(t, mysqlGen.generateCreateTableSQL(table), "\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;")
+
+	pgSQL := NewGenerator("migrations").generateCreateTableSQL(table)
+	require.Contains(t, pgSQL, "\n) TABLESPACE fastspace;")
+	require.NotContains(t, pgSQL, "ENGINE")
+
+	// Models without TableOptions keep a bare closing paren
+	plain := diff.TableDiff{Schema: &schema.Schema{Table: "plain"}, FieldsToAdd: []*schema.Field{{DBName: "id", DataType: "int", PrimaryKey: true}}}
+	require.Contains(t, NewGenerator("migrations").generateCreateTableSQL(plain), "\n);")
+}
+
+type testStory struct {
+	ID    uint `gorm:"primaryKey"`
+	Title string
+}
+
+func (testStory) TableCharset() (string, string) {
+	return "utf8mb4", "utf8mb4_0900_ai_ci"
+}
+
+func TestGenerateCreateTableSQL_MySQLCharset(t *testing.T) {
+	plain := diff.TableDiff{Schema: &schema.Schema{Table: "plain"}, FieldsToAdd: []*schema.Field{{DBName: "id", DataType: "int", PrimaryKey: true}}}
+
+	mysqlGen := NewGenerator("migrations")
+	mysqlGen.SetDialect(DialectMySQL)
+	mysqlGen.SetCharset("utf8mb4", "utf8mb4_unicode_ci")
+	require.True(t, strings.HasSuffix(mysqlGen.generateCreateTableSQL(plain), "\n) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;"))
+
+	// A model's own charset wins over the default
+	stmt := &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testStory{}))
+	stories := diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields}
+	require.Contains(t, mysqlGen.generateCreateTableSQL(stories), "\n) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;")
+
+	// Table options that already set a charset are left alone
+	stmt = &gorm.Statement{DB: createTestDB(t)}
+	require.NoError(t, stmt.Parse(&testLedger{}))
+	ledgers := diff.TableDiff{Schema: stmt.Schema, FieldsToAdd: stmt.Schema.Fields}
+	require.Contains(t, mysqlGen.generateCreateTableSQL(ledgers), "\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;")
+
+	// Postgres ignores the MySQL charset
+	pgGen := NewGenerator("migrations")
+	pgGen.SetCharset("utf8mb4", "utf8mb4_unicode_ci")
+	require.NotContains(t, pgGen.generateCreateTableSQL(plain), "CHARSET")
+}
+
+func TestWriteMigration_PreservesMarkerBlocks(t *testing.T) {
+	dir := t.TempDir()
+	gen := NewGenerator(dir)
+	gen.SetSchemaDiff(&diff.SchemaDiff{
+		TablesToModify: []diff.TableDiff{{
+			Schema: &schema.Schema{Table: "users"},
+			FieldsToAdd: []*schema.Field{
+				{DBName: "nickname", DataType: "string"},
+				{DBName: "age", DataType: "int"},
+			},
+		}},
+	})
+	require.NoError(t, gen.WriteMigration("20240101000000", "add_profile"))
+
+	path := filepath.Join(dir, "20240101000000_add_profile.go")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Hand-edit a backfill between the two generated statements
+	block := "\t\t// migrate:preserve-start\n\t\tdb.Exec(\"UPDATE users SET nickname = name\")\n\t\t// migrate:preserve-end"
+	lines := strings.Split(string(content), "\n")
+	var edited []string
+	inserted := false
+	for _, line := range lines {
+		edited = append(edited, line)
+		if !inserted && strings.TrimSpace(line) == "}" && strings.Contains(strings.Join(edited, "\n"), "nickname") {
+			edited = append(edited, block)
+			inserted = true
+		}
+	}
+	require.True(t, inserted)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(edited, "\n")), 0644))
+
+	// Regenerate with an extra column
+	gen.SetSchemaDiff(&diff.SchemaDiff{
+		TablesToModify: []diff.TableDiff{{
+			Schema: &schema.Schema{Table: "users"},
+			FieldsToAdd: []*schema.Field{
+				{DBName: "nickname", DataType: "string"},
+				{DBName: "age", DataType: "int"},
+				{DBName: "bio", DataType: "string"},
+			},
+		}},
+	})
+	require.NoError(t, gen.WriteMigration("20240101000000", "add_profile"))
+
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	rewritten := string(content)
+	require.Contains(t, rewritten, "bio")
+	require.Equal(t, 1, strings.Count(rewritten, block), "preserved block should appear exactly once")
+
+	blockAt := strings.Index(rewritten, block)
+	require.Greater(t, blockAt, strings.Index(rewritten, `ADD COLUMN "nickname"`))
+	require.Less(t, blockAt, strings.Index(rewritten, `ADD COLUMN "age"`))
+}
+
+func TestScanMigration_InterpretedStrings(t *testing.T) {
+	g := &Generator{Dialect: DialectMySQL, SchemaDiff: &diff.SchemaDiff{
+		TablesToModify: []diff.TableDiff{{
+			Schema:      &schema.Schema{Table: "users"},
+			FieldsToAdd: []*schema.Field{{DBName: "nickname", DataType: "string"}},
+		}},
+	}}
+	content, err := g.renderMigration("20240101000000", "add_nickname")
+	require.NoError(t, err)
+
+	// MySQL statements are written as interpreted strings and still located
+	layout := scanMigration(strings.Split(content, "\n"))
+	require.Len(t, layout.execs, 2)
+	require.Equal(t, "Up", layout.execs[0].function)
+	require.Contains(t, layout.execs[0].sql, "ADD COLUMN `nickname`")
+}
+
+type testGadget struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:64;not null"`
+}
+
+func TestApply(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "apply.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	schemaDiff, err := diff.NewSchemaComparer(db).Compare(&testGadget{})
+	require.NoError(t, err)
+	require.NoError(t, Apply(db, schemaDiff))
+
+	require.True(t, db.Migrator().HasTable("test_gadgets"))
+	require.NoError(t, db.Create(&testGadget{Name: "lamp"}).Error)
+
+	// Applying the same diff again fails on the existing table
+	require.ErrorContains(t, Apply(db, schemaDiff), "already exists")
+
+	// SQL is only generated for known dialects
+	other := &gorm.DB{Config: &gorm.Config{Dialector: otherDialector{}}}
+	require.ErrorContains(t, Apply(other, schemaDiff), "unsupported dialect oracle")
+}
+
+// otherDialector is a database the generator has no dialect for
+type otherDialector struct {
+	gorm.Dialector
+}
+
+func (otherDialector) Name() string { return "oracle" }
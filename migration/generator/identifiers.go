@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// plainIdentifier matches names that mean the same quoted or not
+var plainIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// reservedWords are the keywords of each dialect that cannot be used as
+// plain identifiers. CockroachDB, and any dialect not listed, follows Postgres.
+var reservedWords = map[string]map[string]bool{
+	DialectPostgres: wordSet(`all analyse analyze and any array as asc asymmetric
+		authorization binary both case cast check collate collation column
+		concurrently constraint create cross current_catalog current_date
+		current_role current_schema current_time current_timestamp current_user
+		default deferrable desc distinct do else end except false fetch for
+		foreign freeze from full grant group having ilike in initially inner
+		intersect into is isnull join lateral leading left like limit localtime
+		localtimestamp natural not notnull null offset on only or order outer
+		overlaps placing primary references returning right select session_user
+		similar some symmetric system_user table tablesample then to trailing
+		true union unique user using variadic verbose when where window with`),
+	DialectMySQL: wordSet(`accessible add all alter analyze and as asc asensitive
+		before between bigint binary blob both by call cascade case change char
+		character check collate column condition constraint continue convert
+		create cross cube cume_dist current_date current_time current_timestamp
+		current_user cursor database databases day_hour day_microsecond
+		day_minute day_second dec decimal declare default delayed delete
+		dense_rank desc describe deterministic distinct distinctrow div double
+		drop dual each else elseif empty enclosed escaped except exists exit
+		explain false fetch first_value float float4 float8 for force foreign
+		from fulltext function generated get grant group grouping groups having
+		high_priority hour_microsecond hour_minute hour_second if ignore in index
+		infile inner inout insensitive insert int int1 int2 int3 int4 int8
+		integer intersect interval into io_after_gtids io_before_gtids is
+		iterate join json_table key keys kill lag last_value lateral lead leading
+		leave left like limit linear lines load localtime localtimestamp lock
+		long longblob longtext loop low_priority master_bind
+		master_ssl_verify_server_cert match maxvalue mediumblob mediumint
+		mediumtext middleint minute_microsecond minute_second mod modifies
+		natural not no_write_to_binlog nth_value ntile null numeric of on
+		optimize optimizer_costs option optionally or order out outer outfile
+		over partition percent_rank precision primary procedure purge range rank
+		read reads read_write real recursive references regexp release rename
+		repeat replace require resignal restrict return revoke right rlike row
+		row_number rows schema schemas second_microsecond select sensitive
+		separator set show signal smallint spatial specific sql sql_big_result
+		sql_calc_found_rows sql_small_result sqlexception sqlstate sqlwarning
+		ssl starting stored straight_join system table terminated then tinyblob
+		tinyint tinytext to trailing trigger true undo union unique unlock
+		unsigned update usage use using utc_date utc_time utc_timestamp values
+		varbinary varchar varcharacter varying virtual when where while window
+		with write xor year_month zerofill`),
+	DialectSQLite: wordSet(`abort action add after all alter always analyze and as
+		asc attach autoincrement before begin between by cascade case cast check
+		collate column commit conflict constraint create cross current
+		current_date current_time current_timestamp database default deferrable
+		deferred delete desc detach distinct do drop each else end escape except
+		exclude exclusive exists explain fail filter first following for foreign
+		from full generated glob group groups having if ignore immediate in index
+		indexed initially inner insert instead intersect into is isnull join key
+		last left like limit match materialized natural no not nothing notnull
+		null nulls of offset on or order others outer over partition plan pragma
+		preceding primary query raise range recursive references regexp reindex
+		release rename replace restrict returning right rollback row rows
+		savepoint select set table temp temporary then ties to transaction
+		trigger unbounded union unique update using vacuum values view virtual
+		when where window with without`),
+}
+
+// wordSet returns the whitespace-separated words as a set
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// SetQuoteIdentifiers sets whether every identifier is double-quoted, the
+// default. With quoting off, lowercase names are emitted plain and only
+// reserved words and names with other characters stay quoted.
+func (g *Generator) SetQuoteIdentifiers(enabled bool) {
+	g.Unquoted = !enabled
+}
+
+// identifiers applies the identifier quoting setting to generated SQL
+func (g *Generator) identifiers(sql string) string {
+	if !g.Unquoted {
+		return sql
+	}
+	return unquoteIdentifiers(sql, g.identifierQuote()[0], g.mustQuote)
+}
+
+// mustQuote reports whether an identifier has to be quoted whatever the
+// quoting setting: it is a reserved word of the dialect, or it is not a plain
+// lowercase name and would otherwise be folded or fail to parse
+func (g *Generator) mustQuote(name string) bool {
+	words, ok := reservedWords[g.dialect()]
+	if !ok {
+		words = reservedWords[DialectPostgres]
+	}
+	return !plainIdentifier.MatchString(name) || words[name]
+}
+
+// columnName returns a column name for a column definition, quoted only when
+// it must be
+func (g *Generator) columnName(name string) string {
+	if g.mustQuote(name) {
+		return g.quoteIdentifier(name)
+	}
+	return name
+}
+
+// unquoteIdentifiers drops the quotes around identifiers for which mustQuote
+// is false. String literals and comments are left as they are.
+func unquoteIdentifiers(sql string, quote byte, mustQuote func(string) bool) string {
+	var b strings.Builder
+	inLiteral := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			inLiteral = !inLiteral
+		case inLiteral:
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			b.WriteString(sql[i : i+end])
+			i += end - 1
+			continue
+		case c == quote:
+			end := strings.IndexByte(sql[i+1:], quote)
+			if end < 0 {
+				break
+			}
+			name := sql[i+1 : i+1+end]
+			if mustQuote(name) {
+				b.WriteString(sql[i : i+end+2])
+			} else {
+				b.WriteString(name)
+			}
+			i += end + 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package generator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Marker comments around hand-written code that survives regeneration
+const (
+	preserveStart = "// migrate:preserve-start"
+	preserveEnd   = "// migrate:preserve-end"
+)
+
+// preservedBlock is a marker block taken from an existing migration file
+type preservedBlock struct {
+	function string   // "Up" or "Down"
+	after    string   // SQL of the db.Exec the block follows, empty at the top of the function
+	lines    []string // block lines, markers included
+}
+
+// execSpan locates a generated db.Exec call in a migration file
+type execSpan struct {
+	function string
+	sql      string
+	end      int // line of the closing brace of the error check
+}
+
+// migrationLayout is the position of each function and db.Exec call in a
+// migration file
+type migrationLayout struct {
+	headers map[string]int
+	returns map[string]int
+	execs   []execSpan
+}
+
+// scanMigration locates the Up and Down functions of a migration file and the
+// db.Exec calls inside them
+func scanMigration(lines []string) migrationLayout {
+	layout := migrationLayout{headers: map[string]int{}, returns: map[string]int{}}
+	function := ""
+	preserving := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == preserveStart:
+			preserving = true
+		case strings.TrimSpace(line) == preserveEnd:
+			preserving = false
+		case preserving:
+			// Hand-written code is not part of the generated layout
+		case strings.Contains(line, "Up: func(db *gorm.DB)"):
+			function = "Up"
+			layout.headers[function] = i
+		case strings.Contains(line, "Down: func(db *gorm.DB)"):
+			function = "Down"
+			layout.headers[function] = i
+		case function != "" && strings.TrimSpace(line) == "return nil":
+			layout.returns[function] = i
+			function = ""
+		case function != "" && strings.Contains(line, "db.Exec(`"):
+			start := i
+			for i < len(lines) && !strings.Contains(lines[i], "`).Error") {
+				i++
+			}
+			if i >= len(lines) {
+				return layout
+			}
+			sql := strings.Join(lines[start:i+1], "\n")
+			sql = sql[strings.Index(sql, "`")+1 : strings.Index(sql, "`).Error")]
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "}" {
+				i++
+			}
+			layout.execs = append(layout.execs, execSpan{function: function, sql: strings.Join(strings.Fields(sql), " "), end: i})
+		case function != "" && strings.Contains(line, `db.Exec("`) && strings.Contains(line, `").Error`):
+			// Statements holding backticks are written as interpreted strings
+			literal := line[strings.Index(line, `db.Exec("`)+len("db.Exec(") : strings.LastIndex(line, `").Error`)+1]
+			sql, err := strconv.Unquote(literal)
+			if err != nil {
+				continue
+			}
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "}" {
+				i++
+			}
+			layout.execs = append(layout.execs, execSpan{function: function, sql: strings.Join(strings.Fields(sql), " "), end: i})
+		}
+	}
+	return layout
+}
+
+// extractPreservedBlocks returns the marker blocks of an existing migration
+// file along with the statement each one follows
+func extractPreservedBlocks(content string) []preservedBlock {
+	lines := strings.Split(content, "\n")
+	layout := scanMigration(lines)
+
+	var blocks []preservedBlock
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != preserveStart {
+			continue
+		}
+		start := i
+		for i < len(lines) && strings.TrimSpace(lines[i]) != preserveEnd {
+			i++
+		}
+		if i >= len(lines) {
+			break
+		}
+
+		block := preservedBlock{lines: lines[start : i+1]}
+		for function, header := range layout.headers {
+			if header < start && (block.function == "" || header > layout.headers[block.function]) {
+				block.function = function
+			}
+		}
+		for _, exec := range layout.execs {
+			if exec.function == block.function && exec.end < start {
+				block.after = exec.sql
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// insertPreservedBlocks places marker blocks into freshly generated content,
+// after the statement they followed before. A block whose statement is gone
+// moves to the end of its function.
+func insertPreservedBlocks(content string, blocks []preservedBlock) string {
+	if len(blocks) == 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	layout := scanMigration(lines)
+
+	inserts := make(map[int][]string)
+	for _, block := range blocks {
+		at, ok := layout.returns[block.function]
+		if !ok {
+			continue
+		}
+		at--
+		if block.after == "" {
+			at = layout.headers[block.function]
+		} else {
+			for _, exec := range layout.execs {
+				if exec.function == block.function && exec.sql == block.after {
+					at = exec.end
+					break
+				}
+			}
+		}
+		inserts[at] = append(inserts[at], block.lines...)
+	}
+
+	var out []string
+	for i, line := range lines {
+		out = append(out, line)
+		out = append(out, inserts[i]...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// sameStatements reports whether two migration files run the same generated
+// statements in each function, in any order
+func sameStatements(a, b string) bool {
+	statements := func(content string) map[string][]string {
+		byFunction := make(map[string][]string)
+		for _, exec := range scanMigration(strings.Split(content, "\n")).execs {
+			byFunction[exec.function] = append(byFunction[exec.function], exec.sql)
+		}
+		for _, sqls := range byFunction {
+			sort.Strings(sqls)
+		}
+		return byFunction
+	}
+
+	left, right := statements(a), statements(b)
+	if len(left) != len(right) {
+		return false
+	}
+	for function, sqls := range left {
+		if strings.Join(sqls, "\n") != strings.Join(right[function], "\n") {
+			return false
+		}
+	}
+	return true
+}
@@ -14,6 +14,22 @@ type Migration struct {
 	CreatedAt time.Time
 	Up        func(*gorm.DB) error
 	Down      func(*gorm.DB) error
+	// NonTransactional runs the migration outside a transaction, for
+	// statements such as ALTER TYPE ... ADD VALUE that Postgres refuses in one
+	NonTransactional bool
+	// Tags label the migration for selective runs such as `up --tag online`.
+	// Migration files declare them with a `// migrate:tags a,b` comment.
+	Tags []string
+}
+
+// HasTag reports whether the migration is labelled with tag
+func (m *Migration) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
 
 type MigrationRecord struct {
@@ -22,9 +38,36 @@ type MigrationRecord struct {
 	AppliedAt time.Time `gorm:"not null"`
 }
 
+// LatestFirst orders migration records from the most recently applied.
+// Migrations applied in the same instant are applied in version order, so
+// the version breaks ties on applied_at.
+const LatestFirst = "applied_at DESC, version DESC"
+
+// MaterializedView is a Postgres materialized view that generate creates
+// from its query
+type MaterializedView struct {
+	Name string
+	SQL  string
+}
+
+// Enum is a Postgres enum type and its values, in order
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+// ReferenceData is a set of rows a reference table must contain
+type ReferenceData struct {
+	Table string
+	Rows  []map[string]any
+}
+
 var (
-	globalMigrations = make([]*Migration, 0)
-	registryMutex    sync.RWMutex
+	globalMigrations        = make([]*Migration, 0)
+	globalMaterializedViews = make([]MaterializedView, 0)
+	globalEnums             = make([]Enum, 0)
+	globalReferenceData     = make([]ReferenceData, 0)
+	registryMutex           sync.RWMutex
 )
 
 func RegisterMigration(migration *Migration) {
@@ -42,6 +85,80 @@ func GetRegisteredMigrations() []*Migration {
 	return migrations
 }
 
+// RegisterMaterializedView registers a materialized view defined by a query.
+// generate creates it when it is missing, and tables or models with the same
+// name are not diffed as tables.
+func RegisterMaterializedView(name, sql string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalMaterializedViews = append(globalMaterializedViews, MaterializedView{Name: name, SQL: sql})
+}
+
+func GetRegisteredMaterializedViews() []MaterializedView {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	views := make([]MaterializedView, len(globalMaterializedViews))
+	copy(views, globalMaterializedViews)
+	return views
+}
+
+// RegisterEnum registers a Postgres enum type for models to use as a column
+// type, e.g. `gorm:"type:mood"`. generate creates the type when it is
+// missing and adds values appended to it later.
+func RegisterEnum(name string, values ...string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalEnums = append(globalEnums, Enum{Name: name, Values: values})
+}
+
+func GetRegisteredEnums() []Enum {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	enums := make([]Enum, len(globalEnums))
+	copy(enums, globalEnums)
+	return enums
+}
+
+// RegisterReferenceData registers rows, keyed by column name, that a
+// reference table must contain. generate inserts the missing ones with
+// INSERT ... ON CONFLICT DO NOTHING, so re-running the migration is safe.
+func RegisterReferenceData(table string, rows []map[string]any) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalReferenceData = append(globalReferenceData, ReferenceData{Table: table, Rows: rows})
+}
+
+func GetRegisteredReferenceData() []ReferenceData {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	data := make([]ReferenceData, len(globalReferenceData))
+	copy(data, globalReferenceData)
+	return data
+}
+
+// RefreshMaterializedView reloads the data of a materialized view
+func RefreshMaterializedView(db *gorm.DB, name string) error {
+	return db.Exec(fmt.Sprintf(`REFRESH MATERIALIZED VIEW "%s"`, name)).Error
+}
+
+// ForDialect runs the branch registered for the database's dialect, such as
+// "postgres", "mysql" or "sqlite", so one migration can target several
+// databases. A "default" branch runs when no dialect matches.
+func ForDialect(db *gorm.DB, branches map[string]func(*gorm.DB) error) error {
+	dialect := db.Dialector.Name()
+	branch, ok := branches[dialect]
+	if !ok {
+		branch, ok = branches["default"]
+	}
+	if !ok {
+		return fmt.Errorf("no migration branch for dialect %s", dialect)
+	}
+	return branch(db)
+}
+
 type Migrator struct {
 	db         *gorm.DB
 	migrations []*Migration
@@ -59,7 +176,22 @@ func (m *Migrator) Register(migration *Migration) {
 }
 
 func (m *Migrator) ensureVersionTable() error {
-	return m.db.AutoMigrate(&MigrationRecord{})
+	return EnsureVersionTable(m.db)
+}
+
+// EnsureVersionTable creates or updates the migration tracking table. When
+// the table already has the expected columns it is left alone, as
+// AutoMigrate can need elevated privileges or take locks on managed
+// databases.
+func EnsureVersionTable(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasTable(&MigrationRecord{}) &&
+		migrator.HasColumn(&MigrationRecord{}, "Version") &&
+		migrator.HasColumn(&MigrationRecord{}, "Name") &&
+		migrator.HasColumn(&MigrationRecord{}, "AppliedAt") {
+		return nil
+	}
+	return db.AutoMigrate(&MigrationRecord{})
 }
 
 func (m *Migrator) GetAppliedVersions() (map[string]bool, error) {
@@ -88,7 +220,7 @@ func (m *Migrator) Up() error {
 	for _, migration := range m.migrations {
 		if !applied[migration.Version] {
 			if err := migration.Up(m.db); err != nil {
-				return err
+				return &MigrationApplyError{Version: migration.Version, Name: migration.Name, Err: err}
 			}
 
 			record := MigrationRecord{
@@ -98,7 +230,7 @@ func (m *Migrator) Up() error {
 			}
 
 			if err := m.db.Create(&record).Error; err != nil {
-				return err
+				return &MigrationApplyError{Version: migration.Version, Name: migration.Name, Err: err}
 			}
 		}
 	}
@@ -107,7 +239,7 @@ func (m *Migrator) Up() error {
 
 func (m *Migrator) Down() error {
 	var lastRecord MigrationRecord
-	if err := m.db.Order("applied_at DESC").First(&lastRecord).Error; err != nil {
+	if err := m.db.Order(LatestFirst).First(&lastRecord).Error; err != nil {
 		return err
 	}
 
@@ -136,6 +268,24 @@ func ResetMigrations() {
 	globalMigrations = make([]*Migration, 0)
 }
 
+func ResetMaterializedViews() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalMaterializedViews = make([]MaterializedView, 0)
+}
+
+func ResetEnums() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalEnums = make([]Enum, 0)
+}
+
+func ResetReferenceData() {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	globalReferenceData = make([]ReferenceData, 0)
+}
+
 // ModelRegistry - users must implement this
 type ModelRegistry interface {
 	GetModels() map[string]interface{}
@@ -147,7 +297,7 @@ var GlobalModelRegistry ModelRegistry
 // Validate that registry is provided
 func ValidateRegistry() error {
 	if GlobalModelRegistry == nil {
-		return fmt.Errorf("no model registry provided. Please implement migration.ModelRegistry and set it in your main.go")
+		return fmt.Errorf("%w. Please implement migration.ModelRegistry and set it in your main.go", ErrNoRegistry)
 	}
 	return nil
 }
@@ -1,8 +1,16 @@
 package migration
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // TestModel is a simple test model
@@ -24,8 +32,8 @@ func TestValidateRegistry(t *testing.T) {
 	// Test with no registry set
 	GlobalModelRegistry = nil
 	err := ValidateRegistry()
-	if err == nil {
-		t.Error("Expected error when no registry is set")
+	if !errors.Is(err, ErrNoRegistry) {
+		t.Errorf("Expected ErrNoRegistry when no registry is set, got: %v", err)
 	}
 
 	// Test with registry set
@@ -49,7 +57,132 @@ func TestModelRegistry(t *testing.T) {
 	}
 
 	expectedType := reflect.TypeOf(TestModel{})
-	if modelTypes["TestModel"] != expectedType {
-		t.Errorf("Expected type %v, got %v", expectedType, modelTypes["TestModel"])
+	if reflect.TypeOf(modelTypes["TestModel"]) != expectedType {
+		t.Errorf("Expected type %v, got %v", expectedType, reflect.TypeOf(modelTypes["TestModel"]))
+	}
+}
+
+func TestForDialect(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	ran := ""
+	branches := map[string]func(*gorm.DB) error{
+		"postgres": func(db *gorm.DB) error { ran = "postgres"; return nil },
+		"sqlite": func(db *gorm.DB) error {
+			ran = "sqlite"
+			return db.Exec("CREATE TABLE dialect_widgets (id INTEGER PRIMARY KEY)").Error
+		},
+	}
+	if err := ForDialect(db, branches); err != nil {
+		t.Fatalf("ForDialect returned error: %v", err)
+	}
+	if ran != "sqlite" {
+		t.Errorf("Expected the sqlite branch to run, got %q", ran)
+	}
+	if !db.Migrator().HasTable("dialect_widgets") {
+		t.Error("Expected the sqlite branch to create its table")
+	}
+
+	// Falls back to the default branch, and fails without one
+	delete(branches, "sqlite")
+	branches["default"] = func(db *gorm.DB) error { ran = "default"; return nil }
+	if err := ForDialect(db, branches); err != nil || ran != "default" {
+		t.Errorf("Expected the default branch to run, got %q (err: %v)", ran, err)
+	}
+
+	delete(branches, "default")
+	if err := ForDialect(db, branches); err == nil {
+		t.Error("Expected error when no branch matches the dialect")
+	}
+}
+
+func TestMigratorUpApplyError(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	failure := errors.New("boom")
+	migrator := NewMigrator(db)
+	migrator.Register(&Migration{
+		Version: "20240101000000",
+		Name:    "broken",
+		Up:      func(db *gorm.DB) error { return failure },
+	})
+
+	err = migrator.Up()
+	var applyErr *MigrationApplyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("Expected MigrationApplyError, got: %v", err)
+	}
+	if applyErr.Version != "20240101000000" {
+		t.Errorf("Expected version 20240101000000, got %s", applyErr.Version)
+	}
+	if !errors.Is(err, failure) {
+		t.Error("Expected MigrationApplyError to wrap the migration's error")
+	}
+}
+
+// statementLogger records the SQL of every statement gorm runs
+type statementLogger struct {
+	logger.Interface
+	statements []string
+}
+
+func (l *statementLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *statementLogger) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	l.statements = append(l.statements, sql)
+}
+
+func TestEnsureVersionTableSkipsMatchingTable(t *testing.T) {
+	recorder := &statementLogger{Interface: logger.Discard}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: recorder})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	if err := db.Exec("CREATE TABLE migration_records (version text PRIMARY KEY, name text, applied_at datetime)").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	recorder.statements = nil
+
+	if err := EnsureVersionTable(db); err != nil {
+		t.Fatalf("EnsureVersionTable failed: %v", err)
+	}
+	// Only the existence checks run; AutoMigrate would read the column types
+	for _, statement := range recorder.statements {
+		if !strings.HasPrefix(statement, "SELECT count(*)") {
+			t.Errorf("Expected AutoMigrate to be skipped for a matching table, got: %s", statement)
+		}
+	}
+
+	// A missing table is still created
+	if err := db.Migrator().DropTable(&MigrationRecord{}); err != nil {
+		t.Fatalf("failed to drop table: %v", err)
+	}
+	if err := EnsureVersionTable(db); err != nil {
+		t.Fatalf("EnsureVersionTable failed: %v", err)
+	}
+	if !db.Migrator().HasTable(&MigrationRecord{}) {
+		t.Error("Expected the tracking table to be created")
+	}
+}
+
+func TestRegisterMaterializedView(t *testing.T) {
+	ResetMaterializedViews()
+	defer ResetMaterializedViews()
+
+	RegisterMaterializedView("order_totals", "SELECT user_id, SUM(amount) FROM orders GROUP BY user_id")
+
+	views := GetRegisteredMaterializedViews()
+	if len(views) != 1 || views[0].Name != "order_totals" {
+		t.Errorf("Expected the order_totals view to be registered, got %v", views)
 	}
 }
@@ -1,9 +1,15 @@
 package migration
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -30,6 +36,28 @@ func TestGenerateCmd(t *testing.T) {
 	cmd := commands.GenerateCmd()
 	assert.Equal(t, "generate [name]", cmd.Use)
 	assert.Equal(t, "Generate a migration from model changes", cmd.Short)
+
+	failOnEmpty := cmd.Flags().Lookup("fail-on-empty")
+	require.NotNil(t, failOnEmpty)
+	assert.Equal(t, "false", failOnEmpty.DefValue)
+
+	merge := cmd.Flags().Lookup("merge")
+	require.NotNil(t, merge)
+	assert.Equal(t, "false", merge.DefValue)
+}
+
+func TestDriftCmd(t *testing.T) {
+	cmd := commands.DriftCmd()
+	assert.Equal(t, "drift", cmd.Use)
+	assert.Equal(t, "Check whether the database schema matches the models", cmd.Short)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, commands.ExitOK, commands.ExitCode(nil))
+	assert.Equal(t, commands.ExitError, commands.ExitCode(errors.New("failed to connect")))
+	assert.Equal(t, commands.ExitChanges, commands.ExitCode(commands.ErrSchemaDrift))
+	assert.Equal(t, commands.ExitChanges, commands.ExitCode(fmt.Errorf("drift: %w", commands.ErrSchemaDrift)))
+	assert.NotEqual(t, commands.ExitCode(errors.New("failed to connect")), commands.ExitCode(commands.ErrSchemaDrift))
 }
 
 func TestUpCmd(t *testing.T) {
@@ -40,6 +68,257 @@ func TestUpCmd(t *testing.T) {
 	flags := cmd.Flags()
 	assert.NotNil(t, flags.Lookup("dry-run"))
 	assert.NotNil(t, flags.Lookup("debug"))
+	assert.NotNil(t, flags.Lookup("only"))
+}
+
+func TestUpCmdWithInjectedDB(t *testing.T) {
+	os.Setenv("TEST_MIGRATION_REGISTRY_ONLY", "1")
+	defer func() {
+		if err := os.Unsetenv("TEST_MIGRATION_REGISTRY_ONLY"); err != nil {
+			t.Errorf("failed to unset TEST_MIGRATION_REGISTRY_ONLY: %v", err)
+		}
+	}()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+
+	commands.SetDB(db)
+	defer commands.SetDB(nil)
+
+	migration.ResetMigrations()
+	defer migration.ResetMigrations()
+	migration.RegisterMigration(&migration.Migration{
+		Version: "20240101000000",
+		Name:    "create_widgets",
+		Up: func(db *gorm.DB) error {
+			return db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT)").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Exec("DROP TABLE widgets").Error
+		},
+	})
+
+	cmd := commands.UpCmd()
+	cmd.SetArgs([]string{})
+	require.NoError(t, cmd.Execute())
+
+	assert.True(t, db.Migrator().HasTable("widgets"))
+
+	var records []migration.MigrationRecord
+	require.NoError(t, db.Find(&records).Error)
+	require.Len(t, records, 1)
+	assert.Equal(t, "20240101000000", records[0].Version)
+}
+
+func TestUpAndDownOnlyVersion(t *testing.T) {
+	os.Setenv("TEST_MIGRATION_REGISTRY_ONLY", "1")
+	defer func() {
+		if err := os.Unsetenv("TEST_MIGRATION_REGISTRY_ONLY"); err != nil {
+			t.Errorf("failed to unset TEST_MIGRATION_REGISTRY_ONLY: %v", err)
+		}
+	}()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+
+	commands.SetDB(db)
+	defer commands.SetDB(nil)
+
+	migration.ResetMigrations()
+	defer migration.ResetMigrations()
+	for _, table := range []struct{ version, name string }{
+		{"20240101000000", "gadgets"},
+		{"20240102000000", "gizmos"},
+	} {
+		name := table.name
+		migration.RegisterMigration(&migration.Migration{
+			Version: table.version,
+			Name:    "create_" + name,
+			Up: func(db *gorm.DB) error {
+				return db.Exec("CREATE TABLE " + name + " (id INTEGER PRIMARY KEY)").Error
+			},
+			Down: func(db *gorm.DB) error {
+				return db.Exec("DROP TABLE " + name).Error
+			},
+		})
+	}
+
+	// Apply the second migration while the first is still pending
+	up := commands.UpCmd()
+	up.SetArgs([]string{"--only", "20240102000000"})
+	require.NoError(t, up.Execute())
+
+	assert.True(t, db.Migrator().HasTable("gizmos"))
+	assert.False(t, db.Migrator().HasTable("gadgets"))
+
+	var records []migration.MigrationRecord
+	require.NoError(t, db.Find(&records).Error)
+	require.Len(t, records, 1)
+	assert.Equal(t, "20240102000000", records[0].Version)
+
+	up = commands.UpCmd()
+	up.SetArgs([]string{"--only", "20240102000000"})
+	assert.Error(t, up.Execute(), "applying an applied migration again should fail")
+
+	down := commands.DownCmd()
+	down.SetArgs([]string{"--only", "20240102000000", "--yes"})
+	require.NoError(t, down.Execute())
+
+	assert.False(t, db.Migrator().HasTable("gizmos"))
+	require.NoError(t, db.Find(&records).Error)
+	assert.Empty(t, records)
+}
+
+func TestDownConfirmsDestructiveMigrations(t *testing.T) {
+	os.Setenv("TEST_MIGRATION_REGISTRY_ONLY", "1")
+	defer func() {
+		if err := os.Unsetenv("TEST_MIGRATION_REGISTRY_ONLY"); err != nil {
+			t.Errorf("failed to unset TEST_MIGRATION_REGISTRY_ONLY: %v", err)
+		}
+	}()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+
+	commands.SetDB(db)
+	defer commands.SetDB(nil)
+
+	migration.ResetMigrations()
+	defer migration.ResetMigrations()
+	migration.RegisterMigration(&migration.Migration{
+		Version: "20240101000000",
+		Name:    "create_widgets",
+		Up: func(db *gorm.DB) error {
+			return db.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)").Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.Exec("DROP TABLE widgets").Error
+		},
+	})
+
+	up := commands.UpCmd()
+	up.SetArgs([]string{})
+	require.NoError(t, up.Execute())
+
+	// A dry run shows the target and its SQL without reverting it
+	var out bytes.Buffer
+	down := commands.DownCmd()
+	down.SetOut(&out)
+	down.SetArgs([]string{"--dry-run"})
+	require.NoError(t, down.Execute())
+	assert.Contains(t, out.String(), "Would revert migration: create_widgets (20240101000000)")
+	assert.Contains(t, out.String(), "DROP TABLE widgets")
+	assert.True(t, db.Migrator().HasTable("widgets"), "a dry run should not run the down")
+	var applied int64
+	require.NoError(t, db.Model(&migration.MigrationRecord{}).Count(&applied).Error)
+	assert.Equal(t, int64(1), applied, "a dry run should keep the migration record")
+
+	// Tests do not run in a terminal, so a destructive down needs --yes
+	down = commands.DownCmd()
+	down.SetArgs([]string{})
+	assert.Error(t, down.Execute())
+	assert.True(t, db.Migrator().HasTable("widgets"), "a refused down should not run")
+
+	down = commands.DownCmd()
+	down.SetArgs([]string{"--yes"})
+	require.NoError(t, down.Execute())
+	assert.False(t, db.Migrator().HasTable("widgets"))
+}
+
+func TestCommandErrors(t *testing.T) {
+	os.Setenv("TEST_MIGRATION_REGISTRY_ONLY", "1")
+	defer func() {
+		if err := os.Unsetenv("TEST_MIGRATION_REGISTRY_ONLY"); err != nil {
+			t.Errorf("failed to unset TEST_MIGRATION_REGISTRY_ONLY: %v", err)
+		}
+	}()
+
+	t.Run("no database url", func(t *testing.T) {
+		t.Setenv("DATABASE_URL", "")
+		commands.SetDB(nil)
+		err := commands.UpCmd().Execute()
+		assert.True(t, errors.Is(err, migration.ErrNoDatabaseURL), "got %v", err)
+	})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&migration.MigrationRecord{}))
+	commands.SetDB(db)
+	defer commands.SetDB(nil)
+
+	t.Run("no registry", func(t *testing.T) {
+		registry := migration.GlobalModelRegistry
+		migration.GlobalModelRegistry = nil
+		defer func() { migration.GlobalModelRegistry = registry }()
+
+		cmd := commands.GenerateCmd()
+		cmd.SetArgs([]string{"add_widgets"})
+		err := cmd.Execute()
+		assert.True(t, errors.Is(err, migration.ErrNoRegistry), "got %v", err)
+	})
+
+	t.Run("failed migration", func(t *testing.T) {
+		migration.ResetMigrations()
+		defer migration.ResetMigrations()
+		migration.RegisterMigration(&migration.Migration{
+			Version: "20240101000000",
+			Name:    "broken",
+			Up: func(db *gorm.DB) error {
+				return db.Exec("CREATE TABLE broken (").Error
+			},
+		})
+
+		cmd := commands.UpCmd()
+		cmd.SetArgs([]string{})
+		err := cmd.Execute()
+		var applyErr *migration.MigrationApplyError
+		require.True(t, errors.As(err, &applyErr), "got %v", err)
+		assert.Equal(t, "20240101000000", applyErr.Version)
+	})
+
+	t.Run("applied migration without a file", func(t *testing.T) {
+		migration.ResetMigrations()
+		require.NoError(t, db.Create(&migration.MigrationRecord{Version: "20240102000000", Name: "missing", AppliedAt: time.Now()}).Error)
+
+		cmd := commands.DownCmd()
+		cmd.SetArgs([]string{"--yes"})
+		err := cmd.Execute()
+		assert.True(t, errors.Is(err, migration.ErrDirtyState), "got %v", err)
+	})
+}
+
+func TestConfigFlag(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	commands.SetDB(db)
+	defer commands.SetDB(nil)
+
+	// migrations_path must stay inside the working directory
+	migrationsDir := "config_flag_migrations"
+	t.Cleanup(func() {
+		_ = os.RemoveAll(migrationsDir)
+	})
+
+	path := filepath.Join(t.TempDir(), "service.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("migrations_path: "+migrationsDir+"\n"), 0644))
+
+	root := &cobra.Command{Use: "gorm-migrate"}
+	commands.AddConfigFlag(root)
+	root.AddCommand(commands.InitCmd())
+	defer func() {
+		_ = commands.LoadConfig("")
+	}()
+
+	root.SetArgs([]string{"--config", path, "init"})
+	require.NoError(t, root.Execute())
+
+	assert.DirExists(t, migrationsDir, "init should use the directory from the config file")
+
+	root.SetArgs([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml"), "init"})
+	assert.Error(t, root.Execute(), "A missing config file should fail the command")
 }
 
 func TestDownCmd(t *testing.T) {
@@ -49,6 +328,7 @@ func TestDownCmd(t *testing.T) {
 
 	flags := cmd.Flags()
 	assert.NotNil(t, flags.Lookup("debug"))
+	assert.NotNil(t, flags.Lookup("only"))
 }
 
 func TestStatusCmd(t *testing.T) {
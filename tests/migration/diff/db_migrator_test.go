@@ -1,13 +1,16 @@
 package migration
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 
 	"github.com/beesaferoot/gorm-migrate/migration/diff"
 )
@@ -76,6 +79,29 @@ type TestMigratorGroup struct {
 	Description string
 }
 
+// indexSummaries describes indexes as "name(columns) option" for comparison
+func indexSummaries(indexes []*schema.Index) []string {
+	var summaries []string
+	for _, idx := range indexes {
+		var columns []string
+		for _, field := range idx.Fields {
+			columns = append(columns, field.DBName)
+		}
+		summaries = append(summaries, strings.TrimSpace(fmt.Sprintf("%s(%s) %s", idx.Name, strings.Join(columns, ","), idx.Option)))
+	}
+	return summaries
+}
+
+// foreignKeySummaries describes relationships as "name: column -> table.column"
+func foreignKeySummaries(relationships []*schema.Relationship) []string {
+	var summaries []string
+	for _, rel := range relationships {
+		ref := rel.References[0]
+		summaries = append(summaries, fmt.Sprintf("%s: %s -> %s.%s", rel.Name, ref.ForeignKey.DBName, rel.Schema.Table, ref.PrimaryKey.DBName))
+	}
+	return summaries
+}
+
 func TestSchemaMigrator_GetIndexes(t *testing.T) {
 	// Use a file-based SQLite database for testing
 	dbPath := "test_migrator_indexes.db"
@@ -94,62 +120,35 @@ func TestSchemaMigrator_GetIndexes(t *testing.T) {
 	t.Run("GetIndexes on Empty Table", func(t *testing.T) {
 		// Test on a table that doesn't exist
 		indexes, err := migrator.GetIndexes("non_existent_table")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		// but the function should handle it gracefully
-		if err != nil {
-			t.Logf("Expected error for non-existent table: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for non-existent table")
+		require.NoError(t, err)
+		assert.Empty(t, indexes, "Should return empty slice for non-existent table")
 	})
 
-	t.Run("GetIndexes on Table with Primary Key", func(t *testing.T) {
-		// Create a table with primary key
+	t.Run("GetIndexes on Table with Unique and Regular Indexes", func(t *testing.T) {
+		// The TestMigratorUser model has unique indexes on Name and Email and
+		// a regular index on Age; the primary key index is left out
 		err := db.AutoMigrate(&TestMigratorUser{})
 		require.NoError(t, err)
 
 		indexes, err := migrator.GetIndexes("test_migrator_users")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for SQLite")
-	})
-
-	t.Run("GetIndexes on Table with Unique Indexes", func(t *testing.T) {
-		// The TestMigratorUser model has unique indexes on Name and Email
-		// Note: SQLite implementation may not detect all indexes, so we test what we can
-		indexes, err := migrator.GetIndexes("test_migrator_users")
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"idx_test_migrator_users_age(age)",
+			"idx_test_migrator_users_deleted_at(deleted_at)",
+			"idx_test_migrator_users_email(email) UNIQUE",
+			"idx_test_migrator_users_name(name) UNIQUE",
+		}, indexSummaries(indexes))
 	})
 
-	t.Run("GetIndexes on Table with Regular Indexes", func(t *testing.T) {
-		// The TestMigratorUser model has a regular index on Age
-		// Note: SQLite implementation may not detect regular indexes, so we test what we can
-		indexes, err := migrator.GetIndexes("test_migrator_users")
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for SQLite")
-	})
+	t.Run("GetIndexes on Composite and Partial Indexes", func(t *testing.T) {
+		require.NoError(t, db.Exec("CREATE TABLE test_events (id integer PRIMARY KEY, kind text, at datetime, deleted_at datetime)").Error)
+		require.NoError(t, db.Exec("CREATE INDEX idx_test_events_kind_at ON test_events (kind, at) WHERE deleted_at IS NULL").Error)
 
-	t.Run("GetIndexes Fallback Implementation", func(t *testing.T) {
-		// Create a simple table without complex indexes
-		err := db.AutoMigrate(&TestMigratorCategory{})
+		indexes, err := migrator.GetIndexes("test_events")
 		require.NoError(t, err)
-
-		indexes, err := migrator.GetIndexes("test_migrator_categories")
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		assert.Equal(t, []string{"idx_test_events_kind_at(kind,at)"}, indexSummaries(indexes))
+		assert.Equal(t, 2, indexes[0].Fields[1].Priority)
+		assert.Equal(t, "deleted_at IS NULL", indexes[0].Where)
 	})
 }
 
@@ -171,12 +170,8 @@ func TestSchemaMigrator_GetRelationships(t *testing.T) {
 	t.Run("GetRelationships on Empty Table", func(t *testing.T) {
 		// Test on a table that doesn't exist
 		relationships, err := migrator.GetRelationships("non_existent_table")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for non-existent table: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(relationships) == 0 || err != nil, "Should return empty slice or error for non-existent table")
+		require.NoError(t, err)
+		assert.Empty(t, relationships, "Should return empty slice for non-existent table")
 	})
 
 	t.Run("GetRelationships on Table with Foreign Keys", func(t *testing.T) {
@@ -185,12 +180,14 @@ func TestSchemaMigrator_GetRelationships(t *testing.T) {
 		require.NoError(t, err)
 
 		relationships, err := migrator.GetRelationships("test_migrator_products")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(relationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"fk_test_migrator_products_category: category_id -> test_migrator_categories.id",
+		}, foreignKeySummaries(relationships))
+		assert.Equal(t, "test_migrator_products", relationships[0].Field.Schema.Table)
+		onDelete, onUpdate := diff.ForeignKeyActions(relationships[0])
+		assert.Equal(t, "NO ACTION", onDelete)
+		assert.Equal(t, "NO ACTION", onUpdate)
 	})
 
 	t.Run("GetRelationships on Table with Multiple Foreign Keys", func(t *testing.T) {
@@ -199,16 +196,26 @@ func TestSchemaMigrator_GetRelationships(t *testing.T) {
 		require.NoError(t, err)
 
 		relationships, err := migrator.GetRelationships("test_migrator_orders")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(relationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"fk_test_migrator_orders_user: user_id -> test_migrator_users.id",
+			"fk_test_migrator_orders_product: product_id -> test_migrator_products.id",
+		}, foreignKeySummaries(relationships))
+	})
+
+	t.Run("GetRelationships with Actions", func(t *testing.T) {
+		require.NoError(t, db.Exec("CREATE TABLE test_reviews (id integer PRIMARY KEY, product_id integer REFERENCES test_migrator_products ON DELETE CASCADE)").Error)
+
+		relationships, err := migrator.GetRelationships("test_reviews")
+		require.NoError(t, err)
+		// An unnamed constraint referencing the primary key
+		assert.Equal(t, []string{": product_id -> test_migrator_products.id"}, foreignKeySummaries(relationships))
+		onDelete, _ := diff.ForeignKeyActions(relationships[0])
+		assert.Equal(t, "CASCADE", onDelete)
 	})
 
-	t.Run("GetRelationships Fallback Implementation", func(t *testing.T) {
-		// Test fallback implementation by creating a table with _id columns
+	t.Run("GetRelationships on Table without Foreign Keys", func(t *testing.T) {
+		// _id columns without a constraint are not foreign keys
 		type TestSimple struct {
 			gorm.Model
 			UserID  uint
@@ -220,32 +227,8 @@ func TestSchemaMigrator_GetRelationships(t *testing.T) {
 		require.NoError(t, err)
 
 		relationships, err := migrator.GetRelationships("test_simples")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(relationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
-	})
-
-	t.Run("GetRelationships on Table without Foreign Keys", func(t *testing.T) {
-		// Test on a table without foreign keys
-		type TestNoFK struct {
-			gorm.Model
-			Name   string
-			Status string
-		}
-
-		err := db.AutoMigrate(&TestNoFK{})
 		require.NoError(t, err)
-
-		relationships, err := migrator.GetRelationships("test_no_fks")
-		// SQLite doesn't support the PostgreSQL-specific query, so we expect an error
-		if err != nil {
-			t.Logf("Expected error for SQLite: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(relationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		assert.Empty(t, relationships)
 	})
 }
 
@@ -277,30 +260,29 @@ func TestSchemaMigrator_Integration(t *testing.T) {
 		assert.Contains(t, tables, "test_migrator_products", "test_migrator_products table should be found")
 		assert.Contains(t, tables, "test_migrator_orders", "test_migrator_orders table should be found")
 
-		// Test GetIndexes for each table (SQLite may not support this)
+		// Every table has at least the soft delete index
 		for _, tableName := range []string{"test_migrator_users", "test_migrator_categories", "test_migrator_products", "test_migrator_orders"} {
 			indexes, err := migrator.GetIndexes(tableName)
-			if err != nil {
-				t.Logf("Expected error for SQLite GetIndexes on %s: %v", tableName, err)
-			}
-			// For SQLite, we expect an empty result or an error
-			assert.True(t, len(indexes) == 0 || err != nil, "Should return empty slice or error for SQLite")
+			require.NoError(t, err)
+			assert.Contains(t, indexSummaries(indexes), fmt.Sprintf("idx_%s_deleted_at(deleted_at)", tableName))
 		}
 
-		// Test GetRelationships for tables with foreign keys (SQLite may not support this)
 		productRelationships, err := migrator.GetRelationships("test_migrator_products")
-		if err != nil {
-			t.Logf("Expected error for SQLite GetRelationships on test_migrator_products: %v", err)
-		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(productRelationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
+		require.NoError(t, err)
+		assert.Len(t, productRelationships, 1)
 
 		orderRelationships, err := migrator.GetRelationships("test_migrator_orders")
-		if err != nil {
-			t.Logf("Expected error for SQLite GetRelationships on test_migrator_orders: %v", err)
+		require.NoError(t, err)
+		assert.Len(t, orderRelationships, 2)
+
+		// The current schema reflects the introspected foreign keys
+		currentSchema, err := diff.NewSchemaComparer(db).GetCurrentSchema()
+		require.NoError(t, err)
+		for _, s := range currentSchema {
+			if s.Table == "test_migrator_orders" {
+				assert.Len(t, s.Relationships.BelongsTo, 2)
+			}
 		}
-		// For SQLite, we expect an empty result or an error
-		assert.True(t, len(orderRelationships) == 0 || err != nil, "Should return empty slice or error for SQLite")
 	})
 
 	t.Run("Error Handling", func(t *testing.T) {
@@ -1,15 +1,22 @@
 package migration
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 
+	"github.com/beesaferoot/gorm-migrate/migration"
 	"github.com/beesaferoot/gorm-migrate/migration/diff"
+	"github.com/beesaferoot/gorm-migrate/migration/file"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
 )
 
 // PostgreSQL-specific test models for index and relationship testing
@@ -745,3 +752,758 @@ func TestPostgreSQLSchemaMigrator_GetRelationships(t *testing.T) {
 // 		assert.True(t, enhancedProductTableFound, "Should detect new enhanced product table")
 // 	})
 // }
+
+// TestPostgreSQLCompositeIndexOrder is a test model whose composite index
+// key order differs from the field declaration order
+type TestPostgreSQLCompositeIndexOrder struct {
+	gorm.Model
+	TenantID uint   `gorm:"index:idx_pg_composite_order,priority:2"`
+	Slug     string `gorm:"index:idx_pg_composite_order,priority:1"`
+}
+
+func TestPostgreSQLCompositeIndexOrderStable(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLCompositeIndexOrder{}))
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLCompositeIndexOrder{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLCompositeIndexOrder{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLCompositeIndexOrder{})
+	require.NoError(t, err)
+
+	// Only compare the table under test so unrelated tables don't show up as drops
+	tableName := "test_postgre_sql_composite_index_orders"
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{tableName: currentSchema[tableName]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+
+	for _, table := range schemaDiff.TablesToModify {
+		for _, idx := range table.IndexesToModify {
+			assert.NotEqual(t, "idx_pg_composite_order", idx.Name, "Composite index should not be flagged as modified")
+		}
+		for _, idx := range table.IndexesToAdd {
+			assert.NotEqual(t, "idx_pg_composite_order", idx.Name, "Composite index should not be flagged as added")
+		}
+	}
+}
+
+// TestPostgreSQLSerialPK is a test model with an explicit serial primary key
+type TestPostgreSQLSerialPK struct {
+	ID   uint `gorm:"primaryKey;autoIncrement"`
+	Name string
+}
+
+func TestPostgreSQLSerialPKRepeatedGenerate(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLSerialPK{}))
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLSerialPK{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLSerialPK{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	tableName := "test_postgre_sql_serial_pks"
+
+	// Each run re-reads the database, as successive generate invocations would
+	for i := 0; i < 2; i++ {
+		currentSchema, err := comparer.GetCurrentSchema()
+		require.NoError(t, err)
+		targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLSerialPK{})
+		require.NoError(t, err)
+
+		schemaDiff, err := comparer.CompareSchemas(
+			map[string]*schema.Schema{tableName: currentSchema[tableName]},
+			targetSchema,
+		)
+		require.NoError(t, err)
+
+		for _, table := range schemaDiff.TablesToModify {
+			for _, field := range table.FieldsToModify {
+				assert.NotEqual(t, "id", field.DBName, "Serial primary key should not be flagged as modified (run %d)", i+1)
+			}
+		}
+	}
+}
+
+// TestPostgreSQLIdentityPK is TestPostgreSQLSerialPK with an identity key
+type TestPostgreSQLIdentityPK struct {
+	ID   uint `gorm:"primaryKey;autoIncrement;identity"`
+	Name string
+}
+
+func (TestPostgreSQLIdentityPK) TableName() string {
+	return "test_postgre_sql_serial_pks"
+}
+
+func TestPostgreSQLSerialToIdentityPK(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLSerialPK{}))
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLSerialPK{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLSerialPK{})
+	}()
+	require.NoError(t, db.Create(&TestPostgreSQLSerialPK{Name: "existing"}).Error)
+
+	comparer := diff.NewSchemaComparer(db)
+	tableName := "test_postgre_sql_serial_pks"
+	compare := func() *diff.SchemaDiff {
+		currentSchema, err := comparer.GetCurrentSchema()
+		require.NoError(t, err)
+		targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLIdentityPK{})
+		require.NoError(t, err)
+		schemaDiff, err := comparer.CompareSchemas(
+			map[string]*schema.Schema{tableName: currentSchema[tableName]},
+			targetSchema,
+		)
+		require.NoError(t, err)
+		return schemaDiff
+	}
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(compare())
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+
+	// Default and sequence go first, then the type, then the identity
+	dropDefault := strings.Index(upSQL, "DROP DEFAULT;")
+	alterType := strings.Index(upSQL, "TYPE bigint;")
+	addIdentity := strings.Index(upSQL, "ADD GENERATED BY DEFAULT AS IDENTITY;")
+	require.NotEqual(t, -1, dropDefault)
+	assert.Less(t, dropDefault, alterType)
+	assert.Less(t, alterType, addIdentity)
+
+	for _, statement := range strings.Split(upSQL, "\n") {
+		require.NoError(t, db.Exec(statement).Error, statement)
+	}
+
+	// New rows continue after the existing one and the key is now up to date
+	row := TestPostgreSQLIdentityPK{Name: "new"}
+	require.NoError(t, db.Create(&row).Error)
+	assert.Equal(t, uint(2), row.ID)
+	for _, table := range compare().TablesToModify {
+		for _, field := range table.FieldsToModify {
+			assert.NotEqual(t, "id", field.DBName, "Identity primary key should not be flagged as modified")
+		}
+	}
+}
+
+// TestPostgreSQLFKParent is referenced by TestPostgreSQLFKChild
+type TestPostgreSQLFKParent struct {
+	gorm.Model
+	Name string
+}
+
+// TestPostgreSQLFKChild belongs to TestPostgreSQLFKParent
+type TestPostgreSQLFKChild struct {
+	gorm.Model
+	ParentID uint
+	Parent   TestPostgreSQLFKParent `gorm:"foreignKey:ParentID"`
+}
+
+// TestPostgreSQLFKChildWithoutFK is TestPostgreSQLFKChild with the relationship removed
+type TestPostgreSQLFKChildWithoutFK struct {
+	gorm.Model
+	ParentID uint
+}
+
+func (TestPostgreSQLFKChildWithoutFK) TableName() string {
+	return "test_postgre_sql_fk_children"
+}
+
+func TestPostgreSQLRemovedForeignKey(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLFKChild{}, &TestPostgreSQLFKParent{}))
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLFKParent{}, &TestPostgreSQLFKChild{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLFKChild{}, &TestPostgreSQLFKParent{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLFKChildWithoutFK{})
+	require.NoError(t, err)
+
+	tableName := "test_postgre_sql_fk_children"
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{tableName: currentSchema[tableName]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+
+	table := schemaDiff.TablesToModify[0]
+	require.Len(t, table.ForeignKeysToDrop, 1, "Should detect the removed foreign key")
+	constraintName := table.ForeignKeysToDrop[0].Name
+	assert.NotEmpty(t, constraintName)
+
+	dir := t.TempDir()
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(schemaDiff)
+	require.NoError(t, gen.CreateMigration("drop_parent_fk"))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	// The generator wraps long statements, so compare with whitespace collapsed
+	flattened := strings.Join(strings.Fields(string(content)), " ")
+	assert.Contains(t, flattened, fmt.Sprintf("DROP CONSTRAINT IF EXISTS %s;", constraintName))
+}
+
+func TestPostgreSQLNotValidForeignKey(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLFKChild{}, &TestPostgreSQLFKParent{}))
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLFKParent{}, &TestPostgreSQLFKChildWithoutFK{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLFKChild{}, &TestPostgreSQLFKParent{})
+	}()
+	parent := TestPostgreSQLFKParent{Name: "parent"}
+	require.NoError(t, db.Create(&parent).Error)
+	require.NoError(t, db.Create(&TestPostgreSQLFKChildWithoutFK{ParentID: parent.ID}).Error)
+
+	comparer := diff.NewSchemaComparer(db)
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLFKParent{}, &TestPostgreSQLFKChild{})
+	require.NoError(t, err)
+
+	parentTable, childTable := "test_postgre_sql_fk_parents", "test_postgre_sql_fk_children"
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{parentTable: currentSchema[parentTable], childTable: currentSchema[childTable]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetNotValidFKs(true)
+	gen.SetSchemaDiff(schemaDiff)
+	statements, err := gen.UpStatements()
+	require.NoError(t, err)
+
+	var add, validate int
+	for i, statement := range statements {
+		if strings.Contains(statement, "ADD CONSTRAINT fk_test_postgre_sql_fk_children_parent_id_fkey") {
+			assert.True(t, strings.HasSuffix(statement, "NOT VALID;"), statement)
+			add = i + 1
+		}
+		if strings.Contains(statement, "VALIDATE CONSTRAINT fk_test_postgre_sql_fk_children_parent_id_fkey") {
+			validate = i + 1
+		}
+	}
+	require.NotZero(t, add, "the foreign key should be added")
+	require.Greater(t, validate, add, "the foreign key should be validated after it is added")
+
+	// Each statement commits on its own, and the constraint ends up validated
+	for _, statement := range statements {
+		require.NoError(t, db.Exec(statement).Error, statement)
+	}
+	var validated bool
+	require.NoError(t, db.Raw(`SELECT convalidated FROM pg_constraint WHERE conname = ?`, "fk_test_postgre_sql_fk_children_parent_id_fkey").Scan(&validated).Error)
+	assert.True(t, validated)
+}
+
+// TestPostgreSQLOrderTotal is a read model backed by a materialized view
+type TestPostgreSQLOrderTotal struct {
+	UserID uint
+	Total  int
+}
+
+type TestPostgreSQLViewOrder struct {
+	ID     uint `gorm:"primaryKey"`
+	UserID uint
+	Amount int
+}
+
+func TestPostgreSQLMaterializedViewsAreNotTables(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Exec(`DROP MATERIALIZED VIEW IF EXISTS test_postgre_sql_order_totals`).Error)
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLViewOrder{}))
+	defer func() {
+		_ = db.Exec(`DROP MATERIALIZED VIEW IF EXISTS test_postgre_sql_order_totals`).Error
+		_ = db.Migrator().DropTable(&TestPostgreSQLViewOrder{})
+	}()
+
+	viewSQL := "SELECT user_id, SUM(amount) AS total FROM test_postgre_sql_view_orders GROUP BY user_id"
+	comparer := diff.NewSchemaComparer(db)
+	comparer.SetMaterializedViews([]diff.MaterializedView{{Name: "test_postgre_sql_order_totals", SQL: viewSQL}})
+	schemaDiff, err := comparer.Compare(&TestPostgreSQLViewOrder{}, &TestPostgreSQLOrderTotal{})
+	require.NoError(t, err)
+
+	require.Len(t, schemaDiff.ViewsToCreate, 1)
+	assert.Equal(t, "test_postgre_sql_order_totals", schemaDiff.ViewsToCreate[0].Name)
+	require.Len(t, schemaDiff.TablesToCreate, 1)
+	assert.Equal(t, "test_postgre_sql_view_orders", schemaDiff.TablesToCreate[0].Schema.Table)
+
+	// Once the view exists it is not created again
+	require.NoError(t, db.AutoMigrate(&TestPostgreSQLViewOrder{}))
+	require.NoError(t, db.Exec("CREATE MATERIALIZED VIEW test_postgre_sql_order_totals AS "+viewSQL).Error)
+	schemaDiff, err = comparer.Compare(&TestPostgreSQLViewOrder{}, &TestPostgreSQLOrderTotal{})
+	require.NoError(t, err)
+	assert.Empty(t, schemaDiff.ViewsToCreate)
+	assert.Empty(t, schemaDiff.TablesToCreate)
+}
+
+func TestPostgreSQLEnumValueAdded(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Exec(`DROP TYPE IF EXISTS test_mood`).Error)
+	require.NoError(t, db.Exec(`CREATE TYPE test_mood AS ENUM ('happy', 'sad')`).Error)
+	defer func() {
+		_ = db.Exec(`DROP TYPE IF EXISTS test_mood`).Error
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	comparer.SetEnums([]diff.Enum{{Name: "test_mood", Values: []string{"happy", "sad", "ok"}}})
+	schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.NoError(t, err)
+	require.Equal(t, []diff.EnumChange{{Name: "test_mood", Added: []string{"ok"}}}, schemaDiff.EnumsToAlter)
+	assert.Empty(t, schemaDiff.EnumsToCreate)
+
+	dir := t.TempDir()
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(schemaDiff)
+	require.NoError(t, gen.CreateMigration("add_mood_ok"))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `ALTER TYPE "test_mood" ADD VALUE IF NOT EXISTS 'ok';`)
+	assert.Contains(t, string(content), "NonTransactional: true,")
+
+	// ADD VALUE runs outside a transaction and the enum is then up to date
+	require.NoError(t, db.Exec(`ALTER TYPE "test_mood" ADD VALUE IF NOT EXISTS 'ok'`).Error)
+	schemaDiff, err = comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.NoError(t, err)
+	assert.Empty(t, schemaDiff.EnumsToAlter)
+
+	// A value only removed from the model cannot be dropped, so it is not a change
+	comparer.SetEnums([]diff.Enum{{Name: "test_mood", Values: []string{"happy", "ok"}}})
+	schemaDiff, err = comparer.CompareSchemas(map[string]*schema.Schema{}, map[string]*schema.Schema{})
+	require.NoError(t, err)
+	assert.Empty(t, schemaDiff.EnumsToAlter)
+}
+
+// TestPostgreSQLUserRole is a join table keyed by two columns
+type TestPostgreSQLUserRole struct {
+	UserID uint `gorm:"primaryKey"`
+	RoleID uint `gorm:"primaryKey"`
+}
+
+func TestPostgreSQLCompositePrimaryKey(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLUserRole{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLUserRole{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLUserRole{})
+	require.NoError(t, err)
+
+	schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, targetSchema)
+	require.NoError(t, err)
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.Contains(t, upSQL, `PRIMARY KEY ("user_id", "role_id")`)
+	require.NoError(t, db.Exec(upSQL).Error)
+
+	// The created table reads back as the model, so there is nothing to generate
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	tableName := "test_postgre_sql_user_roles"
+	require.Contains(t, currentSchema, tableName)
+	schemaDiff, err = comparer.CompareSchemas(
+		map[string]*schema.Schema{"TestPostgreSQLUserRole": currentSchema[tableName]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	assert.Empty(t, schemaDiff.TablesToModify)
+}
+
+func TestPostgreSQLSavepointPerStatement(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Exec(`DROP TABLE IF EXISTS test_savepoint_items`).Error)
+	defer func() {
+		_ = db.Exec(`DROP TABLE IF EXISTS test_savepoint_items`).Error
+	}()
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	content := "package migrations\n\n" +
+		"func init() {\n" +
+		"\tmigration.RegisterMigration(&migration.Migration{\n" +
+		"\t\tUp: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`CREATE TABLE test_savepoint_items (id integer PRIMARY KEY);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\tif err := db.Exec(`INSERT INTO test_savepoint_items (id) VALUES (1), (1);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t})\n" +
+		"}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_add_items.go"), []byte(content), 0644))
+
+	loader := file.NewMigrationLoader(dir, nil)
+	loader.SetSavepoints(true)
+	migrations, err := loader.LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+
+	tx := db.Begin()
+	require.NoError(t, tx.Error)
+	defer tx.Rollback()
+
+	// The duplicate insert fails and is rolled back to its savepoint, and its
+	// error is returned; the transaction is not aborted, and the table created
+	// before it remains
+	require.ErrorContains(t, migrations[0].Up(tx), "statement 2 of Up")
+	var count int64
+	require.NoError(t, tx.Raw(`SELECT count(*) FROM test_savepoint_items`).Scan(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+// TestPostgreSQLHintAccount indexes email under a new name
+type TestPostgreSQLHintAccount struct {
+	ID    uint   `gorm:"primaryKey"`
+	Email string `gorm:"index:idx_hint_accounts_email"`
+}
+
+func TestPostgreSQLIndexRenameHint(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLHintAccount{}))
+	require.NoError(t, db.Exec(`CREATE TABLE test_postgre_sql_hint_accounts (id bigserial PRIMARY KEY, email text)`).Error)
+	require.NoError(t, db.Exec(`CREATE INDEX idx_accounts_email ON test_postgre_sql_hint_accounts (email)`).Error)
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLHintAccount{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	comparer.SetIndexRenames(map[string]string{"idx_accounts_email": "idx_hint_accounts_email"})
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLHintAccount{})
+	require.NoError(t, err)
+
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{"TestPostgreSQLHintAccount": currentSchema["test_postgre_sql_hint_accounts"]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	modified := schemaDiff.TablesToModify[0]
+	assert.Equal(t, []diff.IndexRename{{OldName: "idx_accounts_email", NewName: "idx_hint_accounts_email"}}, modified.IndexesToRename)
+	assert.Empty(t, modified.IndexesToAdd)
+	assert.Empty(t, modified.IndexesToDrop)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.Contains(t, upSQL, "ALTER INDEX \"idx_accounts_email\" RENAME TO \"idx_hint_accounts_email\";")
+}
+
+// TestPostgreSQLIndexedAccount indexes a column of an existing table
+type TestPostgreSQLIndexedAccount struct {
+	ID    uint   `gorm:"primaryKey"`
+	Email string `gorm:"index"`
+}
+
+func TestPostgreSQLIndexAddedToUnchangedTable(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLIndexedAccount{}))
+	require.NoError(t, db.Exec(`CREATE TABLE test_postgre_sql_indexed_accounts (id bigserial PRIMARY KEY, email text)`).Error)
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLIndexedAccount{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLIndexedAccount{})
+	require.NoError(t, err)
+
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{"TestPostgreSQLIndexedAccount": currentSchema["test_postgre_sql_indexed_accounts"]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	modified := schemaDiff.TablesToModify[0]
+	assert.Empty(t, modified.FieldsToAdd)
+	assert.Empty(t, modified.FieldsToDrop)
+	require.Len(t, modified.IndexesToAdd, 1)
+	assert.Equal(t, "idx_test_postgre_sql_indexed_accounts_email", modified.IndexesToAdd[0].Name)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.Contains(t, upSQL, `CREATE INDEX "idx_test_postgre_sql_indexed_accounts_email" ON "test_postgre_sql_indexed_accounts" ("email");`)
+}
+
+// TestPostgreSQLCheckedMember relaxes the age check to allow zero
+type TestPostgreSQLCheckedMember struct {
+	ID  uint `gorm:"primaryKey"`
+	Age int  `gorm:"check:age >= 0"`
+}
+
+func TestPostgreSQLChangedCheckExpression(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLCheckedMember{}))
+	require.NoError(t, db.Exec(`CREATE TABLE test_postgre_sql_checked_members (id bigserial PRIMARY KEY, age bigint,
+		CONSTRAINT chk_test_postgre_sql_checked_members_age CHECK (age > 0))`).Error)
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLCheckedMember{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLCheckedMember{})
+	require.NoError(t, err)
+
+	schemaDiff, err := comparer.CompareSchemas(
+		map[string]*schema.Schema{"TestPostgreSQLCheckedMember": currentSchema["test_postgre_sql_checked_members"]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	modified := schemaDiff.TablesToModify[0]
+	require.Len(t, modified.ChecksToDrop, 1)
+	require.Len(t, modified.ChecksToAdd, 1)
+	assert.Equal(t, "age > 0", modified.ChecksToDrop[0].Constraint)
+	assert.Equal(t, "age >= 0", modified.ChecksToAdd[0].Constraint)
+
+	// Applying the change leaves nothing to generate
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(upSQL).Error)
+	currentSchema, err = comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	schemaDiff, err = comparer.CompareSchemas(
+		map[string]*schema.Schema{"TestPostgreSQLCheckedMember": currentSchema["test_postgre_sql_checked_members"]},
+		targetSchema,
+	)
+	require.NoError(t, err)
+	for _, table := range schemaDiff.TablesToModify {
+		assert.Empty(t, table.ChecksToAdd)
+		assert.Empty(t, table.ChecksToDrop)
+	}
+}
+
+// TestPostgreSQLBooking declares a unique constraint rather than a unique index
+type TestPostgreSQLBooking struct {
+	ID     uint   `gorm:"primaryKey"`
+	RoomID uint   `gorm:"uniqueConstraint:uq_test_postgre_sql_bookings_room_night"`
+	Night  string `gorm:"uniqueConstraint:uq_test_postgre_sql_bookings_room_night"`
+}
+
+func TestPostgreSQLUniqueConstraint(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLBooking{}))
+	require.NoError(t, db.Exec(`CREATE TABLE test_postgre_sql_bookings (id bigserial PRIMARY KEY, room_id bigint, night text)`).Error)
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLBooking{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	currentSchema, err := comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLBooking{})
+	require.NoError(t, err)
+
+	compare := func() *diff.SchemaDiff {
+		schemaDiff, err := comparer.CompareSchemas(
+			map[string]*schema.Schema{"TestPostgreSQLBooking": currentSchema["test_postgre_sql_bookings"]},
+			targetSchema,
+		)
+		require.NoError(t, err)
+		return schemaDiff
+	}
+	schemaDiff := compare()
+	require.Len(t, schemaDiff.TablesToModify, 1)
+	assert.Equal(t, []diff.UniqueConstraint{
+		{Name: "uq_test_postgre_sql_bookings_room_night", Columns: []string{"room_id", "night"}},
+	}, schemaDiff.TablesToModify[0].UniquesToAdd)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.Contains(t, upSQL, `ADD CONSTRAINT "uq_test_postgre_sql_bookings_room_night" UNIQUE ("room_id", "night")`)
+	require.NoError(t, db.Exec(upSQL).Error)
+
+	// The constraint and its backing index leave nothing to generate
+	currentSchema, err = comparer.GetCurrentSchema()
+	require.NoError(t, err)
+	for _, table := range compare().TablesToModify {
+		assert.Empty(t, table.UniquesToAdd)
+		assert.Empty(t, table.UniquesToDrop)
+		assert.Empty(t, table.IndexesToDrop)
+	}
+}
+
+// TestPostgreSQLTicket numbers tickets from 1000 in steps of 5
+type TestPostgreSQLTicket struct {
+	ID   uint `gorm:"primaryKey;autoIncrementIncrement:5;autoIncrementStart:1000"`
+	Code string
+}
+
+func TestPostgreSQLSequenceStartAndIncrement(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLTicket{}))
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLTicket{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLTicket{})
+	require.NoError(t, err)
+	schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, targetSchema)
+	require.NoError(t, err)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, _, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.Contains(t, upSQL, "ALTER SEQUENCE \"test_postgre_sql_tickets_id_seq\" INCREMENT BY 5 RESTART WITH 1000;")
+	require.NoError(t, db.Exec(upSQL).Error)
+
+	first, second := TestPostgreSQLTicket{Code: "a"}, TestPostgreSQLTicket{Code: "b"}
+	require.NoError(t, db.Create(&first).Error)
+	require.NoError(t, db.Create(&second).Error)
+	assert.Equal(t, uint(1000), first.ID)
+	assert.Equal(t, uint(1005), second.ID)
+}
+
+// TestPostgreSQLReading stores a reading that used to be a whole number
+type TestPostgreSQLReading struct {
+	ID    uint `gorm:"primaryKey"`
+	Value float64
+}
+
+func TestPostgreSQLModifiedColumnRoundTrip(t *testing.T) {
+	db := getPostgreSQLDB(t)
+	if db == nil {
+		return
+	}
+
+	require.NoError(t, db.Migrator().DropTable(&TestPostgreSQLReading{}))
+	require.NoError(t, db.Exec(`CREATE TABLE test_postgre_sql_readings (id bigserial PRIMARY KEY, value integer NOT NULL DEFAULT 1)`).Error)
+	defer func() {
+		_ = db.Migrator().DropTable(&TestPostgreSQLReading{})
+	}()
+
+	comparer := diff.NewSchemaComparer(db)
+	targetSchema, err := comparer.GetModelSchemas(&TestPostgreSQLReading{})
+	require.NoError(t, err)
+	compare := func() *diff.SchemaDiff {
+		currentSchema, err := comparer.GetCurrentSchema()
+		require.NoError(t, err)
+		schemaDiff, err := comparer.CompareSchemas(
+			map[string]*schema.Schema{"TestPostgreSQLReading": currentSchema["test_postgre_sql_readings"]},
+			targetSchema,
+		)
+		require.NoError(t, err)
+		return schemaDiff
+	}
+	schemaDiff := compare()
+	require.Len(t, schemaDiff.TablesToModify, 1)
+
+	gen := generator.NewGenerator(t.TempDir())
+	gen.SetSchemaDiff(schemaDiff)
+	upSQL, downSQL, err := gen.GenerateSQL()
+	require.NoError(t, err)
+	assert.NotContains(t, downSQL, "TODO")
+	require.NoError(t, db.Exec(upSQL).Error)
+	for _, table := range compare().TablesToModify {
+		assert.Empty(t, table.FieldsToModify)
+	}
+
+	// Down brings back the integer column with its constraint and default
+	require.NoError(t, db.Exec(downSQL).Error)
+	var column struct {
+		DataType      string
+		IsNullable    string
+		ColumnDefault string
+	}
+	require.NoError(t, db.Raw(`SELECT data_type, is_nullable, column_default FROM information_schema.columns
+		WHERE table_name = 'test_postgre_sql_readings' AND column_name = 'value'`).Scan(&column).Error)
+	assert.Equal(t, "integer", column.DataType)
+	assert.Equal(t, "NO", column.IsNullable)
+	assert.Equal(t, "1", column.ColumnDefault)
+}
@@ -110,6 +110,29 @@ type TestBrand struct {
 	Description string
 }
 
+// TestMember is a test model combining a unique index and a check on one field
+type TestMember struct {
+	gorm.Model
+	Name string
+	Age  int `gorm:"uniqueIndex;check:age > 0"`
+}
+
+// TestAuditEntry is a test model carrying a table comment
+type TestAuditEntry struct {
+	ID      uint `gorm:"primaryKey"`
+	Message string
+}
+
+func (TestAuditEntry) TableComment() string { return "Append-only audit trail" }
+
+// TestLedgerEntry is a test model with columns maintained by triggers
+type TestLedgerEntry struct {
+	ID       uint `gorm:"primaryKey"`
+	Amount   int
+	Balance  int    `gorm:"->;-:migration"`
+	SearchTS string `migrate:"-"`
+}
+
 // createTestDB creates a test database for unit tests
 func createTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
@@ -266,6 +289,28 @@ func TestSchemaComparerUnit(t *testing.T) {
 		assert.True(t, tableDiff.IsEmpty(), "Primary key fields should be normalized correctly")
 	})
 
+	t.Run("Serial Default Matches AutoIncrement Primary Key", func(t *testing.T) {
+		// A serial column introspects with a nextval() default, an identity
+		// column with no default; the model only declares autoIncrement
+		currentSchema := createTestSchema("users", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "int8", PrimaryKey: true, DefaultValue: "nextval('users_id_seq'::regclass)"},
+		})
+		identitySchema := createTestSchema("users", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "int8", PrimaryKey: true, AutoIncrement: true},
+		})
+
+		targetSchema := createTestSchema("users", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true, DefaultValue: "0"},
+		})
+
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		serialDiff := comparer.CompareTable(currentSchema, targetSchema)
+		identityDiff := comparer.CompareTable(identitySchema, targetSchema)
+
+		assert.True(t, serialDiff.IsEmpty(), "Serial column should match an autoIncrement primary key")
+		assert.True(t, identityDiff.IsEmpty(), "Identity column should match an autoIncrement primary key")
+	})
+
 	t.Run("Index Changes Detection", func(t *testing.T) {
 		// Current schema with basic fields
 		currentSchema := createTestSchema("users", []*schema.Field{
@@ -344,6 +389,39 @@ func TestSchemaComparerUnit(t *testing.T) {
 		assert.True(t, groupIDFound, "Should detect group_id foreign key field")
 	})
 
+	t.Run("Removed Foreign Key Detection", func(t *testing.T) {
+		// Current schema as introspected: products.category_id references categories.id
+		currentSchema := createTestSchema("products", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+			{Name: "category_id", DBName: "category_id", DataType: "uint"},
+		})
+		currentSchema.Relationships.BelongsTo = []*schema.Relationship{
+			{
+				Name:   "fk_products_category",
+				Type:   schema.BelongsTo,
+				Field:  &schema.Field{DBName: "category_id", Schema: currentSchema},
+				Schema: &schema.Schema{Table: "categories"},
+				References: []*schema.Reference{
+					{ForeignKey: &schema.Field{DBName: "category_id"}, PrimaryKey: &schema.Field{DBName: "id"}},
+				},
+			},
+		}
+
+		// Target schema keeps the column but no longer declares the relationship
+		targetSchema := createTestSchema("products", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+			{Name: "category_id", DBName: "category_id", DataType: "uint"},
+		})
+
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+
+		require.Len(t, tableDiff.ForeignKeysToDrop, 1, "Should detect the removed foreign key")
+		assert.Equal(t, "fk_products_category", tableDiff.ForeignKeysToDrop[0].Name)
+		assert.Empty(t, tableDiff.ForeignKeysToAdd)
+		assert.Empty(t, tableDiff.FieldsToDrop)
+	})
+
 	t.Run("Complex Index and Foreign Key Changes", func(t *testing.T) {
 		// Current schema with basic product
 		currentSchema := createTestSchema("products", []*schema.Field{
@@ -411,6 +489,32 @@ func TestSchemaDiffUnit(t *testing.T) {
 		assert.Empty(t, schemaDiff.TablesToDrop)
 	})
 
+	t.Run("Unique Index And Check On Same Field", func(t *testing.T) {
+		db := createTestDB(t)
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(&TestMember{}))
+
+		targetSchema := map[string]*schema.Schema{stmt.Schema.Table: stmt.Schema}
+
+		comparer := diff.NewSchemaComparer(db)
+		schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, targetSchema)
+		require.NoError(t, err)
+		require.Len(t, schemaDiff.TablesToCreate, 1)
+
+		table := schemaDiff.TablesToCreate[0]
+		require.Len(t, table.ChecksToAdd, 1, "Should keep the check constraint")
+		assert.Equal(t, "chk_test_members_age", table.ChecksToAdd[0].Name)
+		assert.Equal(t, "age > 0", table.ChecksToAdd[0].Constraint)
+
+		var uniqueFound bool
+		for _, idx := range table.IndexesToAdd {
+			if idx.Class == "UNIQUE" && len(idx.Fields) == 1 && idx.Fields[0].DBName == "age" {
+				uniqueFound = true
+			}
+		}
+		assert.True(t, uniqueFound, "Should keep the unique index on age")
+	})
+
 	t.Run("Table Drop Detection", func(t *testing.T) {
 		// Current schema has extra table
 		currentSchema := map[string]*schema.Schema{
@@ -433,6 +537,13 @@ func TestSchemaDiffUnit(t *testing.T) {
 		schemaDiff, err := comparer.CompareSchemas(currentSchema, targetSchema)
 		require.NoError(t, err)
 
+		// Unmanaged tables are left alone unless pruning is enabled
+		assert.Empty(t, schemaDiff.TablesToDrop, "Should not drop an unmanaged table by default")
+
+		comparer.SetPruneUnmanaged(true)
+		schemaDiff, err = comparer.CompareSchemas(currentSchema, targetSchema)
+		require.NoError(t, err)
+
 		assert.Len(t, schemaDiff.TablesToDrop, 1, "Should detect one table to drop")
 		assert.Equal(t, "extra_table", schemaDiff.TablesToDrop[0])
 		assert.Empty(t, schemaDiff.TablesToCreate)
@@ -462,6 +573,98 @@ func TestSchemaDiffUnit(t *testing.T) {
 		assert.Empty(t, schemaDiff.TablesToDrop)
 		assert.Empty(t, schemaDiff.TablesToModify)
 	})
+
+	t.Run("MySQL TINYINT(1) Boolean", func(t *testing.T) {
+		// MySQL introspects a bool column as tinyint(1)
+		currentSchema := createTestSchema("flags", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "bigint", PrimaryKey: true},
+			{Name: "enabled", DBName: "enabled", DataType: "tinyint(1)"},
+		})
+
+		targetSchema := createTestSchema("flags", []*schema.Field{
+			{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true},
+			{Name: "enabled", DBName: "enabled", DataType: "bool"},
+		})
+
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		tableDiff := comparer.CompareTable(currentSchema, targetSchema)
+
+		assert.True(t, tableDiff.IsEmpty(), "tinyint(1) should match a bool field")
+		assert.Empty(t, tableDiff.FieldsToModify)
+	})
+
+	t.Run("Table Comment", func(t *testing.T) {
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		targetSchema, err := comparer.GetModelSchemas(&TestAuditEntry{})
+		require.NoError(t, err)
+
+		schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, targetSchema)
+		require.NoError(t, err)
+
+		require.Len(t, schemaDiff.TablesToCreate, 1)
+		assert.Equal(t, "Append-only audit trail", schemaDiff.TablesToCreate[0].Comment)
+		assert.Empty(t, schemaDiff.TablesToCreate[0].PreviousComment)
+	})
+
+	t.Run("Migration-Ignored Fields", func(t *testing.T) {
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		targetSchema, err := comparer.GetModelSchemas(&TestLedgerEntry{})
+		require.NoError(t, err)
+
+		// Not created with the table
+		schemaDiff, err := comparer.CompareSchemas(map[string]*schema.Schema{}, targetSchema)
+		require.NoError(t, err)
+		require.Len(t, schemaDiff.TablesToCreate, 1)
+		var columns []string
+		for _, field := range schemaDiff.TablesToCreate[0].FieldsToAdd {
+			columns = append(columns, field.DBName)
+		}
+		assert.ElementsMatch(t, []string{"id", "amount"}, columns)
+
+		// Neither modified nor dropped when the database has them
+		currentSchema := map[string]*schema.Schema{
+			"TestLedgerEntry": createTestSchema("test_ledger_entries", []*schema.Field{
+				{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true, AutoIncrement: true},
+				{Name: "amount", DBName: "amount", DataType: "int"},
+				{Name: "balance", DBName: "balance", DataType: "numeric"},
+				{Name: "search_ts", DBName: "search_ts", DataType: "tsvector"},
+			}),
+		}
+		schemaDiff, err = comparer.CompareSchemas(currentSchema, targetSchema)
+		require.NoError(t, err)
+		assert.Empty(t, schemaDiff.TablesToCreate)
+		assert.Empty(t, schemaDiff.TablesToModify)
+	})
+
+	t.Run("Table Rename Hint", func(t *testing.T) {
+		currentSchema := map[string]*schema.Schema{
+			"people": createTestSchema("people", []*schema.Field{
+				{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true},
+			}),
+		}
+
+		// Target schema renames the table and adds a field
+		targetSchema := map[string]*schema.Schema{
+			"users": createTestSchema("users", []*schema.Field{
+				{Name: "id", DBName: "id", DataType: "uint", PrimaryKey: true},
+				{Name: "email", DBName: "email", DataType: "string"},
+			}),
+		}
+
+		comparer := diff.NewSchemaComparer(createTestDB(t))
+		comparer.SetTableRenames(map[string]string{"people": "users"})
+		schemaDiff, err := comparer.CompareSchemas(currentSchema, targetSchema)
+		require.NoError(t, err)
+
+		require.Len(t, schemaDiff.TablesToRename, 1, "Should detect one table rename")
+		assert.Equal(t, diff.TableRename{OldName: "people", NewName: "users"}, schemaDiff.TablesToRename[0])
+		assert.Empty(t, schemaDiff.TablesToCreate, "Renamed table should not be created")
+		assert.Empty(t, schemaDiff.TablesToDrop, "Renamed table should not be dropped")
+		require.Len(t, schemaDiff.TablesToModify, 1)
+		assert.Equal(t, "users", schemaDiff.TablesToModify[0].Schema.Table)
+		require.Len(t, schemaDiff.TablesToModify[0].FieldsToAdd, 1)
+		assert.Equal(t, "email", schemaDiff.TablesToModify[0].FieldsToAdd[0].DBName)
+	})
 }
 
 // Helper function to create test schemas
@@ -116,6 +116,7 @@ func TestSchemaComparer_CompareSchemas_NewTable(t *testing.T) {
 
 func TestSchemaComparer_CompareSchemas_DropTable(t *testing.T) {
 	comparer := diff.NewSchemaComparer(createTestDBForSchemaComparer(t))
+	comparer.SetPruneUnmanaged(true)
 
 	currentSchema := map[string]*schema.Schema{
 		"users": {
@@ -2,15 +2,19 @@ package migration
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
 	"github.com/beesaferoot/gorm-migrate/migration"
+	"github.com/beesaferoot/gorm-migrate/migration/diff"
 	"github.com/beesaferoot/gorm-migrate/migration/file"
+	"github.com/beesaferoot/gorm-migrate/migration/generator"
 )
 
 func TestMigrationFile(t *testing.T) {
@@ -79,3 +83,230 @@ func TestMigrationFile(t *testing.T) {
 		assert.Nil(t, found, "invalid migration should not be found")
 	})
 }
+
+func TestLoadMigrationsSkipsHelperFiles(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	migrationSrc := "package migrations\n\nfunc init() {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_create_users.go"), []byte(migrationSrc), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "migrations.go"), []byte("package migrations\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_create_users_test.go"), []byte("package migrations\n"), 0644))
+
+	loader := file.NewMigrationLoader(dir, nil)
+	migrations, err := loader.LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, "20240101120000", migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+}
+
+func TestLoadMigrationsCreatedAtFromFile(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	migrationSrc := "package migrations\n\nfunc init() {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_create_users.go"), []byte(migrationSrc), 0644))
+	// Not a valid timestamp, so the file's modification time is used
+	legacy := filepath.Join(dir, "20249999000000_legacy.go")
+	require.NoError(t, os.WriteFile(legacy, []byte(migrationSrc), 0644))
+	modTime := time.Date(2023, 6, 1, 8, 30, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(legacy, modTime, modTime))
+
+	migrations, err := file.NewMigrationLoader(dir, nil).LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.True(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local).Equal(migrations[0].CreatedAt), "got %s", migrations[0].CreatedAt)
+	assert.True(t, modTime.Equal(migrations[1].CreatedAt), "got %s", migrations[1].CreatedAt)
+}
+
+func TestLoadMigrationsTags(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	tagged := "package migrations\n\n// migrate:tags online, safe\nfunc init() {}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_add_index.go"), []byte(tagged), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240102120000_backfill.go"), []byte("package migrations\n\nfunc init() {}\n"), 0644))
+
+	migrations, err := file.NewMigrationLoader(dir, nil).LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, []string{"online", "safe"}, migrations[0].Tags)
+	assert.True(t, migrations[0].HasTag("online"))
+	assert.Empty(t, migrations[1].Tags)
+}
+
+func TestLoadMigrationsReturnNilInSQLComment(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	src := "package migrations\n\n" +
+		"func init() {\n" +
+		"\tmigration.RegisterMigration(&migration.Migration{\n" +
+		"\t\tUp: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`CREATE TABLE \"notes\" (id integer);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn db.Exec(\"CREATE TABLE \\\"tags\\\" (id integer);\").Error\n" +
+		"\t\t},\n" +
+		"\t\tDown: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`-- return nil once the notes are gone },\n" +
+		"DROP TABLE \"notes\";`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\tif err := db.Exec(`DROP TABLE \"tags\";`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t})\n" +
+		"}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_create_notes.go"), []byte(src), 0644))
+
+	migrations, err := file.NewMigrationLoader(dir, nil).LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, migrations[0].Up(db))
+	assert.True(t, db.Migrator().HasTable("notes"))
+	assert.True(t, db.Migrator().HasTable("tags"))
+
+	require.NoError(t, migrations[0].Down(db))
+	assert.False(t, db.Migrator().HasTable("notes"))
+	assert.False(t, db.Migrator().HasTable("tags"), "the statement after the comment must run too")
+}
+
+func TestLoadMigrationsSavepoints(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	src := "package migrations\n\n" +
+		"func init() {\n" +
+		"\tmigration.RegisterMigration(&migration.Migration{\n" +
+		"\t\tUp: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`CREATE TABLE items (id integer PRIMARY KEY);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\tif err := db.Exec(`INSERT INTO items (id) VALUES (1), (1);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn db.Exec(`INSERT INTO items (id) VALUES (2);`).Error\n" +
+		"\t\t},\n" +
+		"\t})\n" +
+		"}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_add_items.go"), []byte(src), 0644))
+
+	loader := file.NewMigrationLoader(dir, nil)
+	loader.SetSavepoints(true)
+	migrations, err := loader.LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	tx := db.Begin()
+	require.NoError(t, tx.Error)
+	defer tx.Rollback()
+
+	// The duplicate insert is rolled back to its savepoint and its error
+	// returned; the statement after it does not run, and the transaction is
+	// still usable
+	err = migrations[0].Up(tx)
+	require.ErrorContains(t, err, "statement 2 of Up")
+	var ids []int
+	require.NoError(t, tx.Raw(`SELECT id FROM items ORDER BY id`).Scan(&ids).Error)
+	assert.Empty(t, ids)
+}
+
+func TestLintReversibility(t *testing.T) {
+	dir := t.TempDir()
+	irreversible := "package migrations\n\n" +
+		"func init() {\n" +
+		"\tmigration.RegisterMigration(&migration.Migration{\n" +
+		"\t\tUp: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`ALTER TABLE \"users\" DROP COLUMN \"nickname\";`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\tif err := db.Exec(`DROP TABLE IF EXISTS \"sessions\";`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t\tDown: func(db *gorm.DB) error {\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t})\n" +
+		"}\n"
+	reversible := "package migrations\n\n" +
+		"func init() {\n" +
+		"\tmigration.RegisterMigration(&migration.Migration{\n" +
+		"\t\tUp: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`ALTER TABLE \"users\" ADD COLUMN \"email\" varchar(255);`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t\tDown: func(db *gorm.DB) error {\n" +
+		"\t\t\tif err := db.Exec(`ALTER TABLE \"users\" DROP COLUMN IF EXISTS \"email\";`).Error; err != nil {\n" +
+		"\t\t\t\treturn err\n" +
+		"\t\t\t}\n" +
+		"\t\t\treturn nil\n" +
+		"\t\t},\n" +
+		"\t})\n" +
+		"}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240101120000_drop_nickname.go"), []byte(irreversible), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "20240102120000_add_email.go"), []byte(reversible), 0644))
+
+	warnings, err := file.NewMigrationLoader(dir, nil).LintReversibility()
+	require.NoError(t, err)
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "20240101120000", warnings[0].Version)
+	assert.Equal(t, "drops column users.nickname but Down does not add it back", warnings[0].Reason)
+	assert.Equal(t, `ALTER TABLE "users" DROP COLUMN "nickname";`, warnings[0].Statement)
+	assert.Equal(t, "drops table sessions but Down does not recreate it", warnings[1].Reason)
+}
+
+func TestGeneratedReferenceDataRoundTrip(t *testing.T) {
+	migration.ResetMigrations()
+	t.Cleanup(migration.ResetMigrations)
+
+	dir := t.TempDir()
+	gen := generator.NewGenerator(dir)
+	gen.SetSchemaDiff(&diff.SchemaDiff{
+		RowsToInsert: []diff.ReferenceData{{
+			Table: "authors",
+			Rows:  []map[string]any{{"id": 1, "name": "Smith, John (NOT NULL DEFAULT)"}},
+		}},
+	})
+	require.NoError(t, gen.WriteMigration("20240101120000", "seed_authors"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "20240101120000_seed_authors.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `INSERT INTO "authors" ("id", "name") VALUES (1, 'Smith, John (NOT NULL DEFAULT)') ON CONFLICT DO NOTHING;`)
+	assert.Contains(t, string(content), `DELETE FROM "authors" WHERE "id" = 1 AND "name" = 'Smith, John (NOT NULL DEFAULT)';`)
+
+	migrations, err := file.NewMigrationLoader(dir, nil).LoadMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE authors (id integer PRIMARY KEY, name text)`).Error)
+
+	require.NoError(t, migrations[0].Up(db))
+	var names []string
+	require.NoError(t, db.Raw(`SELECT name FROM authors`).Scan(&names).Error)
+	assert.Equal(t, []string{"Smith, John (NOT NULL DEFAULT)"}, names)
+
+	require.NoError(t, migrations[0].Down(db))
+	var count int64
+	require.NoError(t, db.Table("authors").Count(&count).Error)
+	assert.Zero(t, count, "Down must delete the row Up inserted")
+}
@@ -92,3 +92,38 @@ func TestMigrator_Down(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), count)
 }
+
+func TestMigrator_DownSameAppliedAt(t *testing.T) {
+	db := setupTestDB(t)
+	migrator := driver.NewMigrator(db)
+
+	var reverted []string
+	for _, version := range []string{"20240315000001", "20240315000002"} {
+		version := version
+		migrator.Register(&migration.Migration{
+			Version:   version,
+			Name:      "migration_" + version,
+			CreatedAt: time.Now(),
+			Up: func(db *gorm.DB) error {
+				return nil
+			},
+			Down: func(db *gorm.DB) error {
+				reverted = append(reverted, version)
+				return nil
+			},
+		})
+	}
+	assert.NoError(t, migrator.Up())
+
+	// Simulate both migrations being applied in the same instant
+	appliedAt := time.Now()
+	assert.NoError(t, db.Model(&migration.MigrationRecord{}).Where("1 = 1").Update("applied_at", appliedAt).Error)
+
+	assert.NoError(t, migrator.Down())
+	assert.Equal(t, []string{"20240315000002"}, reverted, "The migration applied last should be reverted")
+
+	var remaining []migration.MigrationRecord
+	assert.NoError(t, db.Find(&remaining).Error)
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, "20240315000001", remaining[0].Version)
+}